@@ -4,28 +4,76 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"strconv"
+	"sort"
+	"strings"
 )
 
+// DefaultAccount is the profile used when no --account is given.
+const DefaultAccount = "default"
+
 type Config struct {
 	APIId   int
 	APIHash string
 	DataDir string
+	Account string // profile name; its data lives in AccountDir
+	Proxy   string // proxy URL, see telegram.ParseProxy; empty connects directly
+
+	// UI settings, applied again on reload.
+	DownloadDir     string
+	TimestampFormat string // Go time layout for message timestamps
+	ChatListWidth   int    // percent of the terminal width
+	ImageProtocol   string // "auto" or a protocol from ImageProtocols
+	Theme           string // built-in theme, theme file, or name of one in ThemeDir
+	Keymap          string // preset from KeymapPresets, adjusted by Keys
+	Keys            Keys
+
+	// File is the config file the settings were read from, empty if
+	// there was none.
+	File string
 }
 
-func Load() (*Config, error) {
-	apiIDStr := os.Getenv("TGTUI_API_ID")
-	if apiIDStr == "" {
-		return nil, fmt.Errorf("TGTUI_API_ID environment variable is required")
+// Load reads the settings from, in increasing order of precedence,
+// built-in defaults, the config file at path (DefaultPath if empty),
+// flags (keyed by config key) and TGTUI_* environment variables. Errors
+// name the offending key and where it was set.
+func Load(path string, flags map[string]string) (*Config, error) {
+	c := defaults()
+
+	explicit := path != ""
+	if !explicit {
+		var err error
+		if path, err = DefaultPath(); err != nil {
+			return nil, fmt.Errorf("failed to determine config directory: %w", err)
+		}
 	}
-	apiID, err := strconv.Atoi(apiIDStr)
-	if err != nil {
-		return nil, fmt.Errorf("TGTUI_API_ID must be an integer: %w", err)
+	if err := c.loadFile(path); err != nil {
+		if !os.IsNotExist(err) || explicit {
+			return nil, err
+		}
+	} else {
+		c.File = path
 	}
 
-	apiHash := os.Getenv("TGTUI_API_HASH")
-	if apiHash == "" {
-		return nil, fmt.Errorf("TGTUI_API_HASH environment variable is required")
+	for _, st := range settings {
+		if v, ok := flags[st.key]; ok {
+			if err := st.set(c, v); err != nil {
+				return nil, fmt.Errorf("--%s: %w", strings.ReplaceAll(st.key, "_", "-"), err)
+			}
+		}
+	}
+	for _, st := range settings {
+		if v := os.Getenv(st.env()); v != "" {
+			if err := st.set(c, v); err != nil {
+				return nil, fmt.Errorf("%s: %w", st.env(), err)
+			}
+		}
+	}
+
+	if c.APIId == 0 {
+		return nil, fmt.Errorf("api_id is required: set TGTUI_API_ID or api_id in %s", path)
+	}
+	if c.APIHash == "" {
+		return nil, fmt.Errorf("api_hash is required: set TGTUI_API_HASH or api_hash in %s", path)
 	}
 
 	dataDir, err := dataDirectory()
@@ -36,16 +84,113 @@ func Load() (*Config, error) {
 	if err := os.MkdirAll(dataDir, 0700); err != nil {
 		return nil, fmt.Errorf("failed to create data directory: %w", err)
 	}
+	c.DataDir = dataDir
+	return c, nil
+}
+
+// WithAccount returns a copy of c for the named profile, creating its
+// directory if needed.
+func (c *Config) WithAccount(name string) (*Config, error) {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return nil, fmt.Errorf("invalid account name %q", name)
+	}
+	ac := *c
+	ac.Account = name
+	if name == DefaultAccount {
+		if err := ac.migrateLegacySession(); err != nil {
+			return nil, fmt.Errorf("failed to move session into accounts/%s: %w", name, err)
+		}
+	}
+	if err := os.MkdirAll(ac.AccountDir(), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create account directory: %w", err)
+	}
+	return &ac, nil
+}
+
+// Accounts lists the profiles that have a directory under DataDir. A
+// session from before profiles existed is moved into the default one
+// first, so it is listed whichever account is chosen at startup.
+func (c *Config) Accounts() ([]string, error) {
+	def := *c
+	def.Account = DefaultAccount
+	if err := def.migrateLegacySession(); err != nil {
+		return nil, fmt.Errorf("failed to move session into accounts/%s: %w", DefaultAccount, err)
+	}
+	entries, err := os.ReadDir(filepath.Join(c.DataDir, "accounts"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
 
-	return &Config{
-		APIId:   apiID,
-		APIHash: apiHash,
-		DataDir: dataDir,
-	}, nil
+func (c *Config) AccountDir() string {
+	return filepath.Join(c.DataDir, "accounts", c.Account)
 }
 
 func (c *Config) SessionPath() string {
-	return filepath.Join(c.DataDir, "session.json")
+	return filepath.Join(c.AccountDir(), "session.json")
+}
+
+// UpdateStatePath is where the update sequence state is kept between runs.
+func (c *Config) UpdateStatePath() string {
+	return filepath.Join(c.AccountDir(), "updates.json")
+}
+
+// migrateLegacySession moves files from before profiles existed, when
+// they lived directly in DataDir, into the account's directory.
+func (c *Config) migrateLegacySession() error {
+	legacy := filepath.Join(c.DataDir, "session.json")
+	if _, err := os.Stat(legacy); err != nil {
+		return nil
+	}
+	if _, err := os.Stat(c.SessionPath()); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(c.AccountDir(), 0700); err != nil {
+		return err
+	}
+	for _, name := range []string{"session.json", "updates.json"} {
+		err := os.Rename(filepath.Join(c.DataDir, name), filepath.Join(c.AccountDir(), name))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// ThemeDir is where theme files named by Theme are looked up: a themes
+// directory beside the config file.
+func (c *Config) ThemeDir() string {
+	path := c.File
+	if path == "" {
+		var err error
+		if path, err = DefaultPath(); err != nil {
+			return ""
+		}
+	}
+	return filepath.Join(filepath.Dir(path), "themes")
+}
+
+// DefaultPath is $XDG_CONFIG_HOME/tgtui/config.toml.
+func DefaultPath() (string, error) {
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		return filepath.Join(xdg, "tgtui", "config.toml"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "tgtui", "config.toml"), nil
 }
 
 func dataDirectory() (string, error) {
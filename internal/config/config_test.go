@@ -0,0 +1,157 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	t.Setenv("TGTUI_API_ID", "")
+	t.Setenv("TGTUI_API_HASH", "")
+	t.Setenv("TGTUI_THEME", "")
+	path := writeConfig(t, `
+api_id = 123
+api_hash = "from-file"
+chat_list_width = 25
+theme = "light"
+download_dir = "/tmp/dl"
+
+[keys.normal]
+reply = "R"
+delete = ["d", "x"]
+`)
+
+	cfg, err := Load(path, map[string]string{"theme": "from-flag", "chat_list_width": "40"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.APIId != 123 || cfg.APIHash != "from-file" || cfg.DownloadDir != "/tmp/dl" {
+		t.Errorf("file values not applied: %+v", cfg)
+	}
+	if cfg.Theme != "from-flag" || cfg.ChatListWidth != 40 {
+		t.Errorf("flags should override the file: theme=%q width=%d", cfg.Theme, cfg.ChatListWidth)
+	}
+	if cfg.TimestampFormat != "Mon 02/01/2006 15:04" || cfg.ImageProtocol != "auto" {
+		t.Errorf("defaults not applied: %+v", cfg)
+	}
+	if got := cfg.Keys["normal"]["delete"]; len(got) != 2 || got[1] != "x" {
+		t.Errorf("keys.normal.delete = %v", got)
+	}
+
+	t.Setenv("TGTUI_THEME", "from-env")
+	cfg, err = Load(path, map[string]string{"theme": "from-flag"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Theme != "from-env" {
+		t.Errorf("environment should override flags, got theme %q", cfg.Theme)
+	}
+}
+
+func TestLoadErrorsNameTheKey(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	t.Setenv("TGTUI_API_ID", "1")
+	t.Setenv("TGTUI_API_HASH", "h")
+
+	tests := []struct {
+		file  string
+		flags map[string]string
+		want  string
+	}{
+		{file: `chat_list_width = 95`, want: "chat_list_width"},
+		{file: `image_protocol = "ascii"`, want: "image_protocol"},
+		{file: `timestamp_format = "hh:mm"`, want: "timestamp_format"},
+		{file: `colour = "red"`, want: "colour: unknown key"},
+		{file: `download_dir = 5`, want: "download_dir"},
+		{file: "[keys.normal]\nreply = 1", want: "keys: normal.reply"},
+		{flags: map[string]string{"chat_list_width": "wide"}, want: "--chat-list-width"},
+	}
+	for _, tt := range tests {
+		_, err := Load(writeConfig(t, tt.file), tt.flags)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Load(%q, %v) = %v, want an error mentioning %q", tt.file, tt.flags, err, tt.want)
+		}
+	}
+
+	t.Setenv("TGTUI_API_ID", "abc")
+	if _, err := Load(writeConfig(t, ""), nil); err == nil || !strings.Contains(err.Error(), "TGTUI_API_ID") {
+		t.Errorf("bad environment value: got %v", err)
+	}
+}
+
+func TestLoadWithoutFile(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("TGTUI_API_ID", "1")
+	t.Setenv("TGTUI_API_HASH", "h")
+
+	cfg, err := Load("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.File != "" {
+		t.Errorf("File = %q, want none", cfg.File)
+	}
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.toml"), nil); err == nil {
+		t.Error("expected an explicitly given missing file to be an error")
+	}
+}
+
+func TestAccountsMigratesLegacySession(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"session.json", "updates.json"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Starting with another account still finds the old login.
+	cfg := &Config{DataDir: dir, Account: "work"}
+	if _, err := cfg.WithAccount("work"); err != nil {
+		t.Fatal(err)
+	}
+
+	names, err := cfg.Accounts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(names, ",") != DefaultAccount+",work" {
+		t.Errorf("Accounts() = %v, want [%s work]", names, DefaultAccount)
+	}
+	def, err := cfg.WithAccount(DefaultAccount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(def.SessionPath()); err != nil || string(data) != "session.json" {
+		t.Errorf("session not moved into the default account: %q, %v", data, err)
+	}
+	if _, err := os.Stat(def.UpdateStatePath()); err != nil {
+		t.Errorf("update state not moved: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "session.json")); !os.IsNotExist(err) {
+		t.Errorf("expected the old session file gone, stat err = %v", err)
+	}
+}
+
+func TestWithAccountRejectsBadNames(t *testing.T) {
+	cfg := &Config{DataDir: t.TempDir()}
+	for _, name := range []string{"", ".", "..", "a/b", `a\b`, "../escape"} {
+		if _, err := cfg.WithAccount(name); err == nil {
+			t.Errorf("WithAccount(%q): expected an error", name)
+		}
+	}
+	if _, err := cfg.WithAccount("personal"); err != nil {
+		t.Errorf("WithAccount(personal): %v", err)
+	}
+}
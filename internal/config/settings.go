@@ -0,0 +1,222 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// ImageProtocols are the accepted image_protocol values besides "auto";
+// "none" turns inline photo previews off.
+var ImageProtocols = []string{"kitty", "iterm", "sixel", "halfblock", "none"}
+
+// KeymapPresets are the accepted keymap values: the built-in bindings
+// that [keys] tables adjust.
+var KeymapPresets = []string{"helix", "vim", "emacs"}
+
+// Keys are user keybindings: mode → action → keys, as in
+//
+//	[keys.normal]
+//	reply = "r"
+//	delete = ["d", "x"]
+type Keys map[string]map[string][]string
+
+// setting is a scalar config key, settable from the file, a flag of the
+// same name and a TGTUI_ environment variable.
+type setting struct {
+	key    string
+	number bool // an integer in the file rather than a string
+	set    func(c *Config, v string) error
+}
+
+func (s setting) env() string {
+	return "TGTUI_" + strings.ToUpper(s.key)
+}
+
+var settings = []setting{
+	{"api_id", true, func(c *Config, v string) error {
+		id, err := strconv.Atoi(v)
+		if err != nil || id <= 0 {
+			return fmt.Errorf("must be a positive integer, got %q", v)
+		}
+		c.APIId = id
+		return nil
+	}},
+	{"api_hash", false, func(c *Config, v string) error {
+		c.APIHash = v
+		return nil
+	}},
+	{"proxy", false, func(c *Config, v string) error {
+		c.Proxy = v
+		return nil
+	}},
+	{"download_dir", false, func(c *Config, v string) error {
+		if v == "" {
+			return fmt.Errorf("must not be empty")
+		}
+		c.DownloadDir = expandHome(v)
+		return nil
+	}},
+	{"timestamp_format", false, func(c *Config, v string) error {
+		// A layout without any reference-time element formats to itself.
+		if v == "" || time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC).Format(v) == v {
+			return fmt.Errorf("%q is not a Go time layout, e.g. \"Mon 02/01/2006 15:04\"", v)
+		}
+		c.TimestampFormat = v
+		return nil
+	}},
+	{"chat_list_width", true, func(c *Config, v string) error {
+		w, err := strconv.Atoi(v)
+		if err != nil || w < 10 || w > 70 {
+			return fmt.Errorf("must be a percentage between 10 and 70, got %q", v)
+		}
+		c.ChatListWidth = w
+		return nil
+	}},
+	{"image_protocol", false, func(c *Config, v string) error {
+		if v != "auto" && !slices.Contains(ImageProtocols, v) {
+			return fmt.Errorf("must be auto or one of %s, got %q", strings.Join(ImageProtocols, ", "), v)
+		}
+		c.ImageProtocol = v
+		return nil
+	}},
+	{"theme", false, func(c *Config, v string) error {
+		if v == "" {
+			return fmt.Errorf("must not be empty")
+		}
+		c.Theme = v
+		return nil
+	}},
+	{"keymap", false, func(c *Config, v string) error {
+		if !slices.Contains(KeymapPresets, v) {
+			return fmt.Errorf("must be one of %s, got %q", strings.Join(KeymapPresets, ", "), v)
+		}
+		c.Keymap = v
+		return nil
+	}},
+}
+
+// IsKey reports whether key is a scalar setting, which can also be given
+// as a flag.
+func IsKey(key string) bool {
+	for _, st := range settings {
+		if st.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func defaults() *Config {
+	home, _ := os.UserHomeDir()
+	return &Config{
+		Account:         DefaultAccount,
+		DownloadDir:     filepath.Join(home, "Downloads"),
+		TimestampFormat: "Mon 02/01/2006 15:04",
+		ChatListWidth:   30,
+		ImageProtocol:   "auto",
+		Theme:           "dark",
+		Keymap:          "helix",
+	}
+}
+
+// loadFile applies the settings in the TOML file at path.
+func (c *Config) loadFile(path string) error {
+	var raw map[string]interface{}
+	if _, err := toml.DecodeFile(path, &raw); err != nil {
+		if os.IsNotExist(err) {
+			return err
+		}
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	keys := make([]string, 0, len(raw))
+	for k := range raw {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		if err := c.setFromFile(key, raw[key]); err != nil {
+			return fmt.Errorf("%s: %s: %w", path, key, err)
+		}
+	}
+	return nil
+}
+
+func (c *Config) setFromFile(key string, value interface{}) error {
+	if key == "keys" {
+		keys, err := parseKeys(value)
+		if err != nil {
+			return err
+		}
+		c.Keys = keys
+		return nil
+	}
+	for _, st := range settings {
+		if st.key != key {
+			continue
+		}
+		switch v := value.(type) {
+		case string:
+			if !st.number {
+				return st.set(c, v)
+			}
+		case int64:
+			if st.number {
+				return st.set(c, strconv.FormatInt(v, 10))
+			}
+		}
+		if st.number {
+			return fmt.Errorf("expected a number, got %v", value)
+		}
+		return fmt.Errorf("expected a string, got %v", value)
+	}
+	return fmt.Errorf("unknown key")
+}
+
+func parseKeys(value interface{}) (Keys, error) {
+	modes, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected a table of modes, e.g. [keys.normal]")
+	}
+	keys := make(Keys)
+	for mode, v := range modes {
+		actions, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: expected a table of actions", mode)
+		}
+		keys[mode] = make(map[string][]string)
+		for action, v := range actions {
+			switch v := v.(type) {
+			case string:
+				keys[mode][action] = []string{v}
+			case []interface{}:
+				for _, k := range v {
+					s, ok := k.(string)
+					if !ok {
+						return nil, fmt.Errorf("%s.%s: expected keys as strings", mode, action)
+					}
+					keys[mode][action] = append(keys[mode][action], s)
+				}
+			default:
+				return nil, fmt.Errorf("%s.%s: expected a key or a list of keys", mode, action)
+			}
+		}
+	}
+	return keys, nil
+}
+
+func expandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[1:])
+		}
+	}
+	return path
+}
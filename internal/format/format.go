@@ -1,13 +1,16 @@
 package format
 
 import (
-	"fmt"
+	"slices"
 	"sort"
 	"strings"
+	"sync/atomic"
+	"unicode"
 	"unicode/utf16"
 
 	"github.com/charmbracelet/lipgloss"
 	"github.com/gotd/td/tg"
+	"github.com/paramon-tech/tgtui/internal/theme"
 )
 
 type entityInfo struct {
@@ -22,15 +25,29 @@ const (
 	ansiItalic        = "3"
 	ansiUnderline     = "4"
 	ansiStrikethrough = "9"
-	ansiReverse       = "7"
 )
 
-func ansiFg(r, g, b int) string {
-	return fmt.Sprintf("38;2;%d;%d;%d", r, g, b)
+// textTheme styles entities; see UseTheme.
+var textTheme atomic.Pointer[theme.Theme]
+
+// UseTheme sets the theme entities are styled with.
+func UseTheme(t *theme.Theme) {
+	textTheme.Store(t)
+}
+
+func currentTheme() *theme.Theme {
+	if t := textTheme.Load(); t != nil {
+		return t
+	}
+	t := theme.Default()
+	textTheme.CompareAndSwap(nil, t)
+	return textTheme.Load()
 }
 
-func ansiBg(r, g, b int) string {
-	return fmt.Sprintf("48;2;%d;%d;%d", r, g, b)
+// sgr returns the codes of a theme style, its colors fitted to the
+// terminal: degraded to 256 or 16 colors, or left out under NO_COLOR.
+func sgr(s theme.Style) []string {
+	return s.SGR(lipgloss.ColorProfile())
 }
 
 func ansiWrap(text string, codes []string) string {
@@ -44,20 +61,80 @@ func ansiWrap(text string, codes []string) string {
 // returning a styled string suitable for terminal display.
 // Newlines are replaced with spaces so each message occupies one visual line.
 func RenderStyledText(text string, entities []tg.MessageEntityClass) string {
-	return renderStyledText(text, entities, false)
+	return renderStyledText(text, entities, nil, false)
 }
 
 // RenderStyledTextMultiline applies Telegram entity formatting and preserves
 // newlines. Long lines are word-wrapped to the given width (ANSI-aware).
 func RenderStyledTextMultiline(text string, entities []tg.MessageEntityClass, width int) string {
-	result := renderStyledText(text, entities, true)
+	return wrap(renderStyledText(text, entities, nil, true), width)
+}
+
+// RenderHighlighted is RenderStyledText with every occurrence of words,
+// ignoring case, in the theme's match style.
+func RenderHighlighted(text string, entities []tg.MessageEntityClass, words []string) string {
+	return renderStyledText(text, entities, matchEntities(text, words), false)
+}
+
+// RenderHighlightedMultiline is RenderStyledTextMultiline with words
+// highlighted as in RenderHighlighted.
+func RenderHighlightedMultiline(text string, entities []tg.MessageEntityClass, words []string, width int) string {
+	return wrap(renderStyledText(text, entities, matchEntities(text, words), true), width)
+}
+
+func wrap(s string, width int) string {
 	if width > 0 {
-		return lipgloss.NewStyle().Width(width).Render(result)
+		return lipgloss.NewStyle().Width(width).Render(s)
 	}
-	return result
+	return s
 }
 
-func renderStyledText(text string, entities []tg.MessageEntityClass, preserveNewlines bool) string {
+// matchEntities returns an entity spanning each case-insensitive
+// occurrence of words in text. They are told apart from the message's
+// own entities by identity, not type.
+func matchEntities(text string, words []string) map[tg.MessageEntityClass]bool {
+	if len(words) == 0 {
+		return nil
+	}
+	hay := []rune(text)
+	for i, r := range hay {
+		hay[i] = unicode.ToLower(r)
+	}
+	// utf16At[i] is the UTF-16 offset of rune i, as entities count.
+	utf16At := make([]int, len(hay)+1)
+	for i, r := range hay {
+		utf16At[i+1] = utf16At[i] + utf16.RuneLen(r)
+	}
+
+	marks := make(map[tg.MessageEntityClass]bool)
+	for _, word := range words {
+		needle := []rune(word)
+		for i, r := range needle {
+			needle[i] = unicode.ToLower(r)
+		}
+		if len(needle) == 0 {
+			continue
+		}
+		for i := 0; i+len(needle) <= len(hay); i++ {
+			if slices.Equal(hay[i:i+len(needle)], needle) {
+				end := i + len(needle)
+				marks[&tg.MessageEntityUnknown{Offset: utf16At[i], Length: utf16At[end] - utf16At[i]}] = true
+				i = end - 1
+			}
+		}
+	}
+	return marks
+}
+
+// renderStyledText styles text by its entities and by marks, the spans
+// of matchEntities.
+func renderStyledText(text string, entities []tg.MessageEntityClass, marks map[tg.MessageEntityClass]bool, preserveNewlines bool) string {
+	if len(marks) > 0 {
+		entities = slices.Clone(entities)
+		for mark := range marks {
+			entities = append(entities, mark)
+		}
+	}
 	if len(entities) == 0 {
 		if preserveNewlines {
 			return text
@@ -173,7 +250,7 @@ func renderStyledText(text string, entities []tg.MessageEntityClass, preserveNew
 			continue
 		}
 
-		codes, suffix := buildANSICodes(active)
+		codes, suffix := buildANSICodes(active, marks)
 		result.WriteString(ansiWrap(segment, codes))
 		if suffix != "" {
 			result.WriteString(suffix)
@@ -183,12 +260,18 @@ func renderStyledText(text string, entities []tg.MessageEntityClass, preserveNew
 	return result.String()
 }
 
-func buildANSICodes(active []entityInfo) ([]string, string) {
+func buildANSICodes(active []entityInfo, marks map[tg.MessageEntityClass]bool) ([]string, string) {
 	var codes []string
 	var suffix string
 	isBlockquote := false
+	isMatch := false
+	t := currentTheme()
 
 	for _, a := range active {
+		if marks[a.ent] {
+			isMatch = true
+			continue
+		}
 		switch e := a.ent.(type) {
 		case *tg.MessageEntityBold:
 			codes = append(codes, ansiBold)
@@ -199,41 +282,33 @@ func buildANSICodes(active []entityInfo) ([]string, string) {
 		case *tg.MessageEntityStrike:
 			codes = append(codes, ansiStrikethrough)
 		case *tg.MessageEntityCode:
-			codes = append(codes, ansiFg(255, 158, 100), ansiBg(26, 27, 38))
+			codes = append(codes, sgr(t.Code)...)
 		case *tg.MessageEntityPre:
-			codes = append(codes, ansiFg(169, 177, 214), ansiBg(26, 27, 38))
-		case *tg.MessageEntityURL:
-			codes = append(codes, ansiUnderline, ansiFg(122, 162, 247))
+			codes = append(codes, sgr(t.Pre)...)
+		case *tg.MessageEntityURL, *tg.MessageEntityEmail, *tg.MessageEntityPhone:
+			codes = append(codes, sgr(t.Link)...)
 		case *tg.MessageEntityTextURL:
-			codes = append(codes, ansiUnderline, ansiFg(122, 162, 247))
+			codes = append(codes, sgr(t.Link)...)
 			if e.URL != "" {
-				suffix = ansiWrap(" ("+e.URL+")", []string{ansiFg(86, 95, 137)})
+				suffix = ansiWrap(" ("+e.URL+")", sgr(t.LinkURL))
 			}
-		case *tg.MessageEntityEmail:
-			codes = append(codes, ansiUnderline, ansiFg(122, 162, 247))
-		case *tg.MessageEntityMention:
-			codes = append(codes, ansiFg(187, 154, 247))
-		case *tg.MessageEntityMentionName:
-			codes = append(codes, ansiFg(187, 154, 247))
-		case *tg.MessageEntityHashtag:
-			codes = append(codes, ansiFg(125, 207, 255))
-		case *tg.MessageEntityBotCommand:
-			codes = append(codes, ansiFg(125, 207, 255))
-		case *tg.MessageEntityCashtag:
-			codes = append(codes, ansiFg(125, 207, 255))
+		case *tg.MessageEntityMention, *tg.MessageEntityMentionName:
+			codes = append(codes, sgr(t.Mention)...)
+		case *tg.MessageEntityHashtag, *tg.MessageEntityBotCommand, *tg.MessageEntityCashtag:
+			codes = append(codes, sgr(t.Hashtag)...)
 		case *tg.MessageEntitySpoiler:
-			codes = append(codes, ansiReverse)
+			codes = append(codes, sgr(t.Spoiler)...)
 		case *tg.MessageEntityBlockquote:
 			isBlockquote = true
-		case *tg.MessageEntityPhone:
-			codes = append(codes, ansiFg(122, 162, 247))
-		default:
-			_ = e
 		}
 	}
 
 	if isBlockquote {
-		codes = append(codes, ansiFg(169, 177, 214))
+		codes = append(codes, sgr(t.Quote)...)
+	}
+	// Last, so its colors win over the entities'.
+	if isMatch {
+		codes = append(codes, sgr(t.Match)...)
 	}
 
 	return codes, suffix
@@ -95,3 +95,36 @@ func TestRenderStyledText_Emoji(t *testing.T) {
 		t.Errorf("Expected bold 'Hello' after emoji, got: %q", result)
 	}
 }
+
+func TestRenderHighlighted(t *testing.T) {
+	// highlighted reports whether s is rendered on its own in the match style.
+	highlighted := func(result, s string) bool {
+		i := strings.Index(result, s+ansiReset)
+		return i > 0 && result[i-1] == 'm'
+	}
+
+	result := RenderHighlighted("Hello world, hello again", nil, []string{"HELLO"})
+	if !highlighted(result, "Hello") || !highlighted(result, "hello") {
+		t.Errorf("expected both hellos highlighted, got: %q", result)
+	}
+	if !strings.Contains(result, " world, ") {
+		t.Errorf("expected the rest unstyled, got: %q", result)
+	}
+
+	// Offsets after an emoji count UTF-16 units.
+	result = RenderHighlighted("👋 hi там", nil, []string{"ТАМ"})
+	if !highlighted(result, "там") {
+		t.Errorf("expected там highlighted, got: %q", result)
+	}
+
+	// Matches keep the message's own styling.
+	bold := []tg.MessageEntityClass{&tg.MessageEntityBold{Offset: 0, Length: 11}}
+	result = RenderHighlighted("Hello world", bold, []string{"world"})
+	if !strings.HasPrefix(result, "\x1b[1mHello ") || !strings.Contains(result, "\x1b[1;") {
+		t.Errorf("expected bold text with a highlighted match, got: %q", result)
+	}
+
+	if got := RenderHighlighted("Hello\nworld", nil, nil); got != "Hello world" {
+		t.Errorf("expected no highlight without words, got: %q", got)
+	}
+}
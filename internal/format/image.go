@@ -12,6 +12,8 @@ import (
 	"strings"
 
 	"github.com/BourgeoisBear/rasterm"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
 	xdraw "golang.org/x/image/draw"
 )
 
@@ -175,23 +177,26 @@ func RenderImageHalfBlock(data []byte, maxWidth, maxHeight int) (string, int, er
 	// Render using half-block characters
 	var sb strings.Builder
 	lineCount := dstH / 2
+	profile := lipgloss.ColorProfile()
 
 	for row := 0; row < dstH; row += 2 {
 		if row > 0 {
 			sb.WriteString("\n")
 		}
 		for col := 0; col < dstW; col++ {
-			// Top pixel → background color
-			tr, tg, tb, _ := dst.At(col, row).RGBA()
-			// Bottom pixel → foreground color
-			br, bg, bb, _ := dst.At(col, row+1).RGBA()
-
-			// RGBA returns 16-bit values, convert to 8-bit
-			sb.WriteString(fmt.Sprintf("\x1b[48;2;%d;%d;%d;38;2;%d;%d;%dm▄",
-				tr>>8, tg>>8, tb>>8,
-				br>>8, bg>>8, bb>>8))
+			// Top pixel → background color, bottom pixel → foreground color
+			top, bottom := dst.At(col, row), dst.At(col, row+1)
+			if profile == termenv.Ascii {
+				// No colors (NO_COLOR): shade by the brightness of the pair.
+				sb.WriteRune(shade(top, bottom))
+				continue
+			}
+			sb.WriteString("\x1b[" + pixelColor(profile, top).Sequence(true) + ";" +
+				pixelColor(profile, bottom).Sequence(false) + "m▄")
+		}
+		if profile != termenv.Ascii {
+			sb.WriteString(ansiResetSeq)
 		}
-		sb.WriteString(ansiResetSeq)
 	}
 
 	return sb.String(), lineCount, nil
@@ -199,6 +204,24 @@ func RenderImageHalfBlock(data []byte, maxWidth, maxHeight int) (string, int, er
 
 // Helper functions
 
+// pixelColor converts a pixel to a color of profile p, degrading it to
+// the nearest of 256 or 16 colors where truecolor is unsupported.
+func pixelColor(p termenv.Profile, c color.Color) termenv.Color {
+	r, g, b, _ := c.RGBA()
+	// RGBA returns 16-bit values, convert to 8-bit
+	return p.Color(fmt.Sprintf("#%02x%02x%02x", r>>8, g>>8, b>>8))
+}
+
+var shades = []rune(" ░▒▓█")
+
+// shade picks a block whose density matches the mean luminance of two
+// stacked pixels.
+func shade(top, bottom color.Color) rune {
+	y := int(color.GrayModel.Convert(top).(color.Gray).Y) +
+		int(color.GrayModel.Convert(bottom).(color.Gray).Y)
+	return shades[y*len(shades)/512]
+}
+
 func decodeImage(data []byte) (image.Image, error) {
 	src, _, err := image.Decode(bytes.NewReader(data))
 	if err != nil {
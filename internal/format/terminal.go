@@ -1,6 +1,7 @@
 package format
 
 import (
+	"fmt"
 	"sync"
 
 	"github.com/BourgeoisBear/rasterm"
@@ -19,16 +20,53 @@ const (
 var (
 	detectedProtocol ImageProtocol
 	detectOnce       sync.Once
+
+	forcedMu       sync.Mutex
+	forcedProtocol *ImageProtocol // set by UseImageProtocol
 )
 
-// DetectImageProtocol returns the best image protocol available in the current terminal.
+// DetectImageProtocol returns the protocol chosen with UseImageProtocol,
+// or else the best one available in the current terminal.
 func DetectImageProtocol() ImageProtocol {
+	forcedMu.Lock()
+	forced := forcedProtocol
+	forcedMu.Unlock()
+	if forced != nil {
+		return *forced
+	}
 	detectOnce.Do(func() {
 		detectedProtocol = detectProtocol()
 	})
 	return detectedProtocol
 }
 
+// UseImageProtocol forces the protocol by name ("kitty", "iterm", "sixel"
+// or "halfblock"); "auto" goes back to detecting it.
+func UseImageProtocol(name string) error {
+	var p ImageProtocol
+	switch name {
+	case "auto":
+		forcedMu.Lock()
+		forcedProtocol = nil
+		forcedMu.Unlock()
+		return nil
+	case "kitty":
+		p = ProtoKitty
+	case "iterm":
+		p = ProtoIterm
+	case "sixel":
+		p = ProtoSixel
+	case "halfblock":
+		p = ProtoHalfBlock
+	default:
+		return fmt.Errorf("unknown image protocol %q", name)
+	}
+	forcedMu.Lock()
+	forcedProtocol = &p
+	forcedMu.Unlock()
+	return nil
+}
+
 func detectProtocol() ImageProtocol {
 	if rasterm.IsKittyCapable() {
 		return ProtoKitty
@@ -0,0 +1,90 @@
+// Package keyring stores small secrets in the OS keyring through the
+// platform's command-line tool: secret-tool (libsecret) on Linux and the
+// BSDs, security (Keychain) on macOS.
+package keyring
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+var (
+	// ErrNotFound is returned by Get when no secret is stored.
+	ErrNotFound = errors.New("keyring: secret not found")
+	// ErrUnsupported is returned when there is no keyring tool for this OS.
+	ErrUnsupported = errors.New("keyring: not supported on " + runtime.GOOS)
+)
+
+// Get returns the secret stored for service and account.
+func Get(service, account string) (string, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "find-generic-password", "-s", service, "-a", account, "-w")
+	case "linux", "freebsd", "openbsd", "netbsd":
+		cmd = exec.Command("secret-tool", "lookup", "service", service, "account", account)
+	default:
+		return "", ErrUnsupported
+	}
+	out, err := cmd.Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		// Both tools exit non-zero, without output, for a missing item.
+		return "", ErrNotFound
+	}
+	if errors.Is(err, exec.ErrNotFound) {
+		return "", ErrUnsupported
+	}
+	if err != nil {
+		return "", err
+	}
+	secret := strings.TrimSuffix(string(out), "\n")
+	if secret == "" {
+		return "", ErrNotFound
+	}
+	return secret, nil
+}
+
+// Set stores secret for service and account, replacing any previous one.
+func Set(service, account, secret string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		// The command goes to security's interactive mode on stdin, so the
+		// secret never appears in an argument list other users can read
+		// with ps. -X takes it hex-encoded, which needs no quoting.
+		cmd = exec.Command("security", "-i")
+		cmd.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -s %s -a %s -X %s\n",
+			quote(service), quote(account), hex.EncodeToString([]byte(secret))))
+	case "linux", "freebsd", "openbsd", "netbsd":
+		cmd = exec.Command("secret-tool", "store", "--label", service+" ("+account+")",
+			"service", service, "account", account)
+		cmd.Stdin = strings.NewReader(secret)
+	default:
+		return ErrUnsupported
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if errors.Is(err, exec.ErrNotFound) {
+		return ErrUnsupported
+	}
+	if err != nil {
+		return fmt.Errorf("keyring: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	// security -i exits zero even when the command it read fails.
+	if runtime.GOOS == "darwin" && stderr.Len() > 0 {
+		return fmt.Errorf("keyring: %s", strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// quote single-quotes s for security's command line.
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
+}
@@ -5,10 +5,12 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/gotd/td/session"
 	"github.com/gotd/td/telegram/auth"
 	"github.com/gotd/td/telegram/auth/qrlogin"
 	"github.com/gotd/td/tgerr"
 	"github.com/gotd/td/tg"
+	"github.com/paramon-tech/tgtui/internal/keyring"
 )
 
 type AuthorizedMsg struct{}
@@ -31,6 +33,68 @@ type QRTokenMsg struct {
 
 type QRAuthDoneMsg struct{}
 
+// NeedPassphraseMsg is sent on startup when the session is encrypted and
+// its passphrase is not in the OS keyring.
+type NeedPassphraseMsg struct{}
+
+// SessionUnlockedMsg is returned by UnlockSession once the passphrase
+// opened the session; connecting continues.
+type SessionUnlockedMsg struct{}
+
+// keyringService is the OS keyring service session passphrases are kept
+// under, one entry per account.
+const keyringService = "tgtui"
+
+// sessionStorage returns the storage for the account's session. An
+// encrypted session is opened with the passphrase from the OS keyring, or
+// else waits for UnlockSession.
+func (c *Client) sessionStorage() (session.Storage, error) {
+	path := c.cfg.SessionPath()
+	encrypted, err := SessionEncrypted(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session: %w", err)
+	}
+	if !encrypted {
+		return &FileSessionStorage{Path: path}, nil
+	}
+
+	if passphrase, err := keyring.Get(keyringService, c.cfg.Account); err == nil {
+		if s, err := OpenEncryptedSession(path, passphrase); err == nil {
+			return s, nil
+		}
+	}
+
+	c.send(NeedPassphraseMsg{})
+	select {
+	case s := <-c.unlocked:
+		return s, nil
+	case <-c.ctx.Done():
+		return nil, c.ctx.Err()
+	}
+}
+
+// UnlockSession opens the encrypted session with passphrase and lets Run
+// continue connecting.
+func (c *Client) UnlockSession(passphrase string) func() interface{} {
+	return func() interface{} {
+		s, err := OpenEncryptedSession(c.cfg.SessionPath(), passphrase)
+		if err != nil {
+			return AuthErrorMsg{Err: err}
+		}
+		select {
+		case c.unlocked <- s:
+		default:
+		}
+		return SessionUnlockedMsg{}
+	}
+}
+
+// SaveSessionPassphrase stores the passphrase of an account's encrypted
+// session in the OS keyring, so startup no longer asks for it.
+func SaveSessionPassphrase(account, passphrase string) error {
+	return keyring.Set(keyringService, account, passphrase)
+}
+
 func (c *Client) SendCode(phone string) func() interface{} {
 	return func() interface{} {
 		sentCode, err := c.client.Auth().SendCode(c.ctx, phone, auth.SendCodeOptions{})
@@ -125,5 +189,6 @@ func (c *Client) afterAuth() interface{} {
 		return AuthErrorMsg{Err: err}
 	}
 	c.selfID = self.ID
+	c.startUpdates()
 	return AuthorizedMsg{}
 }
@@ -0,0 +1,51 @@
+package telegram
+
+import "github.com/gotd/td/telegram/auth/qrlogin"
+
+// Backend is the set of operations the UI needs from a Telegram connection.
+// Each method returns a command that performs the request and yields a
+// result message, so implementations can be plugged into Bubble Tea as-is.
+// *Client is the production implementation; package fake provides an
+// in-memory one for tests.
+type Backend interface {
+	SelfID() int64
+
+	// Authentication
+	UnlockSession(passphrase string) func() interface{}
+	SendCode(phone string) func() interface{}
+	SignIn(phone, code, phoneCodeHash string) func() interface{}
+	Submit2FA(password string) func() interface{}
+	StartQRLogin(loggedIn qrlogin.LoggedIn) func() interface{}
+	LoggedIn() qrlogin.LoggedIn
+
+	// Chats and messages
+	FetchDialogs(folderID int) func() interface{}
+	FetchMoreDialogs(cursor DialogsCursor) func() interface{}
+	FetchFolders() func() interface{}
+	SetChatFolder(chat Chat, folderID int) func() interface{}
+	MarkRead(chat Chat, maxID, stillUnread int) func() interface{}
+	FetchForumTopics(chat Chat) func() interface{}
+	FetchMoreForumTopics(chat Chat, cursor TopicsCursor) func() interface{}
+	FetchHistory(chat Chat) func() interface{}
+	FetchOlderHistory(chat Chat, offsetID int) func() interface{}
+	FetchNewerHistory(chat Chat, afterID int) func() interface{}
+	FetchHistoryAround(chat Chat, msgID int) func() interface{}
+	FetchMessages(chat Chat, ids []int) func() interface{}
+	SendMessage(chat Chat, text string, opts SendOptions) func() interface{}
+	SendFile(chat Chat, path string, opts SendFileOptions) func() interface{}
+	EditMessage(chat Chat, msgID int, text string) func() interface{}
+	DeleteMessages(chat Chat, messageIDs []int, revoke bool) func() interface{}
+	SetTyping(chat Chat) func() interface{}
+	ForwardMessages(fromChat Chat, messageIDs []int, toChat Chat) func() interface{}
+	SearchHistory(chat Chat, query SearchQuery) func() interface{}
+	SearchHistoryMore(chat Chat, cursor SearchCursor) func() interface{}
+	SearchGlobal(query string) func() interface{}
+	SearchGlobalMore(cursor GlobalSearchCursor) func() interface{}
+
+	// Media
+	DownloadPhoto(msgID int, info *MediaInfo) func() interface{}
+	DownloadToFile(msgID int, info *MediaInfo, destPath string) func() interface{}
+	CancelDownload(destPath string) func() interface{}
+}
+
+var _ Backend = (*Client)(nil)
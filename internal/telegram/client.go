@@ -2,11 +2,15 @@ package telegram
 
 import (
 	"context"
+	"fmt"
 	"sync"
+	"time"
 
 	"github.com/gotd/td/telegram"
 	"github.com/gotd/td/telegram/auth/qrlogin"
 	"github.com/gotd/td/telegram/dcs"
+	"github.com/gotd/td/telegram/updates"
+	"github.com/gotd/td/telegram/updates/hook"
 	"github.com/gotd/td/tg"
 
 	tea "github.com/charmbracelet/bubbletea"
@@ -25,15 +29,37 @@ type Client struct {
 	cancel   context.CancelFunc
 	selfID   int64
 	loggedIn qrlogin.LoggedIn
+	updates  *updates.Manager
+
+	downloads   *downloadManager
+	state       *FileStateStorage     // update state, flushed by Stop
+	middlewares []telegram.Middleware // also wrapped around media DC connections
+
+	proxy     *Proxy
+	dialProxy dcs.DialFunc // opens TCP connections, through proxy if any
+
+	connMu    sync.Mutex
+	connState ConnectionState
+	connErr   error
+	pending   int // requests made while not connected, still running
+
+	chatsMu sync.Mutex
+	chats   map[int64]Chat // seen in dialogs, for refetching media
+
+	unlocked chan *EncryptedSessionStorage // passphrase accepted by UnlockSession
 }
 
 func NewClient(cfg *config.Config) *Client {
 	ctx, cancel := context.WithCancel(context.Background())
-	return &Client{
-		cfg:    cfg,
-		ctx:    ctx,
-		cancel: cancel,
+	c := &Client{
+		cfg:      cfg,
+		ctx:      ctx,
+		cancel:   cancel,
+		unlocked: make(chan *EncryptedSessionStorage, 1),
 	}
+	c.downloads = newDownloadManager(c)
+	c.state = &FileStateStorage{Path: cfg.UpdateStatePath()}
+	return c
 }
 
 func (c *Client) SetProgram(p *tea.Program) {
@@ -42,15 +68,27 @@ func (c *Client) SetProgram(p *tea.Program) {
 	c.p = p
 }
 
+// AccountMsg tags a message pushed by a client with the account it
+// belongs to, so a program running several clients can route it.
+type AccountMsg struct {
+	Account string
+	Msg     interface{}
+}
+
 func (c *Client) send(msg tea.Msg) {
 	c.mu.Lock()
 	p := c.p
 	c.mu.Unlock()
 	if p != nil {
-		p.Send(msg)
+		p.Send(AccountMsg{Account: c.cfg.Account, Msg: msg})
 	}
 }
 
+// Account is the name of the profile this client is logged in to.
+func (c *Client) Account() string {
+	return c.cfg.Account
+}
+
 func (c *Client) SelfID() int64 {
 	return c.selfID
 }
@@ -60,14 +98,49 @@ func (c *Client) Run() error {
 	c.setupHandlers(dispatcher)
 	c.loggedIn = qrlogin.OnLoginToken(dispatcher)
 
+	storage, err := c.sessionStorage()
+	if err != nil {
+		return err
+	}
+
+	if c.proxy, err = ParseProxy(c.cfg.Proxy); err != nil {
+		return err
+	}
+	if c.dialProxy, err = c.proxy.dialer(); err != nil {
+		return fmt.Errorf("failed to set up %s: %w", c.proxy, err)
+	}
+	resolver, err := c.proxy.resolver(c.dial)
+	if err != nil {
+		return fmt.Errorf("failed to set up %s: %w", c.proxy, err)
+	}
+
+	c.updates = updates.New(updates.Config{
+		Handler:      dispatcher,
+		Storage:      c.state,
+		AccessHasher: c.state,
+		OnChannelTooLong: func(channelID int64) {
+			c.send(HistoryGapMsg{ChatID: channelID})
+		},
+	})
+
+	c.middlewares = []telegram.Middleware{
+		offlineMiddleware{c: c},
+		newRetryMiddleware(func(wait time.Duration) {
+			c.send(RateLimitedMsg{Wait: wait})
+		}),
+	}
 	c.client = telegram.NewClient(c.cfg.APIId, c.cfg.APIHash, telegram.Options{
-		SessionStorage: &FileSessionStorage{Path: c.cfg.SessionPath()},
-		UpdateHandler:  dispatcher,
+		SessionStorage: storage,
+		UpdateHandler:  c.updates,
+		Middlewares:    append(c.middlewares, hook.UpdateHook(c.updates.Handle)),
 		DCList:         dcs.Prod(),
+		Resolver:       resolver,
+		OnDead:         c.onDead,
 	})
 
 	return c.client.Run(c.ctx, func(ctx context.Context) error {
 		c.api = c.client.API()
+		c.setConnState(StateConnected, nil)
 
 		auth, err := c.client.Auth().Status(ctx)
 		if err != nil {
@@ -80,6 +153,7 @@ func (c *Client) Run() error {
 				return err
 			}
 			c.selfID = self.ID
+			c.startUpdates()
 			c.send(AuthorizedMsg{})
 		} else {
 			c.send(NeedAuthMsg{})
@@ -92,6 +166,8 @@ func (c *Client) Run() error {
 
 func (c *Client) Stop() {
 	c.cancel()
+	// The process exits right after, so write the update state now.
+	c.state.Flush()
 }
 
 func (c *Client) API() *tg.Client {
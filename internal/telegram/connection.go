@@ -0,0 +1,207 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/telegram"
+	"github.com/gotd/td/tg"
+)
+
+const (
+	pingInterval = 30 * time.Second
+	pingTimeout  = 10 * time.Second
+)
+
+// ConnectionState is the state of the connection to Telegram.
+type ConnectionState int
+
+const (
+	StateConnecting ConnectionState = iota
+	StateConnected
+	StateReconnecting
+	StateOffline           // servers unreachable
+	StateWaitingForNetwork // no network at all
+)
+
+func (s ConnectionState) String() string {
+	switch s {
+	case StateConnected:
+		return "Connected"
+	case StateReconnecting:
+		return "Reconnecting..."
+	case StateOffline:
+		return "Offline"
+	case StateWaitingForNetwork:
+		return "Waiting for network..."
+	default:
+		return "Connecting..."
+	}
+}
+
+// ErrOffline marks errors of calls made while the connection was down.
+var ErrOffline = errors.New("offline")
+
+// ConnectionStateMsg reports a change of the connection state or of the
+// number of requests waiting for it. Err is the last connection error,
+// if any.
+type ConnectionStateMsg struct {
+	State   ConnectionState
+	Pending int
+	Err     error
+	Proxy   string // the proxy connections go through, empty when direct
+}
+
+// setConnState records a new state, reporting it if anything changed.
+func (c *Client) setConnState(state ConnectionState, err error) {
+	c.connMu.Lock()
+	changed := c.connState != state || (state != StateConnected && err != nil)
+	c.connState = state
+	if state == StateConnected {
+		err = nil
+	}
+	c.connErr = err
+	msg := ConnectionStateMsg{State: state, Pending: c.pending, Err: err, Proxy: c.via()}
+	c.connMu.Unlock()
+	if changed {
+		c.send(msg)
+	}
+}
+
+func (c *Client) connectionState() ConnectionState {
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
+	return c.connState
+}
+
+// via describes the proxy in use, if any.
+func (c *Client) via() string {
+	if c.proxy == nil || c.proxy.Kind == ProxyNone {
+		return ""
+	}
+	return c.proxy.String()
+}
+
+// addPending counts a request made while offline in or out.
+func (c *Client) addPending(n int) {
+	c.connMu.Lock()
+	c.pending += n
+	msg := ConnectionStateMsg{State: c.connState, Pending: c.pending, Err: c.connErr, Proxy: c.via()}
+	c.connMu.Unlock()
+	c.send(msg)
+}
+
+// dial opens connections to Telegram, tracking their outcome so failures
+// show up right away rather than at the next ping. Errors name the proxy
+// in use, if any, since it is then usually the culprit.
+func (c *Client) dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	conn, err := c.dialProxy(ctx, network, addr)
+	if err != nil {
+		if c.proxy.Kind != ProxyNone {
+			err = fmt.Errorf("%s: %w", c.proxy, err)
+		}
+		if ctx.Err() == nil {
+			if networkDown(err) {
+				c.setConnState(StateWaitingForNetwork, err)
+			} else {
+				c.setConnState(StateOffline, err)
+			}
+		}
+		return nil, err
+	}
+	switch c.connectionState() {
+	case StateOffline, StateWaitingForNetwork, StateReconnecting:
+		c.setConnState(StateReconnecting, nil)
+		go c.confirmConnected()
+	}
+	return conn, nil
+}
+
+// networkDown reports dial errors meaning the machine has no usable
+// network, as opposed to Telegram being unreachable.
+func networkDown(err error) bool {
+	return errors.Is(err, syscall.ENETUNREACH) || errors.Is(err, syscall.ENETDOWN)
+}
+
+// onDead is called by gotd when pings on a connection go unanswered; it
+// reconnects on its own.
+func (c *Client) onDead(err error) {
+	if c.ctx.Err() == nil {
+		c.setConnState(StateReconnecting, err)
+	}
+}
+
+// confirmConnected marks the connection up once a ping gets through.
+func (c *Client) confirmConnected() {
+	ctx, cancel := context.WithTimeout(c.ctx, pingTimeout)
+	defer cancel()
+	if err := c.client.Ping(ctx); err == nil {
+		c.setConnState(StateConnected, nil)
+	}
+}
+
+// watchConnection pings the server periodically. Updates pushed while the
+// connection was down, or while the machine slept, are never resent, so
+// after either the updates manager is told to catch up with getDifference.
+func (c *Client) watchConnection() {
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+
+	// Wall clock: the monotonic clock stops while the machine sleeps.
+	last := time.Now().Round(0)
+	failed := false
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		now := time.Now().Round(0)
+		slept := now.Sub(last) > 2*pingInterval
+		last = now
+
+		ctx, cancel := context.WithTimeout(c.ctx, pingTimeout)
+		err := c.client.Ping(ctx)
+		cancel()
+		if err != nil {
+			failed = true
+			if c.connectionState() == StateConnected {
+				c.setConnState(StateReconnecting, err)
+			}
+			continue
+		}
+		c.setConnState(StateConnected, nil)
+		if failed || slept {
+			failed = false
+			_ = c.updates.Handle(c.ctx, &tg.UpdatesTooLong{})
+		}
+	}
+}
+
+// offlineMiddleware makes requests issued while disconnected visible: they
+// are counted as pending until they complete, and their errors are marked
+// with ErrOffline.
+type offlineMiddleware struct {
+	c *Client
+}
+
+func (m offlineMiddleware) Handle(next tg.Invoker) telegram.InvokeFunc {
+	return func(ctx context.Context, input bin.Encoder, output bin.Decoder) error {
+		if m.c.connectionState() == StateConnected {
+			return next.Invoke(ctx, input, output)
+		}
+		m.c.addPending(1)
+		defer m.c.addPending(-1)
+		err := next.Invoke(ctx, input, output)
+		if err != nil && m.c.connectionState() != StateConnected {
+			return fmt.Errorf("%w: %w", ErrOffline, err)
+		}
+		return err
+	}
+}
@@ -0,0 +1,38 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+)
+
+func TestOfflineMiddlewareMarksErrors(t *testing.T) {
+	c := &Client{ctx: context.Background()}
+	mw := offlineMiddleware{c: c}
+
+	c.setConnState(StateOffline, errors.New("connection refused"))
+	next := &scriptedInvoker{errs: []error{tgerr.New(500, "INTERNAL")}}
+	err := mw.Handle(next).Invoke(context.Background(), &tg.MessagesGetHistoryRequest{}, nil)
+	if !errors.Is(err, ErrOffline) {
+		t.Errorf("expected ErrOffline while offline, got %v", err)
+	}
+	if !tgerr.Is(err, "INTERNAL") {
+		t.Errorf("expected the RPC error to stay visible, got %v", err)
+	}
+	if c.pending != 0 {
+		t.Errorf("pending = %d after the call finished", c.pending)
+	}
+
+	c.setConnState(StateConnected, nil)
+	if c.connErr != nil {
+		t.Errorf("expected the connection error cleared once connected")
+	}
+	next = &scriptedInvoker{errs: []error{tgerr.New(500, "INTERNAL")}}
+	err = mw.Handle(next).Invoke(context.Background(), &tg.MessagesGetHistoryRequest{}, nil)
+	if errors.Is(err, ErrOffline) {
+		t.Errorf("unexpected ErrOffline while connected: %v", err)
+	}
+}
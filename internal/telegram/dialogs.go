@@ -6,76 +6,193 @@ import (
 	"github.com/gotd/td/tg"
 )
 
+// dialogsPageSize is how many dialogs each MessagesGetDialogs call asks for.
+const dialogsPageSize = 100
+
+// DialogsCursor marks where the next page of dialogs starts: the top
+// message and peer of the last dialog of the previous page.
+type DialogsCursor struct {
+	FolderID   int
+	OffsetDate int
+	OffsetID   int
+	OffsetPeer Chat
+}
+
+// DialogsLoadedMsg carries the first page of a peer folder's chat list.
 type DialogsLoadedMsg struct {
-	Chats []Chat
+	FolderID int
+	Chats    []Chat
+	Next     DialogsCursor
+	HasMore  bool
 }
 
 type DialogsErrorMsg struct {
-	Err error
+	FolderID int
+	Err      error
+}
+
+// MoreDialogsLoadedMsg carries a further page of a peer folder's chat list.
+type MoreDialogsLoadedMsg struct {
+	FolderID int
+	Chats    []Chat
+	Next     DialogsCursor
+	HasMore  bool
+}
+
+type MoreDialogsErrorMsg struct {
+	FolderID int
+	Err      error
 }
 
-func (c *Client) FetchDialogs() func() interface{} {
+// InboxReadMsg reports that incoming messages of a chat, or of one of its
+// forum topics, were read up to MaxID, by us or on another device.
+// StillUnread is the chat's remaining count, -1 when unknown.
+type InboxReadMsg struct {
+	ChatID      int64
+	TopicID     int
+	MaxID       int
+	StillUnread int
+}
+
+type ReadErrorMsg struct {
+	ChatID int64
+	Err    error
+}
+
+// FetchDialogs loads the first page of a peer folder: 0 for the main chat
+// list or ArchiveFolderID.
+func (c *Client) FetchDialogs(folderID int) func() interface{} {
 	return func() interface{} {
-		result, err := c.api.MessagesGetDialogs(c.ctx, &tg.MessagesGetDialogsRequest{
-			OffsetPeer: &tg.InputPeerEmpty{},
-			Limit:      100,
-		})
+		chats, next, hasMore, err := c.fetchDialogsPage(DialogsCursor{FolderID: folderID})
 		if err != nil {
-			return DialogsErrorMsg{Err: err}
+			return DialogsErrorMsg{FolderID: folderID, Err: err}
 		}
+		return DialogsLoadedMsg{FolderID: folderID, Chats: OrderChats(chats), Next: next, HasMore: hasMore}
+	}
+}
 
-		var chats []Chat
-
-		switch r := result.(type) {
-		case *tg.MessagesDialogs:
-			chats = c.extractDialogs(r.Dialogs, r.Users, r.Chats, r.Messages)
-		case *tg.MessagesDialogsSlice:
-			chats = c.extractDialogs(r.Dialogs, r.Users, r.Chats, r.Messages)
+// FetchMoreDialogs loads the page of dialogs after cursor.
+func (c *Client) FetchMoreDialogs(cursor DialogsCursor) func() interface{} {
+	return func() interface{} {
+		chats, next, hasMore, err := c.fetchDialogsPage(cursor)
+		if err != nil {
+			return MoreDialogsErrorMsg{FolderID: cursor.FolderID, Err: err}
 		}
+		return MoreDialogsLoadedMsg{FolderID: cursor.FolderID, Chats: chats, Next: next, HasMore: hasMore}
+	}
+}
 
-		// Partition: pinned chats first (preserve server order), then non-pinned by date
-		var pinned, unpinned []Chat
-		for _, c := range chats {
-			if c.Pinned {
-				pinned = append(pinned, c)
-			} else {
-				unpinned = append(unpinned, c)
-			}
+// fetchDialogsPage returns one page of dialogs in server order, the cursor
+// for the page after it and whether there is one.
+func (c *Client) fetchDialogsPage(cursor DialogsCursor) ([]Chat, DialogsCursor, bool, error) {
+	var offsetPeer tg.InputPeerClass = &tg.InputPeerEmpty{}
+	if cursor.OffsetPeer.ID != 0 {
+		offsetPeer = c.chatToInputPeer(cursor.OffsetPeer)
+	}
+
+	req := &tg.MessagesGetDialogsRequest{
+		OffsetDate: cursor.OffsetDate,
+		OffsetID:   cursor.OffsetID,
+		OffsetPeer: offsetPeer,
+		Limit:      dialogsPageSize,
+	}
+	req.SetFolderID(cursor.FolderID)
+	result, err := c.api.MessagesGetDialogs(c.ctx, req)
+	if err != nil {
+		return nil, DialogsCursor{}, false, err
+	}
+
+	var (
+		chats    []Chat
+		dialogs  []tg.DialogClass
+		messages []tg.MessageClass
+		hasMore  bool
+	)
+	switch r := result.(type) {
+	case *tg.MessagesDialogs:
+		chats = c.extractDialogs(r.Dialogs, r.Users, r.Chats, r.Messages)
+		dialogs, messages = r.Dialogs, r.Messages
+	case *tg.MessagesDialogsSlice:
+		chats = c.extractDialogs(r.Dialogs, r.Users, r.Chats, r.Messages)
+		dialogs, messages = r.Dialogs, r.Messages
+		// A short page means the server has nothing more, whatever Count says.
+		hasMore = len(r.Dialogs) == dialogsPageSize
+	}
+
+	next, ok := nextDialogsCursor(chats, dialogs, messages)
+	next.FolderID = cursor.FolderID
+	return chats, next, hasMore && ok, nil
+}
+
+// nextDialogsCursor builds the cursor after the last dialog of a page.
+func nextDialogsCursor(chats []Chat, dialogs []tg.DialogClass, messages []tg.MessageClass) (DialogsCursor, bool) {
+	for i := len(dialogs) - 1; i >= 0; i-- {
+		dialog, ok := dialogs[i].(*tg.Dialog)
+		if !ok {
+			continue
 		}
-		sort.Slice(unpinned, func(i, j int) bool {
-			var di, dj int
-			if unpinned[i].LastMessage != nil {
-				di = unpinned[i].LastMessage.Date
+		peerID := extractChatID(dialog.Peer)
+		for _, chat := range chats {
+			if chat.ID != peerID {
+				continue
 			}
-			if unpinned[j].LastMessage != nil {
-				dj = unpinned[j].LastMessage.Date
+			for _, m := range messages {
+				if m.GetID() == dialog.TopMessage && extractChatID(messagePeer(m)) == peerID {
+					return DialogsCursor{OffsetDate: messageDate(m), OffsetID: dialog.TopMessage, OffsetPeer: chat}, true
+				}
 			}
-			return di > dj
-		})
-		chats = append(pinned, unpinned...)
+		}
+	}
+	return DialogsCursor{}, false
+}
 
-		return DialogsLoadedMsg{Chats: chats}
+func messagePeer(m tg.MessageClass) tg.PeerClass {
+	switch v := m.(type) {
+	case *tg.Message:
+		return v.PeerID
+	case *tg.MessageService:
+		return v.PeerID
 	}
+	return nil
 }
 
-func (c *Client) extractDialogs(dialogs []tg.DialogClass, users []tg.UserClass, chatClasses []tg.ChatClass, messages []tg.MessageClass) []Chat {
-	userMap := make(map[int64]*tg.User)
-	for _, u := range users {
-		if user, ok := u.(*tg.User); ok {
-			userMap[user.ID] = user
-		}
+func messageDate(m tg.MessageClass) int {
+	switch v := m.(type) {
+	case *tg.Message:
+		return v.Date
+	case *tg.MessageService:
+		return v.Date
 	}
+	return 0
+}
 
-	chatMap := make(map[int64]*tg.Chat)
-	channelMap := make(map[int64]*tg.Channel)
-	for _, ch := range chatClasses {
-		switch v := ch.(type) {
-		case *tg.Chat:
-			chatMap[v.ID] = v
-		case *tg.Channel:
-			channelMap[v.ID] = v
+// OrderChats sorts a chat list the way the mobile apps do: pinned chats
+// first in their server order, then the rest by latest message.
+func OrderChats(chats []Chat) []Chat {
+	var pinned, unpinned []Chat
+	for _, c := range chats {
+		if c.Pinned {
+			pinned = append(pinned, c)
+		} else {
+			unpinned = append(unpinned, c)
 		}
 	}
+	sort.SliceStable(unpinned, func(i, j int) bool {
+		var di, dj int
+		if unpinned[i].LastMessage != nil {
+			di = unpinned[i].LastMessage.Date
+		}
+		if unpinned[j].LastMessage != nil {
+			dj = unpinned[j].LastMessage.Date
+		}
+		return di > dj
+	})
+	return append(pinned, unpinned...)
+}
+
+func (c *Client) extractDialogs(dialogs []tg.DialogClass, users []tg.UserClass, chatClasses []tg.ChatClass, messages []tg.MessageClass) []Chat {
+	userMap := usersByID(users)
+	chatMap, channelMap := chatsByID(chatClasses)
 
 	msgMap := make(map[int]tg.MessageClass)
 	for _, m := range messages {
@@ -94,44 +211,16 @@ func (c *Client) extractDialogs(dialogs []tg.DialogClass, users []tg.UserClass,
 			continue
 		}
 
-		chat := Chat{
-			UnreadCount: dialog.UnreadCount,
-			Pinned:      dialog.Pinned,
+		chat, ok := peerChat(dialog.Peer, userMap, chatMap, channelMap)
+		if !ok {
+			continue
 		}
-
-		switch peer := dialog.Peer.(type) {
-		case *tg.PeerUser:
-			user, exists := userMap[peer.UserID]
-			if !exists {
-				continue
-			}
-			chat.ID = user.ID
-			chat.AccessHash = user.AccessHash
-			chat.Title = displayName(user.FirstName, user.LastName)
-			chat.Type = ChatTypePrivate
-
-		case *tg.PeerChat:
-			group, exists := chatMap[peer.ChatID]
-			if !exists {
-				continue
-			}
-			chat.ID = group.ID
-			chat.Title = group.Title
-			chat.Type = ChatTypeGroup
-
-		case *tg.PeerChannel:
-			channel, exists := channelMap[peer.ChannelID]
-			if !exists {
-				continue
-			}
-			chat.ID = channel.ID
-			chat.AccessHash = channel.AccessHash
-			chat.Title = channel.Title
-			if channel.Broadcast {
-				chat.Type = ChatTypeChannel
-			} else {
-				chat.Type = ChatTypeGroup
-			}
+		chat.UnreadCount = dialog.UnreadCount
+		chat.ReadInboxMaxID = dialog.ReadInboxMaxID
+		chat.Pinned = dialog.Pinned
+		chat.MutedUntil = dialog.NotifySettings.MuteUntil
+		if folderID, ok := dialog.GetFolderID(); ok {
+			chat.FolderID = folderID
 		}
 
 		if dialog.TopMessage != 0 {
@@ -161,8 +250,133 @@ func (c *Client) extractDialogs(dialogs []tg.DialogClass, users []tg.UserClass,
 			}
 		}
 
+		c.rememberChat(chat)
 		chats = append(chats, chat)
 	}
 
 	return chats
 }
+
+func usersByID(users []tg.UserClass) map[int64]*tg.User {
+	userMap := make(map[int64]*tg.User)
+	for _, u := range users {
+		if user, ok := u.(*tg.User); ok {
+			userMap[user.ID] = user
+		}
+	}
+	return userMap
+}
+
+func chatsByID(chatClasses []tg.ChatClass) (map[int64]*tg.Chat, map[int64]*tg.Channel) {
+	chatMap := make(map[int64]*tg.Chat)
+	channelMap := make(map[int64]*tg.Channel)
+	for _, ch := range chatClasses {
+		switch v := ch.(type) {
+		case *tg.Chat:
+			chatMap[v.ID] = v
+		case *tg.Channel:
+			channelMap[v.ID] = v
+		}
+	}
+	return chatMap, channelMap
+}
+
+// peerChat builds the chat a peer refers to from the users and chats sent
+// along with it. Dialog state (unread count, pinning) is left unset.
+func peerChat(peer tg.PeerClass, users map[int64]*tg.User, chats map[int64]*tg.Chat, channels map[int64]*tg.Channel) (Chat, bool) {
+	var chat Chat
+	switch peer := peer.(type) {
+	case *tg.PeerUser:
+		user, exists := users[peer.UserID]
+		if !exists {
+			return chat, false
+		}
+		chat.ID = user.ID
+		chat.AccessHash = user.AccessHash
+		chat.Title = displayName(user.FirstName, user.LastName)
+		chat.Type = ChatTypePrivate
+		chat.Contact = user.Contact
+		chat.Bot = user.Bot
+		if !user.Bot && !user.Self {
+			chat.Presence = convertUserStatus(user.Status)
+		}
+
+	case *tg.PeerChat:
+		group, exists := chats[peer.ChatID]
+		if !exists {
+			return chat, false
+		}
+		chat.ID = group.ID
+		chat.Title = group.Title
+		chat.Type = ChatTypeGroup
+
+	case *tg.PeerChannel:
+		channel, exists := channels[peer.ChannelID]
+		if !exists {
+			return chat, false
+		}
+		chat.ID = channel.ID
+		chat.AccessHash = channel.AccessHash
+		chat.Title = channel.Title
+		if channel.Broadcast {
+			chat.Type = ChatTypeChannel
+		} else {
+			chat.Type = ChatTypeGroup
+			chat.Forum = channel.Forum
+		}
+
+	default:
+		return chat, false
+	}
+	return chat, true
+}
+
+// MarkRead acknowledges incoming messages up to maxID. stillUnread is the
+// caller's count of incoming messages after maxID, or -1 when it cannot
+// tell (a forum topic, or history not loaded up to the latest message);
+// the chat's remaining unread count is then fetched from the server.
+func (c *Client) MarkRead(chat Chat, maxID, stillUnread int) func() interface{} {
+	return func() interface{} {
+		peer := c.chatToInputPeer(chat)
+
+		var err error
+		switch p := peer.(type) {
+		case *tg.InputPeerChannel:
+			if chat.TopicID != 0 {
+				_, err = c.api.MessagesReadDiscussion(c.ctx, &tg.MessagesReadDiscussionRequest{
+					Peer:      peer,
+					MsgID:     chat.TopicID,
+					ReadMaxID: maxID,
+				})
+				break
+			}
+			_, err = c.api.ChannelsReadHistory(c.ctx, &tg.ChannelsReadHistoryRequest{
+				Channel: &tg.InputChannel{ChannelID: p.ChannelID, AccessHash: p.AccessHash},
+				MaxID:   maxID,
+			})
+		default:
+			_, err = c.api.MessagesReadHistory(c.ctx, &tg.MessagesReadHistoryRequest{
+				Peer:  peer,
+				MaxID: maxID,
+			})
+		}
+		if err != nil {
+			return ReadErrorMsg{ChatID: chat.ID, Err: err}
+		}
+
+		if stillUnread < 0 {
+			dialogs, err := c.api.MessagesGetPeerDialogs(c.ctx, []tg.InputDialogPeerClass{
+				&tg.InputDialogPeer{Peer: peer},
+			})
+			if err == nil {
+				for _, d := range dialogs.Dialogs {
+					if dialog, ok := d.(*tg.Dialog); ok {
+						stillUnread = dialog.UnreadCount
+					}
+				}
+			}
+		}
+
+		return InboxReadMsg{ChatID: chat.ID, TopicID: chat.TopicID, MaxID: maxID, StillUnread: stillUnread}
+	}
+}
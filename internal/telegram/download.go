@@ -0,0 +1,355 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gotd/td/tg"
+)
+
+const (
+	// downloadChunkSize is the upload.getFile limit; offsets stay aligned
+	// to it so a .part file can always be resumed.
+	downloadChunkSize = 1024 * 1024
+	// maxActiveDownloads is how many transfers run at once; the rest wait.
+	maxActiveDownloads = 2
+	// parallelThreshold is the size above which chunks of one file are
+	// fetched in parallel, downloadThreads at a time.
+	parallelThreshold = 8 * downloadChunkSize
+	downloadThreads   = 4
+	// progressInterval throttles DownloadProgressMsg.
+	progressInterval = 250 * time.Millisecond
+)
+
+// DownloadQueuedMsg reports that a download was accepted. Position is its
+// place in the queue, 0 when it started right away.
+type DownloadQueuedMsg struct {
+	MessageID int
+	Path      string
+	Position  int
+}
+
+// DownloadProgressMsg reports bytes written so far; Total is 0 when the
+// size is not known up front.
+type DownloadProgressMsg struct {
+	MessageID int
+	Path      string
+	Done      int64
+	Total     int64
+}
+
+// DownloadCancelledMsg reports a download stopped by CancelDownload. Its
+// .part file is kept, so downloading to the same path resumes it.
+type DownloadCancelledMsg struct {
+	MessageID int
+	Path      string
+}
+
+type transfer struct {
+	msgID  int
+	info   *MediaInfo
+	path   string
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// downloadManager queues file downloads and runs a few of them at a time.
+// Results are delivered to the program as SaveFileMsg, SaveFileErrorMsg or
+// DownloadCancelledMsg.
+type downloadManager struct {
+	c *Client
+
+	mu     sync.Mutex
+	queue  []*transfer
+	active map[string]*transfer // by destination path
+	dcs    map[int]*dcConn      // media DC connections, by DC ID
+}
+
+// dcConn is a media DC connection, dialled once by whichever download
+// needs it first. api stays nil if dialling failed.
+type dcConn struct {
+	once sync.Once
+	api  *tg.Client
+}
+
+func newDownloadManager(c *Client) *downloadManager {
+	return &downloadManager{
+		c:      c,
+		active: make(map[string]*transfer),
+		dcs:    make(map[int]*dcConn),
+	}
+}
+
+// DownloadToFile queues a media file (photo or document) for saving to
+// destPath. An existing destPath+".part" from an earlier attempt is resumed.
+func (c *Client) DownloadToFile(msgID int, info *MediaInfo, destPath string) func() interface{} {
+	return func() interface{} {
+		if info == nil {
+			return SaveFileErrorMsg{MessageID: msgID, Err: fmt.Errorf("no media info")}
+		}
+		return c.downloads.enqueue(msgID, info, destPath)
+	}
+}
+
+// CancelDownload stops a queued or running download.
+func (c *Client) CancelDownload(destPath string) func() interface{} {
+	return func() interface{} {
+		return c.downloads.cancel(destPath)
+	}
+}
+
+func (d *downloadManager) enqueue(msgID int, info *MediaInfo, path string) interface{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, running := d.active[path]; running {
+		return SaveFileErrorMsg{MessageID: msgID, Err: fmt.Errorf("already downloading to %s", path)}
+	}
+	for _, t := range d.queue {
+		if t.path == path {
+			return SaveFileErrorMsg{MessageID: msgID, Err: fmt.Errorf("already queued to download to %s", path)}
+		}
+	}
+	ctx, cancel := context.WithCancel(d.c.ctx)
+	t := &transfer{msgID: msgID, info: info, path: path, ctx: ctx, cancel: cancel}
+	if len(d.active) < maxActiveDownloads {
+		d.startLocked(t)
+		return DownloadQueuedMsg{MessageID: msgID, Path: path}
+	}
+	d.queue = append(d.queue, t)
+	return DownloadQueuedMsg{MessageID: msgID, Path: path, Position: len(d.queue)}
+}
+
+func (d *downloadManager) cancel(path string) interface{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i, t := range d.queue {
+		if t.path == path {
+			d.queue = append(d.queue[:i], d.queue[i+1:]...)
+			t.cancel()
+			return DownloadCancelledMsg{MessageID: t.msgID, Path: path}
+		}
+	}
+	if t, ok := d.active[path]; ok {
+		// The transfer reports DownloadCancelledMsg once it stops.
+		t.cancel()
+	}
+	return nil
+}
+
+func (d *downloadManager) startLocked(t *transfer) {
+	d.active[t.path] = t
+	go func() {
+		result := d.run(t)
+		t.cancel()
+		d.c.send(result)
+
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		delete(d.active, t.path)
+		if len(d.queue) > 0 && len(d.active) < maxActiveDownloads {
+			next := d.queue[0]
+			d.queue = d.queue[1:]
+			d.startLocked(next)
+		}
+	}()
+}
+
+// run downloads one file into path+".part", renaming it into place once
+// complete.
+func (d *downloadManager) run(t *transfer) interface{} {
+	fail := func(err error) interface{} {
+		if errors.Is(err, context.Canceled) && d.c.ctx.Err() == nil {
+			return DownloadCancelledMsg{MessageID: t.msgID, Path: t.path}
+		}
+		return SaveFileErrorMsg{MessageID: t.msgID, Err: err}
+	}
+
+	loc, dcID, err := fileLocation(t.info)
+	if err != nil {
+		return fail(err)
+	}
+	api := d.dcClient(dcID)
+
+	partPath := t.path + ".part"
+	f, err := os.OpenFile(partPath, os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fail(err)
+	}
+	defer f.Close()
+
+	// Resume after the last whole chunk; anything past it is refetched.
+	stat, err := f.Stat()
+	if err != nil {
+		return fail(err)
+	}
+	offset := stat.Size() - stat.Size()%downloadChunkSize
+	if err := f.Truncate(offset); err != nil {
+		return fail(err)
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return fail(err)
+	}
+
+	total := t.info.FileSize
+	threads := 1
+	if total > parallelThreshold {
+		threads = downloadThreads
+	}
+
+	var lastReport time.Time
+	refreshed := false
+	for {
+		if err := t.ctx.Err(); err != nil {
+			return fail(err)
+		}
+		chunks, err := fetchChunks(t.ctx, api, loc, offset, threads)
+		if err != nil && fileReferenceExpired(err) && !refreshed {
+			// Retry once with a reference from the refetched message.
+			refreshed = true
+			info, err := d.c.refreshMedia(t.msgID, t.info)
+			if err != nil {
+				return fail(err)
+			}
+			if loc, dcID, err = fileLocation(info); err != nil {
+				return fail(err)
+			}
+			api = d.dcClient(dcID)
+			t.info = info
+			continue
+		}
+		if err != nil {
+			return fail(err)
+		}
+		done := false
+		for _, chunk := range chunks {
+			if len(chunk) > 0 {
+				if _, err := f.Write(chunk); err != nil {
+					return fail(err)
+				}
+				offset += int64(len(chunk))
+			}
+			if len(chunk) < downloadChunkSize {
+				done = true
+				break
+			}
+		}
+		if done {
+			break
+		}
+		if time.Since(lastReport) >= progressInterval {
+			lastReport = time.Now()
+			d.c.send(DownloadProgressMsg{MessageID: t.msgID, Path: t.path, Done: offset, Total: total})
+		}
+	}
+
+	if err := f.Close(); err != nil {
+		return fail(err)
+	}
+	if err := os.Rename(partPath, t.path); err != nil {
+		return fail(err)
+	}
+	return SaveFileMsg{MessageID: t.msgID, Path: t.path}
+}
+
+// fetchChunks requests n consecutive chunks starting at offset in
+// parallel and returns them in order. Chunks past the end come back empty.
+func fetchChunks(ctx context.Context, api *tg.Client, loc tg.InputFileLocationClass, offset int64, n int) ([][]byte, error) {
+	chunks := make([][]byte, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			result, err := api.UploadGetFile(ctx, &tg.UploadGetFileRequest{
+				Location: loc,
+				Offset:   offset + int64(i)*downloadChunkSize,
+				Limit:    downloadChunkSize,
+			})
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			file, ok := result.(*tg.UploadFile)
+			if !ok {
+				errs[i] = fmt.Errorf("unexpected upload response")
+				return
+			}
+			chunks[i] = file.Bytes
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return chunks, nil
+}
+
+// fileLocation returns where to fetch a media file from and the DC that
+// stores it.
+func fileLocation(info *MediaInfo) (tg.InputFileLocationClass, int, error) {
+	switch info.Type {
+	case MediaPhoto:
+		if info.PhotoThumbSize == "" {
+			return nil, 0, fmt.Errorf("no photo size available")
+		}
+		return &tg.InputPhotoFileLocation{
+			ID:            info.PhotoID,
+			AccessHash:    info.PhotoAccessHash,
+			FileReference: info.PhotoFileRef,
+			ThumbSize:     info.PhotoThumbSize,
+		}, info.PhotoDCID, nil
+	default:
+		if info.DocID == 0 {
+			return nil, 0, fmt.Errorf("no document info available")
+		}
+		return &tg.InputDocumentFileLocation{
+			ID:            info.DocID,
+			AccessHash:    info.DocAccessHash,
+			FileReference: info.DocFileRef,
+			ThumbSize:     "", // full file
+		}, info.DocDCID, nil
+	}
+}
+
+// dcClient returns an API client for the DC holding a file, connecting to
+// it on first use. Files on our own DC, or on a DC we cannot reach, go
+// through the main connection. The dial happens outside d.mu so it does
+// not hold up queueing and cancelling.
+func (d *downloadManager) dcClient(dcID int) *tg.Client {
+	if dcID == 0 || d.c.client == nil || dcID == d.c.client.Config().ThisDC {
+		return d.c.api
+	}
+	d.mu.Lock()
+	conn, ok := d.dcs[dcID]
+	if !ok {
+		conn = &dcConn{}
+		d.dcs[dcID] = conn
+	}
+	d.mu.Unlock()
+
+	conn.once.Do(func() {
+		invoker, err := d.c.client.DC(d.c.ctx, dcID, downloadThreads)
+		if err != nil {
+			// Forget the failed attempt so a later download dials again.
+			d.mu.Lock()
+			if d.dcs[dcID] == conn {
+				delete(d.dcs, dcID)
+			}
+			d.mu.Unlock()
+			return
+		}
+		conn.api = tg.NewClient(chainMiddlewares(invoker, d.c.middlewares...))
+	})
+	if conn.api == nil {
+		return d.c.api
+	}
+	return conn.api
+}
@@ -0,0 +1,168 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+)
+
+// fileServer answers upload.getFile from an in-memory file. When ref is
+// set, requests with another file reference fail as expired and
+// messages.getMessages returns message 5 carrying the current one.
+type fileServer struct {
+	data []byte
+	ref  []byte
+
+	mu      sync.Mutex
+	offsets []int64
+}
+
+func (s *fileServer) Invoke(ctx context.Context, input bin.Encoder, output bin.Decoder) error {
+	if _, ok := input.(*tg.MessagesGetMessagesRequest); ok {
+		return s.encode(&tg.MessagesMessages{Messages: []tg.MessageClass{&tg.Message{
+			ID:     5,
+			PeerID: &tg.PeerUser{UserID: 42},
+			Media: &tg.MessageMediaDocument{Document: &tg.Document{
+				ID:            1,
+				FileReference: s.ref,
+				Size:          int64(len(s.data)),
+			}},
+		}}}, output)
+	}
+
+	req := input.(*tg.UploadGetFileRequest)
+	if loc, ok := req.Location.(*tg.InputDocumentFileLocation); ok && s.ref != nil && !bytes.Equal(loc.FileReference, s.ref) {
+		return tgerr.New(400, "FILE_REFERENCE_EXPIRED")
+	}
+	s.mu.Lock()
+	s.offsets = append(s.offsets, req.Offset)
+	s.mu.Unlock()
+
+	var chunk []byte
+	if req.Offset < int64(len(s.data)) {
+		end := req.Offset + int64(req.Limit)
+		if end > int64(len(s.data)) {
+			end = int64(len(s.data))
+		}
+		chunk = s.data[req.Offset:end]
+	}
+	return s.encode(&tg.UploadFile{Type: &tg.StorageFileUnknown{}, Bytes: chunk}, output)
+}
+
+func (s *fileServer) encode(result bin.Encoder, output bin.Decoder) error {
+	var b bin.Buffer
+	if err := result.Encode(&b); err != nil {
+		return err
+	}
+	return output.Decode(&b)
+}
+
+func TestDownloadResumesPartFile(t *testing.T) {
+	data := make([]byte, 10*downloadChunkSize+123)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	srv := &fileServer{data: data}
+	c := &Client{ctx: context.Background(), api: tg.NewClient(srv)}
+	d := newDownloadManager(c)
+
+	dest := filepath.Join(t.TempDir(), "video.mp4")
+	// An earlier attempt got a chunk and a half in.
+	if err := os.WriteFile(dest+".part", data[:downloadChunkSize*3/2], 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	info := &MediaInfo{Type: MediaVideo, DocID: 1, FileSize: int64(len(data))}
+	result := d.run(&transfer{msgID: 5, info: info, path: dest, ctx: context.Background()})
+	if saved, ok := result.(SaveFileMsg); !ok || saved.Path != dest {
+		t.Fatalf("expected SaveFileMsg, got %#v", result)
+	}
+
+	got, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("downloaded file differs from source (%d vs %d bytes)", len(got), len(data))
+	}
+	if _, err := os.Stat(dest + ".part"); !os.IsNotExist(err) {
+		t.Errorf("expected .part file renamed away, stat err = %v", err)
+	}
+	for _, off := range srv.offsets {
+		if off < downloadChunkSize {
+			t.Errorf("expected resume from the second chunk, fetched offset %d", off)
+		}
+	}
+}
+
+func TestDownloadCancelKeepsPartFile(t *testing.T) {
+	srv := &fileServer{data: make([]byte, 3*downloadChunkSize)}
+	c := &Client{ctx: context.Background(), api: tg.NewClient(srv)}
+	d := newDownloadManager(c)
+
+	dest := filepath.Join(t.TempDir(), "doc.pdf")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	info := &MediaInfo{Type: MediaDocument, DocID: 1, FileSize: int64(len(srv.data))}
+	result := d.run(&transfer{msgID: 5, info: info, path: dest, ctx: ctx, cancel: cancel})
+	if _, ok := result.(DownloadCancelledMsg); !ok {
+		t.Fatalf("expected DownloadCancelledMsg, got %#v", result)
+	}
+	if _, err := os.Stat(dest + ".part"); err != nil {
+		t.Errorf("expected .part file kept for resuming: %v", err)
+	}
+}
+
+func TestDownloadRejectsQueuedPath(t *testing.T) {
+	c := &Client{ctx: context.Background()}
+	d := newDownloadManager(c)
+	// Every slot is taken, so new downloads wait in the queue.
+	for i := 0; i < maxActiveDownloads; i++ {
+		d.active[fmt.Sprint("busy", i)] = &transfer{}
+	}
+
+	info := &MediaInfo{Type: MediaDocument, DocID: 1}
+	if queued, ok := d.enqueue(5, info, "/tmp/doc.pdf").(DownloadQueuedMsg); !ok || queued.Position != 1 {
+		t.Fatalf("expected the download queued, got %#v", queued)
+	}
+	if _, ok := d.enqueue(5, info, "/tmp/doc.pdf").(SaveFileErrorMsg); !ok {
+		t.Error("expected a second download to the queued path to be rejected")
+	}
+	if len(d.queue) != 1 {
+		t.Errorf("expected one queued transfer, got %d", len(d.queue))
+	}
+}
+
+func TestDownloadRefreshesExpiredFileReference(t *testing.T) {
+	data := bytes.Repeat([]byte("tgtui"), 1000)
+	srv := &fileServer{data: data, ref: []byte("fresh")}
+	c := &Client{ctx: context.Background(), api: tg.NewClient(srv)}
+	c.rememberChat(Chat{ID: 42, Type: ChatTypePrivate})
+	d := newDownloadManager(c)
+
+	dest := filepath.Join(t.TempDir(), "notes.txt")
+	info := &MediaInfo{Type: MediaDocument, DocID: 1, DocFileRef: []byte("stale"), ChatID: 42, FileSize: int64(len(data))}
+	result := d.run(&transfer{msgID: 5, info: info, path: dest, ctx: context.Background()})
+	if _, ok := result.(SaveFileMsg); !ok {
+		t.Fatalf("expected SaveFileMsg after refreshing the reference, got %#v", result)
+	}
+	if got, _ := os.ReadFile(dest); !bytes.Equal(got, data) {
+		t.Errorf("downloaded file differs from source")
+	}
+
+	// A reference that is still rejected after one refresh is reported.
+	srv.ref = []byte("rotated")
+	info = &MediaInfo{Type: MediaDocument, DocID: 2, DocFileRef: []byte("stale"), ChatID: 42}
+	result = d.run(&transfer{msgID: 5, info: info, path: dest + ".2", ctx: context.Background()})
+	if _, ok := result.(SaveFileErrorMsg); !ok {
+		t.Fatalf("expected SaveFileErrorMsg for a replaced file, got %#v", result)
+	}
+}
@@ -0,0 +1,998 @@
+// Package fake provides an in-memory telegram.Backend for driving the UI
+// without a network connection. Every piece of server state (dialogs,
+// history, media, login flow, failures) is scripted by the test.
+package fake
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gotd/td/telegram/auth/qrlogin"
+	"github.com/paramon-tech/tgtui/internal/telegram"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ErrNotFound is returned for media that has not been scripted.
+var ErrNotFound = errors.New("fake: not found")
+
+const pageSize = 50
+
+type Backend struct {
+	mu sync.Mutex
+
+	selfID      int64
+	selfName    string
+	dialogs     []telegram.Chat
+	dialogsPage int
+	folders     []telegram.Folder
+	topics      map[int64][]telegram.Topic
+	topicsPage  int
+	history     map[int64][]telegram.Message
+	photos      map[int][]byte
+	files       map[int][]byte
+	errs        map[string]error
+	nextID      int
+
+	typing map[int64]int // chatID → SetTyping calls
+
+	loginCode  string
+	password   string
+	passphrase string
+	loggedIn   chan struct{}
+
+	p *tea.Program
+}
+
+var _ telegram.Backend = (*Backend)(nil)
+
+// New returns an empty backend logged in as selfID.
+func New(selfID int64) *Backend {
+	return &Backend{
+		selfID:      selfID,
+		selfName:    "Me",
+		history:     make(map[int64][]telegram.Message),
+		topics:      make(map[int64][]telegram.Topic),
+		photos:      make(map[int][]byte),
+		files:       make(map[int][]byte),
+		errs:        make(map[string]error),
+		typing:      make(map[int64]int),
+		nextID:      1,
+		dialogsPage: 100,
+		topicsPage:  100,
+		loggedIn:    make(chan struct{}, 1),
+	}
+}
+
+// Scripting
+
+// SetProgram makes Emit and Receive deliver messages to p, mirroring
+// telegram.Client.SetProgram.
+func (b *Backend) SetProgram(p *tea.Program) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.p = p
+}
+
+// SetDialogs replaces the chat list returned by FetchDialogs.
+func (b *Backend) SetDialogs(chats ...telegram.Chat) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.dialogs = append([]telegram.Chat(nil), chats...)
+}
+
+// SetFolders replaces the chat folders returned by FetchFolders.
+func (b *Backend) SetFolders(folders ...telegram.Folder) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.folders = append([]telegram.Folder(nil), folders...)
+}
+
+// SetTopics replaces the forum topics of a chat.
+func (b *Backend) SetTopics(chatID int64, topics ...telegram.Topic) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.topics[chatID] = append([]telegram.Topic(nil), topics...)
+}
+
+// SetTopicsPageSize sets how many topics FetchForumTopics and
+// FetchMoreForumTopics return per page.
+func (b *Backend) SetTopicsPageSize(n int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.topicsPage = n
+}
+
+// SetDialogsPageSize sets how many chats FetchDialogs and FetchMoreDialogs
+// return per page.
+func (b *Backend) SetDialogsPageSize(n int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.dialogsPage = n
+}
+
+// SetHistory replaces the history of a chat. Messages must be in
+// chronological order; ChatID is filled in when zero.
+func (b *Backend) SetHistory(chatID int64, msgs ...telegram.Message) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	h := make([]telegram.Message, len(msgs))
+	for i, m := range msgs {
+		if m.ChatID == 0 {
+			m.ChatID = chatID
+		}
+		if m.ID >= b.nextID {
+			b.nextID = m.ID + 1
+		}
+		h[i] = m
+	}
+	b.history[chatID] = h
+}
+
+// History returns a copy of the current history of a chat.
+func (b *Backend) History(chatID int64) []telegram.Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]telegram.Message(nil), b.history[chatID]...)
+}
+
+// TypingSent returns how many times SetTyping was called for a chat.
+func (b *Backend) TypingSent(chatID int64) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.typing[chatID]
+}
+
+// SetPhoto scripts the bytes returned by DownloadPhoto for a message.
+func (b *Backend) SetPhoto(msgID int, data []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.photos[msgID] = data
+}
+
+// SetFile scripts the bytes written by DownloadToFile for a message.
+func (b *Backend) SetFile(msgID int, data []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.files[msgID] = data
+}
+
+// Fail makes every call to the named Backend method (e.g. "SendMessage")
+// fail with err until Fail is called again with a nil error.
+func (b *Backend) Fail(method string, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil {
+		delete(b.errs, method)
+		return
+	}
+	b.errs[method] = err
+}
+
+// SetLoginCode sets the code SignIn accepts. An empty code accepts anything.
+func (b *Backend) SetLoginCode(code string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.loginCode = code
+}
+
+// SetPassword enables 2FA with the given password.
+func (b *Backend) SetPassword(password string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.password = password
+}
+
+// SetPassphrase sets the passphrase UnlockSession accepts.
+func (b *Backend) SetPassphrase(passphrase string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.passphrase = passphrase
+}
+
+// ScanQR completes a pending StartQRLogin as if the token had been
+// accepted on another device.
+func (b *Backend) ScanQR() {
+	select {
+	case b.loggedIn <- struct{}{}:
+	default:
+	}
+}
+
+// Emit delivers an arbitrary update to the attached program.
+func (b *Backend) Emit(msg tea.Msg) {
+	b.mu.Lock()
+	p := b.p
+	b.mu.Unlock()
+	if p != nil {
+		p.Send(msg)
+	}
+}
+
+// EditRemote changes a message as if it had been edited on another device
+// and emits the corresponding MessageEditedMsg.
+func (b *Backend) EditRemote(chatID int64, msgID int, text string) telegram.MessageEditedMsg {
+	b.mu.Lock()
+	var edited telegram.Message
+	for i, m := range b.history[chatID] {
+		if m.ID == msgID {
+			m.Text = text
+			m.Entities = nil
+			m.EditDate = int(time.Now().Unix())
+			b.history[chatID][i] = m
+			edited = m
+			break
+		}
+	}
+	b.mu.Unlock()
+
+	update := telegram.MessageEditedMsg{Message: edited}
+	b.Emit(update)
+	return update
+}
+
+// DeleteRemote removes messages as if they had been deleted on another
+// device and emits the corresponding MessagesDeletedMsg. Like the real
+// server, the update only names the chat for channels and supergroups.
+func (b *Backend) DeleteRemote(chat telegram.Chat, messageIDs ...int) telegram.MessagesDeletedMsg {
+	b.mu.Lock()
+	b.history[chat.ID] = without(b.history[chat.ID], messageIDs)
+	b.mu.Unlock()
+
+	update := telegram.MessagesDeletedMsg{IDs: messageIDs}
+	if chat.IsChannelPeer() {
+		update.ChatID = chat.ID
+	}
+	b.Emit(update)
+	return update
+}
+
+// ReadRemote marks a chat read up to maxID as if on another device and
+// emits the corresponding InboxReadMsg.
+func (b *Backend) ReadRemote(chatID int64, maxID int) telegram.InboxReadMsg {
+	b.mu.Lock()
+	update := telegram.InboxReadMsg{ChatID: chatID, MaxID: maxID, StillUnread: b.unreadAfter(chatID, maxID)}
+	b.mu.Unlock()
+	b.Emit(update)
+	return update
+}
+
+// Receive appends an incoming message to its chat's history and emits
+// the corresponding NewMessageMsg. The message is also returned so tests
+// without a program can feed it to a model directly.
+func (b *Backend) Receive(msg telegram.Message) telegram.NewMessageMsg {
+	b.mu.Lock()
+	if msg.ID == 0 {
+		msg.ID = b.nextID
+	}
+	if msg.ID >= b.nextID {
+		b.nextID = msg.ID + 1
+	}
+	if msg.Date == 0 {
+		msg.Date = int(time.Now().Unix())
+	}
+	b.history[msg.ChatID] = append(b.history[msg.ChatID], msg)
+	b.mu.Unlock()
+
+	update := telegram.NewMessageMsg{Message: msg}
+	b.Emit(update)
+	return update
+}
+
+func (b *Backend) err(method string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.errs[method]
+}
+
+// telegram.Backend implementation
+
+func (b *Backend) SelfID() int64 {
+	return b.selfID
+}
+
+func (b *Backend) SendCode(phone string) func() interface{} {
+	return func() interface{} {
+		if err := b.err("SendCode"); err != nil {
+			return telegram.AuthErrorMsg{Err: err}
+		}
+		return telegram.CodeSentMsg{PhoneCodeHash: "fake-hash:" + phone, CodeType: "Telegram app"}
+	}
+}
+
+func (b *Backend) SignIn(phone, code, phoneCodeHash string) func() interface{} {
+	return func() interface{} {
+		if err := b.err("SignIn"); err != nil {
+			return telegram.AuthErrorMsg{Err: err}
+		}
+		b.mu.Lock()
+		want, password := b.loginCode, b.password
+		b.mu.Unlock()
+		if want != "" && code != want {
+			return telegram.AuthErrorMsg{Err: errors.New("PHONE_CODE_INVALID")}
+		}
+		if password != "" {
+			return telegram.Need2FAMsg{}
+		}
+		return telegram.AuthorizedMsg{}
+	}
+}
+
+func (b *Backend) Submit2FA(password string) func() interface{} {
+	return func() interface{} {
+		if err := b.err("Submit2FA"); err != nil {
+			return telegram.AuthErrorMsg{Err: err}
+		}
+		b.mu.Lock()
+		want := b.password
+		b.mu.Unlock()
+		if password != want {
+			return telegram.AuthErrorMsg{Err: errors.New("PASSWORD_HASH_INVALID")}
+		}
+		return telegram.AuthorizedMsg{}
+	}
+}
+
+func (b *Backend) UnlockSession(passphrase string) func() interface{} {
+	return func() interface{} {
+		if err := b.err("UnlockSession"); err != nil {
+			return telegram.AuthErrorMsg{Err: err}
+		}
+		b.mu.Lock()
+		want := b.passphrase
+		b.mu.Unlock()
+		if passphrase != want {
+			return telegram.AuthErrorMsg{Err: telegram.ErrWrongPassphrase}
+		}
+		return telegram.SessionUnlockedMsg{}
+	}
+}
+
+// StartQRLogin emits a QRTokenMsg and blocks until ScanQR is called.
+func (b *Backend) StartQRLogin(loggedIn qrlogin.LoggedIn) func() interface{} {
+	return func() interface{} {
+		if err := b.err("StartQRLogin"); err != nil {
+			return telegram.AuthErrorMsg{Err: err}
+		}
+		b.Emit(telegram.QRTokenMsg{URL: "tg://login?token=fake"})
+		<-loggedIn
+		b.mu.Lock()
+		password := b.password
+		b.mu.Unlock()
+		if password != "" {
+			return telegram.Need2FAMsg{}
+		}
+		return telegram.AuthorizedMsg{}
+	}
+}
+
+func (b *Backend) LoggedIn() qrlogin.LoggedIn {
+	return b.loggedIn
+}
+
+func (b *Backend) FetchDialogs(folderID int) func() interface{} {
+	return func() interface{} {
+		if err := b.err("FetchDialogs"); err != nil {
+			return telegram.DialogsErrorMsg{FolderID: folderID, Err: err}
+		}
+		chats, next, hasMore := b.dialogsAfter(telegram.DialogsCursor{FolderID: folderID})
+		return telegram.DialogsLoadedMsg{FolderID: folderID, Chats: telegram.OrderChats(chats), Next: next, HasMore: hasMore}
+	}
+}
+
+func (b *Backend) FetchMoreDialogs(cursor telegram.DialogsCursor) func() interface{} {
+	return func() interface{} {
+		if err := b.err("FetchMoreDialogs"); err != nil {
+			return telegram.MoreDialogsErrorMsg{FolderID: cursor.FolderID, Err: err}
+		}
+		chats, next, hasMore := b.dialogsAfter(cursor)
+		return telegram.MoreDialogsLoadedMsg{FolderID: cursor.FolderID, Chats: chats, Next: next, HasMore: hasMore}
+	}
+}
+
+func (b *Backend) FetchFolders() func() interface{} {
+	return func() interface{} {
+		if err := b.err("FetchFolders"); err != nil {
+			return telegram.FoldersErrorMsg{Err: err}
+		}
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		return telegram.FoldersLoadedMsg{Folders: append([]telegram.Folder(nil), b.folders...)}
+	}
+}
+
+func (b *Backend) SetChatFolder(chat telegram.Chat, folderID int) func() interface{} {
+	return func() interface{} {
+		if err := b.err("SetChatFolder"); err != nil {
+			return telegram.FolderChangeErrorMsg{ChatID: chat.ID, Err: err}
+		}
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		for i := range b.dialogs {
+			if b.dialogs[i].ID == chat.ID {
+				b.dialogs[i].FolderID = folderID
+			}
+		}
+		return telegram.ChatFolderChangedMsg{ChatID: chat.ID, FolderID: folderID}
+	}
+}
+
+func (b *Backend) MarkRead(chat telegram.Chat, maxID, stillUnread int) func() interface{} {
+	return func() interface{} {
+		if err := b.err("MarkRead"); err != nil {
+			return telegram.ReadErrorMsg{ChatID: chat.ID, Err: err}
+		}
+		if stillUnread < 0 {
+			// Stands in for the server's dialog count, which Client
+			// fetches when the caller could not tell.
+			b.mu.Lock()
+			stillUnread = b.unreadAfter(chat.ID, maxID)
+			b.mu.Unlock()
+		}
+		return telegram.InboxReadMsg{ChatID: chat.ID, TopicID: chat.TopicID, MaxID: maxID, StillUnread: stillUnread}
+	}
+}
+
+func (b *Backend) FetchForumTopics(chat telegram.Chat) func() interface{} {
+	return func() interface{} {
+		if err := b.err("FetchForumTopics"); err != nil {
+			return telegram.ForumTopicsErrorMsg{ChatID: chat.ID, Err: err}
+		}
+		topics, next, hasMore := b.topicsAfter(chat.ID, telegram.TopicsCursor{})
+		return telegram.ForumTopicsLoadedMsg{ChatID: chat.ID, Topics: topics, Next: next, HasMore: hasMore}
+	}
+}
+
+func (b *Backend) FetchMoreForumTopics(chat telegram.Chat, cursor telegram.TopicsCursor) func() interface{} {
+	return func() interface{} {
+		if err := b.err("FetchMoreForumTopics"); err != nil {
+			return telegram.MoreTopicsErrorMsg{ChatID: chat.ID, Err: err}
+		}
+		topics, next, hasMore := b.topicsAfter(chat.ID, cursor)
+		return telegram.MoreTopicsLoadedMsg{ChatID: chat.ID, Topics: topics, Next: next, HasMore: hasMore}
+	}
+}
+
+func (b *Backend) FetchHistory(chat telegram.Chat) func() interface{} {
+	return func() interface{} {
+		if err := b.err("FetchHistory"); err != nil {
+			return telegram.HistoryErrorMsg{Err: err}
+		}
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		return telegram.HistoryLoadedMsg{ChatID: chat.ID, TopicID: chat.TopicID, Messages: lastPage(b.thread(chat))}
+	}
+}
+
+func (b *Backend) FetchOlderHistory(chat telegram.Chat, offsetID int) func() interface{} {
+	return func() interface{} {
+		if err := b.err("FetchOlderHistory"); err != nil {
+			return telegram.OlderHistoryErrorMsg{Err: err}
+		}
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		var older []telegram.Message
+		for _, m := range b.thread(chat) {
+			if m.ID < offsetID {
+				older = append(older, m)
+			}
+		}
+		return telegram.OlderHistoryLoadedMsg{ChatID: chat.ID, TopicID: chat.TopicID, Messages: lastPage(older)}
+	}
+}
+
+func (b *Backend) FetchNewerHistory(chat telegram.Chat, afterID int) func() interface{} {
+	return func() interface{} {
+		if err := b.err("FetchNewerHistory"); err != nil {
+			return telegram.NewerHistoryErrorMsg{Err: err}
+		}
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		var newer []telegram.Message
+		for _, m := range b.thread(chat) {
+			if m.ID > afterID && len(newer) < pageSize {
+				newer = append(newer, m)
+			}
+		}
+		return telegram.NewerHistoryLoadedMsg{ChatID: chat.ID, TopicID: chat.TopicID, Messages: newer}
+	}
+}
+
+func (b *Backend) FetchHistoryAround(chat telegram.Chat, msgID int) func() interface{} {
+	return func() interface{} {
+		if err := b.err("FetchHistoryAround"); err != nil {
+			return telegram.HistoryErrorMsg{Err: err}
+		}
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		h := b.thread(chat)
+		idx := 0
+		for idx < len(h) && h[idx].ID < msgID {
+			idx++
+		}
+		start := idx - pageSize/2
+		if start < 0 {
+			start = 0
+		}
+		end := start + pageSize
+		if end > len(h) {
+			end = len(h)
+		}
+		return telegram.HistoryLoadedMsg{
+			ChatID:   chat.ID,
+			TopicID:  chat.TopicID,
+			Messages: append([]telegram.Message(nil), h[start:end]...),
+			FocusID:  msgID,
+			HasNewer: end < len(h),
+		}
+	}
+}
+
+func (b *Backend) FetchMessages(chat telegram.Chat, ids []int) func() interface{} {
+	return func() interface{} {
+		if err := b.err("FetchMessages"); err != nil {
+			return telegram.MessagesFetchErrorMsg{ChatID: chat.ID, IDs: ids, Err: err}
+		}
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		want := make(map[int]bool, len(ids))
+		for _, id := range ids {
+			want[id] = true
+		}
+		var found []telegram.Message
+		for _, m := range b.history[chat.ID] {
+			if want[m.ID] {
+				found = append(found, m)
+			}
+		}
+		return telegram.MessagesFetchedMsg{ChatID: chat.ID, Messages: found}
+	}
+}
+
+func (b *Backend) SendMessage(chat telegram.Chat, text string, opts telegram.SendOptions) func() interface{} {
+	return func() interface{} {
+		if err := b.err("SendMessage"); err != nil {
+			return telegram.MessageSendErrorMsg{Err: err}
+		}
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		msg := b.outgoing(chat.ID, text)
+		msg.ReplyToID = opts.ReplyToID
+		if chat.TopicID != telegram.GeneralTopicID {
+			msg.TopicID = chat.TopicID
+		}
+		b.history[chat.ID] = append(b.history[chat.ID], msg)
+		return telegram.MessageSentMsg{ChatID: chat.ID}
+	}
+}
+
+// SendFile records the file as an outgoing media message. The file must
+// exist; nothing is uploaded.
+func (b *Backend) SendFile(chat telegram.Chat, path string, opts telegram.SendFileOptions) func() interface{} {
+	return func() interface{} {
+		name := filepath.Base(path)
+		if err := b.err("SendFile"); err != nil {
+			return telegram.FileSendErrorMsg{Name: name, Err: err}
+		}
+		stat, err := os.Stat(path)
+		if err != nil {
+			return telegram.FileSendErrorMsg{Name: name, Err: err}
+		}
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		msg := b.outgoing(chat.ID, opts.Caption)
+		msg.ReplyToID = opts.ReplyToID
+		media := &telegram.MediaInfo{FileName: name, FileSize: stat.Size(), MimeType: telegram.DetectMIME(path)}
+		switch opts.As {
+		case telegram.SendAsPhoto:
+			media.Type, media.Label = telegram.MediaPhoto, "[Photo]"
+		case telegram.SendAsVideo:
+			media.Type, media.Label = telegram.MediaVideo, "[Video]"
+		case telegram.SendAsVoice:
+			media.Type, media.Label = telegram.MediaVoice, "[Voice]"
+		default:
+			media.Type, media.Label = telegram.MediaDocument, "[File: "+name+"]"
+		}
+		msg.Media = media
+		b.history[chat.ID] = append(b.history[chat.ID], msg)
+		return telegram.FileSentMsg{ChatID: chat.ID, Name: name}
+	}
+}
+
+func (b *Backend) EditMessage(chat telegram.Chat, msgID int, text string) func() interface{} {
+	return func() interface{} {
+		if err := b.err("EditMessage"); err != nil {
+			return telegram.MessageEditErrorMsg{Err: err}
+		}
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		for i, m := range b.history[chat.ID] {
+			if m.ID == msgID {
+				m.Text = text
+				m.Entities = nil
+				m.EditDate = int(time.Now().Unix())
+				b.history[chat.ID][i] = m
+				return telegram.MessageEditedMsg{Message: m}
+			}
+		}
+		return telegram.MessageEditErrorMsg{Err: errors.New("MESSAGE_ID_INVALID")}
+	}
+}
+
+func (b *Backend) DeleteMessages(chat telegram.Chat, messageIDs []int, revoke bool) func() interface{} {
+	return func() interface{} {
+		if err := b.err("DeleteMessages"); err != nil {
+			return telegram.DeleteErrorMsg{Err: err}
+		}
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.history[chat.ID] = without(b.history[chat.ID], messageIDs)
+		return telegram.MessagesDeletedMsg{ChatID: chat.ID, IDs: messageIDs}
+	}
+}
+
+func (b *Backend) SetTyping(chat telegram.Chat) func() interface{} {
+	return func() interface{} {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.typing[chat.ID]++
+		return nil
+	}
+}
+
+func (b *Backend) ForwardMessages(fromChat telegram.Chat, messageIDs []int, toChat telegram.Chat) func() interface{} {
+	return func() interface{} {
+		if err := b.err("ForwardMessages"); err != nil {
+			return telegram.ForwardErrorMsg{Err: err}
+		}
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		want := make(map[int]bool, len(messageIDs))
+		for _, id := range messageIDs {
+			want[id] = true
+		}
+		for _, m := range b.history[fromChat.ID] {
+			if want[m.ID] {
+				fwd := b.outgoing(toChat.ID, m.Text)
+				fwd.Media = m.Media
+				fwd.Entities = m.Entities
+				b.history[toChat.ID] = append(b.history[toChat.ID], fwd)
+			}
+		}
+		return telegram.ForwardedMsg{FromChatID: fromChat.ID, ToChatID: toChat.ID, Count: len(messageIDs)}
+	}
+}
+
+func (b *Backend) SearchHistory(chat telegram.Chat, query telegram.SearchQuery) func() interface{} {
+	return func() interface{} {
+		if err := b.err("SearchHistory"); err != nil {
+			return telegram.SearchErrorMsg{Err: err}
+		}
+		found := b.searchBefore(chat, query, 0)
+		page := lastPage(found)
+		return telegram.SearchResultMsg{ChatID: chat.ID, Query: query, Messages: page, Next: searchNext(query, page), HasMore: len(found) > pageSize}
+	}
+}
+
+func (b *Backend) SearchHistoryMore(chat telegram.Chat, cursor telegram.SearchCursor) func() interface{} {
+	return func() interface{} {
+		if err := b.err("SearchHistoryMore"); err != nil {
+			return telegram.SearchErrorMsg{Err: err}
+		}
+		found := b.searchBefore(chat, cursor.Query, cursor.OffsetID)
+		page := lastPage(found)
+		return telegram.SearchMoreMsg{ChatID: chat.ID, Query: cursor.Query, Messages: page, Next: searchNext(cursor.Query, page), HasMore: len(found) > pageSize}
+	}
+}
+
+// searchNext is the cursor for the results older than page.
+func searchNext(query telegram.SearchQuery, page []telegram.Message) telegram.SearchCursor {
+	next := telegram.SearchCursor{Query: query}
+	if len(page) > 0 {
+		next.OffsetID = page[0].ID
+	}
+	return next
+}
+
+// searchBefore returns the messages of the thread matching query with IDs
+// below offsetID, or all of them if it is 0.
+func (b *Backend) searchBefore(chat telegram.Chat, query telegram.SearchQuery, offsetID int) []telegram.Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var found []telegram.Message
+	for _, m := range b.thread(chat) {
+		if (offsetID == 0 || m.ID < offsetID) && matchesSearch(m, query) {
+			found = append(found, m)
+		}
+	}
+	return found
+}
+
+// searchMediaTypes is the media each type: filter matches; "link" is
+// matched on the text instead.
+var searchMediaTypes = map[string][]telegram.MediaType{
+	"photo":    {telegram.MediaPhoto},
+	"video":    {telegram.MediaVideo},
+	"media":    {telegram.MediaPhoto, telegram.MediaVideo},
+	"file":     {telegram.MediaDocument},
+	"gif":      {telegram.MediaAnimation},
+	"voice":    {telegram.MediaVoice},
+	"music":    {telegram.MediaAudio},
+	"round":    {telegram.MediaVideo},
+	"location": {telegram.MediaLocation},
+	"contact":  {telegram.MediaContact},
+}
+
+// matchesSearch reports whether m satisfies query. There are no usernames
+// here, so from: is compared with the sender's name.
+func matchesSearch(m telegram.Message, query telegram.SearchQuery) bool {
+	if !strings.Contains(strings.ToLower(m.Text), strings.ToLower(query.Text)) {
+		return false
+	}
+	switch {
+	case query.From == "me":
+		if !m.Out {
+			return false
+		}
+	case query.From != "":
+		if !strings.EqualFold(m.Sender, query.From) {
+			return false
+		}
+	}
+	switch query.Type {
+	case "":
+	case "link":
+		if !strings.Contains(m.Text, "://") {
+			return false
+		}
+	default:
+		if m.Media == nil || !slices.Contains(searchMediaTypes[query.Type], m.Media.Type) {
+			return false
+		}
+	}
+	date := time.Unix(int64(m.Date), 0)
+	if !query.After.IsZero() && date.Before(query.After) {
+		return false
+	}
+	if !query.Before.IsZero() && !date.Before(query.Before) {
+		return false
+	}
+	return true
+}
+
+func (b *Backend) SearchGlobal(query string) func() interface{} {
+	return func() interface{} {
+		if err := b.err("SearchGlobal"); err != nil {
+			return telegram.GlobalSearchErrorMsg{Query: query, Err: err}
+		}
+		hits, next, hasMore := b.globalHitsAfter(telegram.GlobalSearchCursor{Query: query})
+		return telegram.GlobalSearchResultMsg{Query: query, Hits: hits, Next: next, HasMore: hasMore}
+	}
+}
+
+func (b *Backend) SearchGlobalMore(cursor telegram.GlobalSearchCursor) func() interface{} {
+	return func() interface{} {
+		if err := b.err("SearchGlobalMore"); err != nil {
+			return telegram.GlobalSearchErrorMsg{Query: cursor.Query, Err: err}
+		}
+		hits, next, hasMore := b.globalHitsAfter(cursor)
+		return telegram.GlobalSearchMoreMsg{Query: cursor.Query, Hits: hits, Next: next, HasMore: hasMore}
+	}
+}
+
+func (b *Backend) DownloadPhoto(msgID int, info *telegram.MediaInfo) func() interface{} {
+	return func() interface{} {
+		if err := b.err("DownloadPhoto"); err != nil {
+			return telegram.DownloadPhotoErrorMsg{MessageID: msgID, Err: err}
+		}
+		b.mu.Lock()
+		data, ok := b.photos[msgID]
+		b.mu.Unlock()
+		if !ok {
+			return telegram.DownloadPhotoErrorMsg{MessageID: msgID, Err: ErrNotFound}
+		}
+		return telegram.DownloadPhotoMsg{MessageID: msgID, Data: data}
+	}
+}
+
+func (b *Backend) DownloadToFile(msgID int, info *telegram.MediaInfo, destPath string) func() interface{} {
+	return func() interface{} {
+		if err := b.err("DownloadToFile"); err != nil {
+			return telegram.SaveFileErrorMsg{MessageID: msgID, Err: err}
+		}
+		b.mu.Lock()
+		data, ok := b.files[msgID]
+		b.mu.Unlock()
+		if !ok {
+			return telegram.SaveFileErrorMsg{MessageID: msgID, Err: ErrNotFound}
+		}
+		if err := os.WriteFile(destPath, data, 0o644); err != nil {
+			return telegram.SaveFileErrorMsg{MessageID: msgID, Err: fmt.Errorf("fake: %w", err)}
+		}
+		return telegram.SaveFileMsg{MessageID: msgID, Path: destPath}
+	}
+}
+
+// CancelDownload always reports the download cancelled; fake downloads
+// finish as soon as they start, so the file may already be on disk.
+func (b *Backend) CancelDownload(destPath string) func() interface{} {
+	return func() interface{} {
+		return telegram.DownloadCancelledMsg{Path: destPath}
+	}
+}
+
+// outgoing builds a message sent by the current user. Callers hold b.mu.
+func (b *Backend) outgoing(chatID int64, text string) telegram.Message {
+	msg := telegram.Message{
+		ID:       b.nextID,
+		ChatID:   chatID,
+		SenderID: b.selfID,
+		Sender:   b.selfName,
+		Text:     text,
+		Date:     int(time.Now().Unix()),
+		Out:      true,
+	}
+	b.nextID++
+	return msg
+}
+
+// thread returns the history of chat, narrowed to its open forum topic if
+// any. Callers hold b.mu.
+func (b *Backend) thread(chat telegram.Chat) []telegram.Message {
+	if chat.TopicID == 0 {
+		return b.history[chat.ID]
+	}
+	var msgs []telegram.Message
+	for _, m := range b.history[chat.ID] {
+		if m.InTopic(chat.TopicID) {
+			msgs = append(msgs, m)
+		}
+	}
+	return msgs
+}
+
+// unreadAfter counts incoming messages newer than maxID. Callers hold b.mu.
+func (b *Backend) unreadAfter(chatID int64, maxID int) int {
+	n := 0
+	for _, m := range b.history[chatID] {
+		if !m.Out && m.ID > maxID {
+			n++
+		}
+	}
+	return n
+}
+
+func without(msgs []telegram.Message, ids []int) []telegram.Message {
+	drop := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		drop[id] = true
+	}
+	kept := msgs[:0]
+	for _, m := range msgs {
+		if !drop[m.ID] {
+			kept = append(kept, m)
+		}
+	}
+	return kept
+}
+
+func lastPage(msgs []telegram.Message) []telegram.Message {
+	if len(msgs) > pageSize {
+		msgs = msgs[len(msgs)-pageSize:]
+	}
+	return append([]telegram.Message(nil), msgs...)
+}
+
+// globalHitsAfter returns the page of messages in the scripted dialogs
+// matching cursor.Query, newest first, that follows the message named by
+// the cursor's offset.
+func (b *Backend) globalHitsAfter(cursor telegram.GlobalSearchCursor) ([]telegram.SearchHit, telegram.GlobalSearchCursor, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	q := strings.ToLower(cursor.Query)
+	var hits []telegram.SearchHit
+	for _, c := range b.dialogs {
+		for _, m := range b.history[c.ID] {
+			if strings.Contains(strings.ToLower(m.Text), q) {
+				hits = append(hits, telegram.SearchHit{Chat: c, Message: m})
+			}
+		}
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		return hits[i].Message.Date > hits[j].Message.Date
+	})
+
+	start := 0
+	if cursor.OffsetPeer.ID != 0 {
+		for i, h := range hits {
+			if h.Chat.ID == cursor.OffsetPeer.ID && h.Message.ID == cursor.OffsetID {
+				start = i + 1
+				break
+			}
+		}
+	}
+	end := min(start+pageSize, len(hits))
+	page := hits[start:end]
+	next := telegram.GlobalSearchCursor{Query: cursor.Query}
+	if len(page) > 0 {
+		last := page[len(page)-1]
+		next.OffsetPeer = last.Chat
+		next.OffsetID = last.Message.ID
+		next.OffsetRate = last.Message.Date
+	}
+	return page, next, end < len(hits)
+}
+
+// topicsAfter returns the page of scripted topics following the one named
+// by cursor.OffsetTopic, or the first page for a cursor without one.
+func (b *Backend) topicsAfter(chatID int64, cursor telegram.TopicsCursor) ([]telegram.Topic, telegram.TopicsCursor, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	topics := b.topics[chatID]
+	start := 0
+	if cursor.OffsetTopic != 0 {
+		for i, t := range topics {
+			if t.ID == cursor.OffsetTopic {
+				start = i + 1
+				break
+			}
+		}
+	}
+	end := min(start+b.topicsPage, len(topics))
+	page := append([]telegram.Topic(nil), topics[start:end]...)
+	var next telegram.TopicsCursor
+	if len(page) > 0 {
+		last := page[len(page)-1]
+		next.OffsetTopic = last.ID
+		if last.LastMessage != nil {
+			next.OffsetID = last.LastMessage.ID
+			next.OffsetDate = last.LastMessage.Date
+		}
+	}
+	return page, next, end < len(topics)
+}
+
+// dialogsAfter returns the page of scripted dialogs in cursor.FolderID
+// following the chat named by cursor.OffsetPeer, or the first page for a
+// cursor without one.
+func (b *Backend) dialogsAfter(cursor telegram.DialogsCursor) ([]telegram.Chat, telegram.DialogsCursor, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var folder []telegram.Chat
+	for _, c := range b.dialogs {
+		if c.FolderID == cursor.FolderID {
+			folder = append(folder, c)
+		}
+	}
+	start := 0
+	if cursor.OffsetPeer.ID != 0 {
+		for i, c := range folder {
+			if c.ID == cursor.OffsetPeer.ID {
+				start = i + 1
+				break
+			}
+		}
+	}
+	end := start + b.dialogsPage
+	if end > len(folder) {
+		end = len(folder)
+	}
+	page := folder[start:end]
+	next := telegram.DialogsCursor{FolderID: cursor.FolderID}
+	if len(page) > 0 {
+		last := page[len(page)-1]
+		next.OffsetPeer = last
+		if last.LastMessage != nil {
+			next.OffsetID = last.LastMessage.ID
+			next.OffsetDate = last.LastMessage.Date
+		}
+	}
+	return page, next, end < len(folder)
+}
@@ -0,0 +1,54 @@
+package telegram
+
+import (
+	"fmt"
+
+	"github.com/gotd/td/tgerr"
+)
+
+// fileReferenceExpired reports whether a download failed because the file
+// reference captured in MediaInfo is no longer valid. Telegram rotates
+// them, so long sessions run into this regularly.
+func fileReferenceExpired(err error) bool {
+	return tgerr.Is(err, "FILE_REFERENCE_EXPIRED", "FILE_REFERENCE_INVALID")
+}
+
+func (c *Client) rememberChat(chat Chat) {
+	c.chatsMu.Lock()
+	defer c.chatsMu.Unlock()
+	if c.chats == nil {
+		c.chats = make(map[int64]Chat)
+	}
+	c.chats[chat.ID] = chat
+}
+
+func (c *Client) knownChat(id int64) (Chat, bool) {
+	c.chatsMu.Lock()
+	defer c.chatsMu.Unlock()
+	chat, ok := c.chats[id]
+	return chat, ok
+}
+
+// refreshMedia refetches the message carrying info and returns a copy of
+// its media with fresh file references.
+func (c *Client) refreshMedia(msgID int, info *MediaInfo) (*MediaInfo, error) {
+	chat, ok := c.knownChat(info.ChatID)
+	if !ok {
+		return nil, fmt.Errorf("file reference expired: unknown chat %d", info.ChatID)
+	}
+	msgs, err := c.getMessages(chat, []int{msgID})
+	if err != nil {
+		return nil, fmt.Errorf("refresh file reference: %w", err)
+	}
+	for _, m := range msgs {
+		if m.ID != msgID || m.Media == nil {
+			continue
+		}
+		// The message may have been edited to carry another file.
+		if m.Media.PhotoID != info.PhotoID || m.Media.DocID != info.DocID {
+			break
+		}
+		return m.Media, nil
+	}
+	return nil, fmt.Errorf("file reference expired: message %d no longer has this file", msgID)
+}
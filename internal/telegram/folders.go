@@ -0,0 +1,195 @@
+package telegram
+
+import (
+	"sort"
+	"time"
+
+	"github.com/gotd/td/tg"
+)
+
+// ArchiveFolderID is the peer folder Telegram uses for archived chats.
+const ArchiveFolderID = 1
+
+// Folder is a user-defined chat folder (a dialog filter). Its rules are
+// evaluated locally against the loaded chats.
+type Folder struct {
+	ID    int
+	Title string
+
+	Contacts        bool
+	NonContacts     bool
+	Groups          bool
+	Broadcasts      bool
+	Bots            bool
+	ExcludeMuted    bool
+	ExcludeRead     bool
+	ExcludeArchived bool
+
+	Pinned  []int64 // chat IDs, in folder order
+	Include []int64
+	Exclude []int64
+}
+
+// Matches reports whether chat belongs in the folder.
+func (f Folder) Matches(chat Chat, now time.Time) bool {
+	if containsID(f.Exclude, chat.ID) {
+		return false
+	}
+	if containsID(f.Pinned, chat.ID) || containsID(f.Include, chat.ID) {
+		return true
+	}
+	if f.ExcludeMuted && chat.Muted(now) {
+		return false
+	}
+	if f.ExcludeRead && chat.UnreadCount == 0 {
+		return false
+	}
+	if f.ExcludeArchived && chat.FolderID == ArchiveFolderID {
+		return false
+	}
+	switch chat.Type {
+	case ChatTypePrivate:
+		switch {
+		case chat.Bot:
+			return f.Bots
+		case chat.Contact:
+			return f.Contacts
+		default:
+			return f.NonContacts
+		}
+	case ChatTypeGroup:
+		return f.Groups
+	case ChatTypeChannel:
+		return f.Broadcasts
+	}
+	return false
+}
+
+// Chats returns the chats that belong in the folder: its pinned chats in
+// folder order, then the rest by latest message.
+func (f Folder) Chats(all []Chat, now time.Time) []Chat {
+	var pinned, rest []Chat
+	for _, c := range all {
+		if !f.Matches(c, now) {
+			continue
+		}
+		if containsID(f.Pinned, c.ID) {
+			pinned = append(pinned, c)
+		} else {
+			c.Pinned = false
+			rest = append(rest, c)
+		}
+	}
+	sort.SliceStable(pinned, func(i, j int) bool {
+		return indexOfID(f.Pinned, pinned[i].ID) < indexOfID(f.Pinned, pinned[j].ID)
+	})
+	for i := range pinned {
+		pinned[i].Pinned = true
+	}
+	return append(pinned, OrderChats(rest)...)
+}
+
+type FoldersLoadedMsg struct {
+	Folders []Folder
+}
+
+type FoldersErrorMsg struct {
+	Err error
+}
+
+// ChatFolderChangedMsg reports that a chat moved into or out of the
+// archive, by us or on another device.
+type ChatFolderChangedMsg struct {
+	ChatID   int64
+	FolderID int
+}
+
+type FolderChangeErrorMsg struct {
+	ChatID int64
+	Err    error
+}
+
+// FetchFolders loads the user's chat folders. The built-in "All chats"
+// entry is skipped; the UI always shows it.
+func (c *Client) FetchFolders() func() interface{} {
+	return func() interface{} {
+		result, err := c.api.MessagesGetDialogFilters(c.ctx)
+		if err != nil {
+			return FoldersErrorMsg{Err: err}
+		}
+		var folders []Folder
+		for _, f := range result.Filters {
+			switch f := f.(type) {
+			case *tg.DialogFilter:
+				folders = append(folders, Folder{
+					ID:              f.ID,
+					Title:           f.Title.Text,
+					Contacts:        f.Contacts,
+					NonContacts:     f.NonContacts,
+					Groups:          f.Groups,
+					Broadcasts:      f.Broadcasts,
+					Bots:            f.Bots,
+					ExcludeMuted:    f.ExcludeMuted,
+					ExcludeRead:     f.ExcludeRead,
+					ExcludeArchived: f.ExcludeArchived,
+					Pinned:          c.inputPeerIDs(f.PinnedPeers),
+					Include:         c.inputPeerIDs(f.IncludePeers),
+					Exclude:         c.inputPeerIDs(f.ExcludePeers),
+				})
+			case *tg.DialogFilterChatlist:
+				folders = append(folders, Folder{
+					ID:      f.ID,
+					Title:   f.Title.Text,
+					Pinned:  c.inputPeerIDs(f.PinnedPeers),
+					Include: c.inputPeerIDs(f.IncludePeers),
+				})
+			}
+		}
+		return FoldersLoadedMsg{Folders: folders}
+	}
+}
+
+// SetChatFolder moves a chat into the archive (ArchiveFolderID) or back
+// to the main list (0).
+func (c *Client) SetChatFolder(chat Chat, folderID int) func() interface{} {
+	return func() interface{} {
+		_, err := c.api.FoldersEditPeerFolders(c.ctx, []tg.InputFolderPeer{{
+			Peer:     c.chatToInputPeer(chat),
+			FolderID: folderID,
+		}})
+		if err != nil {
+			return FolderChangeErrorMsg{ChatID: chat.ID, Err: err}
+		}
+		return ChatFolderChangedMsg{ChatID: chat.ID, FolderID: folderID}
+	}
+}
+
+func (c *Client) inputPeerIDs(peers []tg.InputPeerClass) []int64 {
+	ids := make([]int64, 0, len(peers))
+	for _, p := range peers {
+		switch p := p.(type) {
+		case *tg.InputPeerSelf:
+			ids = append(ids, c.selfID)
+		case *tg.InputPeerUser:
+			ids = append(ids, p.UserID)
+		case *tg.InputPeerChat:
+			ids = append(ids, p.ChatID)
+		case *tg.InputPeerChannel:
+			ids = append(ids, p.ChannelID)
+		}
+	}
+	return ids
+}
+
+func containsID(ids []int64, id int64) bool {
+	return indexOfID(ids, id) >= 0
+}
+
+func indexOfID(ids []int64, id int64) int {
+	for i, v := range ids {
+		if v == id {
+			return i
+		}
+	}
+	return -1
+}
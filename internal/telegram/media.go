@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"fmt"
 	"io"
-	"os"
 	"path/filepath"
 
 	"github.com/gotd/td/tg"
@@ -30,82 +29,6 @@ type SaveFileErrorMsg struct {
 	Err       error
 }
 
-// DownloadToFile saves a media file (photo or document) to disk.
-func (c *Client) DownloadToFile(msgID int, info *MediaInfo, destPath string) func() interface{} {
-	return func() interface{} {
-		if info == nil {
-			return SaveFileErrorMsg{MessageID: msgID, Err: fmt.Errorf("no media info")}
-		}
-
-		var loc tg.InputFileLocationClass
-
-		switch info.Type {
-		case MediaPhoto:
-			if info.PhotoThumbSize == "" {
-				return SaveFileErrorMsg{MessageID: msgID, Err: fmt.Errorf("no photo size available")}
-			}
-			loc = &tg.InputPhotoFileLocation{
-				ID:            info.PhotoID,
-				AccessHash:    info.PhotoAccessHash,
-				FileReference: info.PhotoFileRef,
-				ThumbSize:     info.PhotoThumbSize,
-			}
-		default:
-			if info.DocID == 0 {
-				return SaveFileErrorMsg{MessageID: msgID, Err: fmt.Errorf("no document info available")}
-			}
-			loc = &tg.InputDocumentFileLocation{
-				ID:            info.DocID,
-				AccessHash:    info.DocAccessHash,
-				FileReference: info.DocFileRef,
-				ThumbSize:     "", // full file
-			}
-		}
-
-		f, err := os.Create(destPath)
-		if err != nil {
-			return SaveFileErrorMsg{MessageID: msgID, Err: err}
-		}
-		defer f.Close()
-
-		offset := int64(0)
-		const chunkSize = 1024 * 1024 // 1MB
-		for {
-			result, err := c.api.UploadGetFile(c.ctx, &tg.UploadGetFileRequest{
-				Location: loc,
-				Offset:   offset,
-				Limit:    chunkSize,
-			})
-			if err != nil {
-				os.Remove(destPath)
-				return SaveFileErrorMsg{MessageID: msgID, Err: err}
-			}
-
-			file, ok := result.(*tg.UploadFile)
-			if !ok {
-				os.Remove(destPath)
-				return SaveFileErrorMsg{MessageID: msgID, Err: fmt.Errorf("unexpected upload response")}
-			}
-
-			if len(file.Bytes) == 0 {
-				break
-			}
-
-			if _, err := f.Write(file.Bytes); err != nil {
-				os.Remove(destPath)
-				return SaveFileErrorMsg{MessageID: msgID, Err: err}
-			}
-
-			if len(file.Bytes) < chunkSize {
-				break
-			}
-			offset += int64(len(file.Bytes))
-		}
-
-		return SaveFileMsg{MessageID: msgID, Path: destPath}
-	}
-}
-
 func (c *Client) DownloadPhoto(msgID int, info *MediaInfo) func() interface{} {
 	return func() interface{} {
 		if info == nil || info.PhotoThumbSize == "" {
@@ -122,12 +45,22 @@ func (c *Client) DownloadPhoto(msgID int, info *MediaInfo) func() interface{} {
 		var buf bytes.Buffer
 		// Use upload.getFile to download the thumbnail
 		offset := 0
+		refreshed := false
 		for {
 			result, err := c.api.UploadGetFile(c.ctx, &tg.UploadGetFileRequest{
 				Location: loc,
 				Offset:   int64(offset),
 				Limit:    1024 * 1024, // 1MB chunks
 			})
+			if err != nil && fileReferenceExpired(err) && !refreshed {
+				refreshed = true
+				fresh, err := c.refreshMedia(msgID, info)
+				if err != nil {
+					return DownloadPhotoErrorMsg{MessageID: msgID, Err: err}
+				}
+				loc.FileReference = fresh.PhotoFileRef
+				continue
+			}
 			if err != nil {
 				return DownloadPhotoErrorMsg{MessageID: msgID, Err: err}
 			}
@@ -359,7 +292,7 @@ func extractDocMediaInfo(doc *tg.Document) *MediaInfo {
 			}
 		}
 	}
-	label := fmt.Sprintf("[Document: %s (%s)]", name, formatFileSize(doc.Size))
+	label := fmt.Sprintf("[Document: %s (%s)]", name, FormatFileSize(doc.Size))
 	return docFields(&MediaInfo{
 		Type:     MediaDocument,
 		Label:    label,
@@ -400,7 +333,8 @@ func formatDuration(seconds float64) string {
 	return fmt.Sprintf("%d:%02d", m, s)
 }
 
-func formatFileSize(bytes int64) string {
+// FormatFileSize renders a byte count as e.g. "3.2 MB".
+func FormatFileSize(bytes int64) string {
 	switch {
 	case bytes >= 1<<30:
 		return fmt.Sprintf("%.1f GB", float64(bytes)/float64(1<<30))
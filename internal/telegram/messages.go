@@ -3,13 +3,17 @@ package telegram
 import (
 	"crypto/rand"
 	"encoding/binary"
+	"time"
 
 	"github.com/gotd/td/tg"
 )
 
 type HistoryLoadedMsg struct {
 	ChatID   int64
+	TopicID  int // forum topic the messages belong to, 0 for the whole chat
 	Messages []Message
+	FocusID  int  // message to put the cursor on, 0 for the latest
+	HasNewer bool // true when newer messages exist past the loaded window
 }
 
 type HistoryErrorMsg struct {
@@ -18,6 +22,7 @@ type HistoryErrorMsg struct {
 
 type OlderHistoryLoadedMsg struct {
 	ChatID   int64
+	TopicID  int // forum topic the messages belong to, 0 for the whole chat
 	Messages []Message
 }
 
@@ -25,6 +30,33 @@ type OlderHistoryErrorMsg struct {
 	Err error
 }
 
+type NewerHistoryLoadedMsg struct {
+	ChatID   int64
+	TopicID  int // forum topic the messages belong to, 0 for the whole chat
+	Messages []Message
+}
+
+type NewerHistoryErrorMsg struct {
+	Err error
+}
+
+// MessagesFetchedMsg carries messages looked up by ID, e.g. reply parents.
+type MessagesFetchedMsg struct {
+	ChatID   int64
+	Messages []Message
+}
+
+type MessagesFetchErrorMsg struct {
+	ChatID int64
+	IDs    []int
+	Err    error
+}
+
+// SendOptions holds optional parameters for SendMessage.
+type SendOptions struct {
+	ReplyToID int // message being replied to, 0 for none
+}
+
 type MessageSentMsg struct {
 	ChatID int64
 }
@@ -33,6 +65,22 @@ type MessageSendErrorMsg struct {
 	Err error
 }
 
+type MessageEditErrorMsg struct {
+	Err error
+}
+
+// MessagesDeletedMsg reports messages removed from a chat. ChatID is 0 for
+// deletions in private chats and basic groups that arrive as updates, since
+// those share one message ID space and the server does not name the chat.
+type MessagesDeletedMsg struct {
+	ChatID int64
+	IDs    []int
+}
+
+type DeleteErrorMsg struct {
+	Err error
+}
+
 type ForwardedMsg struct {
 	FromChatID int64
 	ToChatID   int64
@@ -47,7 +95,7 @@ func (c *Client) FetchHistory(chat Chat) func() interface{} {
 	return func() interface{} {
 		peer := c.chatToInputPeer(chat)
 
-		result, err := c.api.MessagesGetHistory(c.ctx, &tg.MessagesGetHistoryRequest{
+		result, err := c.getHistory(chat, &tg.MessagesGetHistoryRequest{
 			Peer:  peer,
 			Limit: 50,
 		})
@@ -55,156 +103,149 @@ func (c *Client) FetchHistory(chat Chat) func() interface{} {
 			return HistoryErrorMsg{Err: err}
 		}
 
-		var msgs []Message
+		msgs := messagesFromResult(result, chat.ID)
+
+		return HistoryLoadedMsg{ChatID: chat.ID, TopicID: chat.TopicID, Messages: msgs}
+	}
+}
+
+func (c *Client) FetchOlderHistory(chat Chat, offsetID int) func() interface{} {
+	return func() interface{} {
+		peer := c.chatToInputPeer(chat)
 
-		var tgMessages []tg.MessageClass
-		var users []tg.UserClass
-
-		switch r := result.(type) {
-		case *tg.MessagesMessages:
-			tgMessages = r.Messages
-			users = r.Users
-		case *tg.MessagesMessagesSlice:
-			tgMessages = r.Messages
-			users = r.Users
-		case *tg.MessagesChannelMessages:
-			tgMessages = r.Messages
-			users = r.Users
+		result, err := c.getHistory(chat, &tg.MessagesGetHistoryRequest{
+			Peer:     peer,
+			OffsetID: offsetID,
+			Limit:    50,
+		})
+		if err != nil {
+			return OlderHistoryErrorMsg{Err: err}
 		}
 
-		userMap := make(map[int64]*tg.User)
-		for _, u := range users {
-			if user, ok := u.(*tg.User); ok {
-				userMap[user.ID] = user
-			}
+		msgs := messagesFromResult(result, chat.ID)
+
+		return OlderHistoryLoadedMsg{ChatID: chat.ID, TopicID: chat.TopicID, Messages: msgs}
+	}
+}
+
+// FetchHistoryAround loads a page of history centred on msgID.
+func (c *Client) FetchHistoryAround(chat Chat, msgID int) func() interface{} {
+	return func() interface{} {
+		peer := c.chatToInputPeer(chat)
+
+		const limit = 50
+		result, err := c.getHistory(chat, &tg.MessagesGetHistoryRequest{
+			Peer:      peer,
+			OffsetID:  msgID,
+			AddOffset: -limit / 2,
+			Limit:     limit,
+		})
+		if err != nil {
+			return HistoryErrorMsg{Err: err}
 		}
 
-		for _, m := range tgMessages {
-			msg, ok := m.(*tg.Message)
-			if !ok {
-				continue
-			}
+		msgs := messagesFromResult(result, chat.ID)
 
-			sender := ""
-			senderID := int64(0)
-			if msg.FromID != nil {
-				if peer, ok := msg.FromID.(*tg.PeerUser); ok {
-					senderID = peer.UserID
-					if u, exists := userMap[peer.UserID]; exists {
-						sender = displayName(u.FirstName, u.LastName)
-					}
+		// Fewer than half a page newer than the target means we reached the
+		// end. Service messages are dropped from msgs but still fill the
+		// page, so count the raw result.
+		newer := 0
+		if r, ok := result.AsModified(); ok {
+			for _, m := range r.GetMessages() {
+				if m.GetID() > msgID {
+					newer++
 				}
 			}
-
-			msgs = append(msgs, Message{
-				ID:        msg.ID,
-				ChatID:    chat.ID,
-				SenderID:  senderID,
-				Sender:    sender,
-				Text:      msg.Message,
-				Date:      msg.Date,
-				Out:       msg.Out,
-				Entities:  msg.Entities,
-				Media:     extractMediaInfo(msg.Media),
-				Reactions: extractReactions(msg.Reactions),
-			})
 		}
 
-		// Reverse to chronological order
-		for i, j := 0, len(msgs)-1; i < j; i, j = i+1, j-1 {
-			msgs[i], msgs[j] = msgs[j], msgs[i]
+		return HistoryLoadedMsg{
+			ChatID:   chat.ID,
+			TopicID:  chat.TopicID,
+			Messages: msgs,
+			FocusID:  msgID,
+			HasNewer: newer >= limit/2-1,
 		}
-
-		return HistoryLoadedMsg{ChatID: chat.ID, Messages: msgs}
 	}
 }
 
-func (c *Client) FetchOlderHistory(chat Chat, offsetID int) func() interface{} {
+// FetchNewerHistory loads the page of messages right after afterID.
+func (c *Client) FetchNewerHistory(chat Chat, afterID int) func() interface{} {
 	return func() interface{} {
 		peer := c.chatToInputPeer(chat)
 
-		result, err := c.api.MessagesGetHistory(c.ctx, &tg.MessagesGetHistoryRequest{
-			Peer:     peer,
-			OffsetID: offsetID,
-			Limit:    50,
+		const limit = 50
+		result, err := c.getHistory(chat, &tg.MessagesGetHistoryRequest{
+			Peer:      peer,
+			OffsetID:  afterID + 1,
+			AddOffset: -limit,
+			Limit:     limit,
 		})
 		if err != nil {
-			return OlderHistoryErrorMsg{Err: err}
+			return NewerHistoryErrorMsg{Err: err}
 		}
 
 		var msgs []Message
-
-		var tgMessages []tg.MessageClass
-		var users []tg.UserClass
-
-		switch r := result.(type) {
-		case *tg.MessagesMessages:
-			tgMessages = r.Messages
-			users = r.Users
-		case *tg.MessagesMessagesSlice:
-			tgMessages = r.Messages
-			users = r.Users
-		case *tg.MessagesChannelMessages:
-			tgMessages = r.Messages
-			users = r.Users
-		}
-
-		userMap := make(map[int64]*tg.User)
-		for _, u := range users {
-			if user, ok := u.(*tg.User); ok {
-				userMap[user.ID] = user
+		for _, m := range messagesFromResult(result, chat.ID) {
+			if m.ID > afterID {
+				msgs = append(msgs, m)
 			}
 		}
 
-		for _, m := range tgMessages {
-			msg, ok := m.(*tg.Message)
-			if !ok {
-				continue
-			}
-
-			sender := ""
-			senderID := int64(0)
-			if msg.FromID != nil {
-				if peer, ok := msg.FromID.(*tg.PeerUser); ok {
-					senderID = peer.UserID
-					if u, exists := userMap[peer.UserID]; exists {
-						sender = displayName(u.FirstName, u.LastName)
-					}
-				}
-			}
+		return NewerHistoryLoadedMsg{ChatID: chat.ID, TopicID: chat.TopicID, Messages: msgs}
+	}
+}
 
-			msgs = append(msgs, Message{
-				ID:        msg.ID,
-				ChatID:    chat.ID,
-				SenderID:  senderID,
-				Sender:    sender,
-				Text:      msg.Message,
-				Date:      msg.Date,
-				Out:       msg.Out,
-				Entities:  msg.Entities,
-				Media:     extractMediaInfo(msg.Media),
-				Reactions: extractReactions(msg.Reactions),
-			})
+// FetchMessages looks up specific messages of a chat by ID.
+func (c *Client) FetchMessages(chat Chat, ids []int) func() interface{} {
+	return func() interface{} {
+		msgs, err := c.getMessages(chat, ids)
+		if err != nil {
+			return MessagesFetchErrorMsg{ChatID: chat.ID, IDs: ids, Err: err}
 		}
 
-		// Reverse to chronological order
-		for i, j := 0, len(msgs)-1; i < j; i, j = i+1, j-1 {
-			msgs[i], msgs[j] = msgs[j], msgs[i]
-		}
+		return MessagesFetchedMsg{ChatID: chat.ID, Messages: msgs}
+	}
+}
+
+func (c *Client) getMessages(chat Chat, ids []int) ([]Message, error) {
+	input := make([]tg.InputMessageClass, len(ids))
+	for i, id := range ids {
+		input[i] = &tg.InputMessageID{ID: id}
+	}
 
-		return OlderHistoryLoadedMsg{ChatID: chat.ID, Messages: msgs}
+	var (
+		result tg.MessagesMessagesClass
+		err    error
+	)
+	switch peer := c.chatToInputPeer(chat).(type) {
+	case *tg.InputPeerChannel:
+		result, err = c.api.ChannelsGetMessages(c.ctx, &tg.ChannelsGetMessagesRequest{
+			Channel: &tg.InputChannel{ChannelID: peer.ChannelID, AccessHash: peer.AccessHash},
+			ID:      input,
+		})
+	default:
+		result, err = c.api.MessagesGetMessages(c.ctx, input)
+	}
+	if err != nil {
+		return nil, err
 	}
+	return messagesFromResult(result, chat.ID), nil
 }
 
-func (c *Client) SendMessage(chat Chat, text string) func() interface{} {
+func (c *Client) SendMessage(chat Chat, text string, opts SendOptions) func() interface{} {
 	return func() interface{} {
 		peer := c.chatToInputPeer(chat)
 
-		_, err := c.api.MessagesSendMessage(c.ctx, &tg.MessagesSendMessageRequest{
+		req := &tg.MessagesSendMessageRequest{
 			Peer:     peer,
 			Message:  text,
 			RandomID: randomID(),
-		})
+		}
+		if reply := topicReplyTo(chat, opts.ReplyToID); reply != nil {
+			req.SetReplyTo(reply)
+		}
+
+		_, err := c.api.MessagesSendMessage(c.ctx, req)
 		if err != nil {
 			return MessageSendErrorMsg{Err: err}
 		}
@@ -213,6 +254,102 @@ func (c *Client) SendMessage(chat Chat, text string) func() interface{} {
 	}
 }
 
+// EditMessage replaces the text of one of our own messages.
+func (c *Client) EditMessage(chat Chat, msgID int, text string) func() interface{} {
+	return func() interface{} {
+		peer := c.chatToInputPeer(chat)
+
+		result, err := c.api.MessagesEditMessage(c.ctx, &tg.MessagesEditMessageRequest{
+			Peer:    peer,
+			ID:      msgID,
+			Message: text,
+		})
+		if err != nil {
+			return MessageEditErrorMsg{Err: err}
+		}
+
+		if edited, ok := findEditedMessage(result, chat.ID, msgID); ok {
+			return MessageEditedMsg{Message: edited}
+		}
+
+		// The server did not echo the edit back; report what we know.
+		return MessageEditedMsg{Message: Message{
+			ID:       msgID,
+			ChatID:   chat.ID,
+			SenderID: c.selfID,
+			Text:     text,
+			EditDate: int(time.Now().Unix()),
+			Out:      true,
+		}}
+	}
+}
+
+// findEditedMessage looks for the edit of msgID in an RPC result.
+func findEditedMessage(result tg.UpdatesClass, chatID int64, msgID int) (Message, bool) {
+	var (
+		updates []tg.UpdateClass
+		users   []tg.UserClass
+	)
+	switch u := result.(type) {
+	case *tg.Updates:
+		updates, users = u.Updates, u.Users
+	case *tg.UpdatesCombined:
+		updates, users = u.Updates, u.Users
+	case *tg.UpdateShort:
+		updates = []tg.UpdateClass{u.Update}
+	default:
+		return Message{}, false
+	}
+
+	userMap := make(map[int64]*tg.User)
+	for _, u := range users {
+		if user, ok := u.(*tg.User); ok {
+			userMap[user.ID] = user
+		}
+	}
+
+	for _, upd := range updates {
+		var m tg.MessageClass
+		switch v := upd.(type) {
+		case *tg.UpdateEditMessage:
+			m = v.Message
+		case *tg.UpdateEditChannelMessage:
+			m = v.Message
+		default:
+			continue
+		}
+		if msg, ok := m.(*tg.Message); ok && msg.ID == msgID {
+			return convertMessage(msg, chatID, userMap), true
+		}
+	}
+	return Message{}, false
+}
+
+// DeleteMessages deletes messages from a chat. With revoke set they are
+// removed for every participant; channels and supergroups always revoke.
+func (c *Client) DeleteMessages(chat Chat, messageIDs []int, revoke bool) func() interface{} {
+	return func() interface{} {
+		var err error
+		switch peer := c.chatToInputPeer(chat).(type) {
+		case *tg.InputPeerChannel:
+			_, err = c.api.ChannelsDeleteMessages(c.ctx, &tg.ChannelsDeleteMessagesRequest{
+				Channel: &tg.InputChannel{ChannelID: peer.ChannelID, AccessHash: peer.AccessHash},
+				ID:      messageIDs,
+			})
+		default:
+			_, err = c.api.MessagesDeleteMessages(c.ctx, &tg.MessagesDeleteMessagesRequest{
+				Revoke: revoke,
+				ID:     messageIDs,
+			})
+		}
+		if err != nil {
+			return DeleteErrorMsg{Err: err}
+		}
+
+		return MessagesDeletedMsg{ChatID: chat.ID, IDs: messageIDs}
+	}
+}
+
 func (c *Client) ForwardMessages(fromChat Chat, messageIDs []int, toChat Chat) func() interface{} {
 	return func() interface{} {
 		fromPeer := c.chatToInputPeer(fromChat)
@@ -257,6 +394,104 @@ func (c *Client) chatToInputPeer(chat Chat) tg.InputPeerClass {
 	}
 }
 
+// messagesFromResult converts a messages.Messages response into
+// chronological order, skipping service and empty messages.
+func messagesFromResult(result tg.MessagesMessagesClass, chatID int64) []Message {
+	var tgMessages []tg.MessageClass
+	var users []tg.UserClass
+
+	switch r := result.(type) {
+	case *tg.MessagesMessages:
+		tgMessages = r.Messages
+		users = r.Users
+	case *tg.MessagesMessagesSlice:
+		tgMessages = r.Messages
+		users = r.Users
+	case *tg.MessagesChannelMessages:
+		tgMessages = r.Messages
+		users = r.Users
+	}
+
+	userMap := make(map[int64]*tg.User)
+	for _, u := range users {
+		if user, ok := u.(*tg.User); ok {
+			userMap[user.ID] = user
+		}
+	}
+
+	var msgs []Message
+	for _, m := range tgMessages {
+		msg, ok := m.(*tg.Message)
+		if !ok {
+			continue
+		}
+		msgs = append(msgs, convertMessage(msg, chatID, userMap))
+	}
+
+	// Reverse to chronological order
+	for i, j := 0, len(msgs)-1; i < j; i, j = i+1, j-1 {
+		msgs[i], msgs[j] = msgs[j], msgs[i]
+	}
+	return msgs
+}
+
+// convertMessage builds a Message from its TL form. users is used to
+// resolve the sender's display name and may be nil.
+func convertMessage(msg *tg.Message, chatID int64, users map[int64]*tg.User) Message {
+	sender := ""
+	senderID := int64(0)
+	if msg.FromID != nil {
+		if peer, ok := msg.FromID.(*tg.PeerUser); ok {
+			senderID = peer.UserID
+			if u, exists := users[peer.UserID]; exists {
+				sender = displayName(u.FirstName, u.LastName)
+			}
+		}
+	}
+
+	editDate := 0
+	if !msg.EditHide {
+		editDate = msg.EditDate
+	}
+
+	// Replies to messages in other chats have no parent we can show.
+	replyToID, topicID := 0, 0
+	if h, ok := msg.ReplyTo.(*tg.MessageReplyHeader); ok && h.ReplyToPeerID == nil {
+		replyToID = h.ReplyToMsgID
+		if h.ForumTopic {
+			// Posts in a topic reply to its root message; only a set top
+			// ID means the post is also a reply to something inside it.
+			topicID = h.ReplyToMsgID
+			if top, ok := h.GetReplyToTopID(); ok {
+				topicID = top
+			} else {
+				replyToID = 0
+			}
+		}
+	}
+
+	media := extractMediaInfo(msg.Media)
+	if media != nil {
+		media.ChatID = chatID
+	}
+
+	return Message{
+		ID:        msg.ID,
+		ChatID:    chatID,
+		SenderID:  senderID,
+		Sender:    sender,
+		Text:      msg.Message,
+		Date:      msg.Date,
+		EditDate:  editDate,
+		ReplyToID: replyToID,
+		TopicID:   topicID,
+		Out:       msg.Out,
+		Entities:  msg.Entities,
+		Media:     media,
+		Reactions: extractReactions(msg.Reactions),
+	}
+}
+
 func extractReactions(reactions tg.MessageReactions) []Reaction {
 	if len(reactions.Results) == 0 {
 		return nil
@@ -284,92 +519,113 @@ func extractReactions(reactions tg.MessageReactions) []Reaction {
 	return result
 }
 
+// searchPageSize is how many results each MessagesSearch call asks for.
+const searchPageSize = 50
+
+// SearchResultMsg carries the newest results of a search in a chat, in
+// chronological order.
 type SearchResultMsg struct {
 	ChatID   int64
-	Query    string
+	Query    SearchQuery
+	Messages []Message
+	Next     SearchCursor
+	HasMore  bool // older results remain
+}
+
+// SearchMoreMsg carries results older than those already shown.
+type SearchMoreMsg struct {
+	ChatID   int64
+	Query    SearchQuery
 	Messages []Message
+	Next     SearchCursor
+	HasMore  bool
 }
 
 type SearchErrorMsg struct {
 	Err error
 }
 
-func (c *Client) SearchHistory(chat Chat, query string) func() interface{} {
-	return func() interface{} {
-		peer := c.chatToInputPeer(chat)
+// SearchCursor marks where the next page of a chat search starts: below
+// OffsetID. It carries the from: sender once resolved, so later pages do
+// not look the username up again.
+type SearchCursor struct {
+	Query    SearchQuery
+	OffsetID int
 
-		result, err := c.api.MessagesSearch(c.ctx, &tg.MessagesSearchRequest{
-			Peer:   peer,
-			Q:      query,
-			Filter: &tg.InputMessagesFilterEmpty{},
-			Limit:  50,
-		})
+	from tg.InputPeerClass
+}
+
+// SearchHistory searches the chat, or its open topic, for query.
+func (c *Client) SearchHistory(chat Chat, query SearchQuery) func() interface{} {
+	return func() interface{} {
+		msgs, next, hasMore, err := c.searchPage(chat, SearchCursor{Query: query})
 		if err != nil {
 			return SearchErrorMsg{Err: err}
 		}
+		return SearchResultMsg{ChatID: chat.ID, Query: query, Messages: msgs, Next: next, HasMore: hasMore}
+	}
+}
 
-		var msgs []Message
-
-		var tgMessages []tg.MessageClass
-		var users []tg.UserClass
-
-		switch r := result.(type) {
-		case *tg.MessagesMessages:
-			tgMessages = r.Messages
-			users = r.Users
-		case *tg.MessagesMessagesSlice:
-			tgMessages = r.Messages
-			users = r.Users
-		case *tg.MessagesChannelMessages:
-			tgMessages = r.Messages
-			users = r.Users
-		}
-
-		userMap := make(map[int64]*tg.User)
-		for _, u := range users {
-			if user, ok := u.(*tg.User); ok {
-				userMap[user.ID] = user
-			}
-		}
-
-		for _, m := range tgMessages {
-			msg, ok := m.(*tg.Message)
-			if !ok {
-				continue
-			}
-
-			sender := ""
-			senderID := int64(0)
-			if msg.FromID != nil {
-				if peer, ok := msg.FromID.(*tg.PeerUser); ok {
-					senderID = peer.UserID
-					if u, exists := userMap[peer.UserID]; exists {
-						sender = displayName(u.FirstName, u.LastName)
-					}
-				}
-			}
-
-			msgs = append(msgs, Message{
-				ID:        msg.ID,
-				ChatID:    chat.ID,
-				SenderID:  senderID,
-				Sender:    sender,
-				Text:      msg.Message,
-				Date:      msg.Date,
-				Out:       msg.Out,
-				Entities:  msg.Entities,
-				Media:     extractMediaInfo(msg.Media),
-				Reactions: extractReactions(msg.Reactions),
-			})
+// SearchHistoryMore loads the page of results after cursor.
+func (c *Client) SearchHistoryMore(chat Chat, cursor SearchCursor) func() interface{} {
+	return func() interface{} {
+		msgs, next, hasMore, err := c.searchPage(chat, cursor)
+		if err != nil {
+			return SearchErrorMsg{Err: err}
 		}
+		return SearchMoreMsg{ChatID: chat.ID, Query: cursor.Query, Messages: msgs, Next: next, HasMore: hasMore}
+	}
+}
 
-		// Reverse to chronological order
-		for i, j := 0, len(msgs)-1; i < j; i, j = i+1, j-1 {
-			msgs[i], msgs[j] = msgs[j], msgs[i]
+// searchPage returns the results at cursor, the cursor for the page after
+// them and whether there is one.
+func (c *Client) searchPage(chat Chat, cursor SearchCursor) ([]Message, SearchCursor, bool, error) {
+	query := cursor.Query
+	req := &tg.MessagesSearchRequest{
+		Peer:     c.chatToInputPeer(chat),
+		Q:        query.Text,
+		Filter:   query.filter(),
+		OffsetID: cursor.OffsetID,
+		Limit:    searchPageSize,
+	}
+	if chat.TopicID != 0 {
+		req.SetTopMsgID(chat.TopicID)
+	}
+	if query.From != "" && cursor.from == nil {
+		from, err := c.resolveSender(query.From)
+		if err != nil {
+			return nil, cursor, false, err
 		}
+		cursor.from = from
+	}
+	if cursor.from != nil {
+		req.SetFromID(cursor.from)
+	}
+	if !query.After.IsZero() {
+		req.MinDate = int(query.After.Unix())
+	}
+	if !query.Before.IsZero() {
+		req.MaxDate = int(query.Before.Unix())
+	}
+	result, err := c.api.MessagesSearch(c.ctx, req)
+	if err != nil {
+		return nil, cursor, false, err
+	}
 
-		return SearchResultMsg{ChatID: chat.ID, Query: query, Messages: msgs}
+	// A plain MessagesMessages holds every result; the sliced kinds are
+	// cut at the limit when more remain.
+	var hasMore bool
+	switch r := result.(type) {
+	case *tg.MessagesMessagesSlice:
+		hasMore = len(r.Messages) == searchPageSize
+	case *tg.MessagesChannelMessages:
+		hasMore = len(r.Messages) == searchPageSize
+	}
+	msgs := messagesFromResult(result, chat.ID)
+	if len(msgs) > 0 {
+		cursor.OffsetID = msgs[0].ID
 	}
+	return msgs, cursor, hasMore, nil
 }
 
 func randomID() int64 {
@@ -0,0 +1,52 @@
+package telegram
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/tg"
+)
+
+// historyServer answers messages.getHistory around OffsetID from a chat
+// of messages 1..last, newest first, where every third one is a service
+// message.
+type historyServer struct {
+	last int
+}
+
+func (s *historyServer) Invoke(ctx context.Context, input bin.Encoder, output bin.Decoder) error {
+	req := input.(*tg.MessagesGetHistoryRequest)
+	top := min(req.OffsetID-req.AddOffset-1, s.last)
+	var msgs []tg.MessageClass
+	for id := top; id > top-req.Limit && id > 0; id-- {
+		peer := &tg.PeerUser{UserID: 42}
+		if id%3 == 0 {
+			msgs = append(msgs, &tg.MessageService{ID: id, PeerID: peer, Action: &tg.MessageActionPinMessage{}})
+			continue
+		}
+		msgs = append(msgs, &tg.Message{ID: id, PeerID: peer})
+	}
+	var b bin.Buffer
+	if err := (&tg.MessagesMessagesSlice{Count: s.last, Messages: msgs}).Encode(&b); err != nil {
+		return err
+	}
+	return output.Decode(&b)
+}
+
+func TestHistoryAroundCountsServiceMessages(t *testing.T) {
+	c := &Client{ctx: context.Background(), api: tg.NewClient(&historyServer{last: 200})}
+	chat := Chat{ID: 42, Type: ChatTypePrivate}
+
+	// Only two thirds of the newer half are regular messages, but the
+	// server still filled the page.
+	loaded, ok := c.FetchHistoryAround(chat, 100)().(HistoryLoadedMsg)
+	if !ok || !loaded.HasNewer {
+		t.Errorf("expected more history after a full page, got %+v", loaded)
+	}
+
+	loaded, ok = c.FetchHistoryAround(chat, 195)().(HistoryLoadedMsg)
+	if !ok || loaded.HasNewer {
+		t.Errorf("expected the page to reach the latest message, got %+v", loaded)
+	}
+}
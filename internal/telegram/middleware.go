@@ -0,0 +1,186 @@
+package telegram
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/telegram"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+)
+
+const (
+	// maxFloodWait is the longest flood wait sat out; longer ones fail.
+	maxFloodWait = 5 * time.Minute
+	// maxRetries bounds both flood-wait and transient-error retries of
+	// one call.
+	maxRetries   = 3
+	retryBackoff = 500 * time.Millisecond // doubled on every retry
+	// Calls may burst up to rateBurst at once, then pass one per
+	// rateInterval. File transfers are not limited.
+	rateBurst    = 20
+	rateInterval = 100 * time.Millisecond
+)
+
+// RateLimitedMsg reports that a call hit a flood wait and is retried after
+// Wait. A zero Wait reports that the call is no longer waiting.
+type RateLimitedMsg struct {
+	Wait time.Duration
+}
+
+// retryMiddleware keeps flood waits and transient server errors away from
+// the UI: flood waits are sat out, idempotent calls are retried with
+// backoff, and bursts are spread out before they trigger flood waits.
+type retryMiddleware struct {
+	notify  func(wait time.Duration)
+	sleep   func(ctx context.Context, d time.Duration) error
+	limiter *limiter
+}
+
+func newRetryMiddleware(notify func(wait time.Duration)) *retryMiddleware {
+	return &retryMiddleware{
+		notify:  notify,
+		sleep:   sleepContext,
+		limiter: &limiter{burst: rateBurst, interval: rateInterval},
+	}
+}
+
+var _ telegram.Middleware = (*retryMiddleware)(nil)
+
+func (m *retryMiddleware) Handle(next tg.Invoker) telegram.InvokeFunc {
+	return func(ctx context.Context, input bin.Encoder, output bin.Decoder) error {
+		if !fileTransfer(input) {
+			if err := m.sleep(ctx, m.limiter.reserve(time.Now())); err != nil {
+				return err
+			}
+		}
+
+		waited := false
+		defer func() {
+			if waited {
+				m.notify(0)
+			}
+		}()
+		for attempt := 0; ; attempt++ {
+			err := next.Invoke(ctx, input, output)
+			if err == nil {
+				return nil
+			}
+			if attempt == maxRetries {
+				return err
+			}
+
+			// A flood-waited call was not executed, so any call can be
+			// repeated; other failures only for calls without side effects.
+			wait, flood := tgerr.AsFloodWait(err)
+			switch {
+			case flood && wait <= maxFloodWait:
+				waited = true
+				m.notify(wait)
+			case !flood && transientError(err) && idempotent(input):
+				wait = retryBackoff << attempt
+			default:
+				return err
+			}
+			if err := m.sleep(ctx, wait); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// chainMiddlewares wraps invoker so that calls pass through middlewares in
+// order, the way telegram.Options.Middlewares applies them.
+func chainMiddlewares(invoker tg.Invoker, middlewares ...telegram.Middleware) tg.Invoker {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		invoker = middlewares[i].Handle(invoker)
+	}
+	return invoker
+}
+
+// transientError reports server-side failures that usually pass on retry.
+func transientError(err error) bool {
+	rpcErr, ok := tgerr.As(err)
+	if !ok {
+		return false
+	}
+	return rpcErr.IsCodeOneOf(500, -503) || rpcErr.IsOneOf("TIMEOUT", "RPC_CALL_FAIL", "RPC_MCGET_FAIL")
+}
+
+// idempotent reports whether a call only reads, so repeating it after an
+// unclear failure cannot, e.g., send a message twice.
+func idempotent(input bin.Encoder) bool {
+	switch input.(type) {
+	case *tg.MessagesGetDialogsRequest,
+		*tg.MessagesGetDialogFiltersRequest,
+		*tg.MessagesGetHistoryRequest,
+		*tg.MessagesGetRepliesRequest,
+		*tg.MessagesGetMessagesRequest,
+		*tg.ChannelsGetMessagesRequest,
+		*tg.MessagesGetForumTopicsRequest,
+		*tg.MessagesSearchRequest,
+		*tg.MessagesSearchGlobalRequest,
+		*tg.UsersGetUsersRequest,
+		*tg.UpdatesGetStateRequest,
+		*tg.UpdatesGetDifferenceRequest,
+		*tg.UpdatesGetChannelDifferenceRequest,
+		*tg.UploadGetFileRequest:
+		return true
+	}
+	return false
+}
+
+func fileTransfer(input bin.Encoder) bool {
+	switch input.(type) {
+	case *tg.UploadGetFileRequest, *tg.UploadSaveFilePartRequest, *tg.UploadSaveBigFilePartRequest:
+		return true
+	}
+	return false
+}
+
+// limiter is a token bucket handing out delays rather than blocking.
+type limiter struct {
+	burst    int
+	interval time.Duration
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// reserve takes a token at now and returns how long to wait until it is
+// actually available.
+func (l *limiter) reserve(now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.last.IsZero() {
+		l.tokens = float64(l.burst)
+	} else {
+		l.tokens += float64(now.Sub(l.last)) / float64(l.interval)
+		if l.tokens > float64(l.burst) {
+			l.tokens = float64(l.burst)
+		}
+	}
+	l.last = now
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens * float64(l.interval))
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
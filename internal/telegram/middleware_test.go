@@ -0,0 +1,131 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+)
+
+// scriptedInvoker fails the first calls with errs, then succeeds.
+type scriptedInvoker struct {
+	errs  []error
+	calls int
+}
+
+func (s *scriptedInvoker) Invoke(ctx context.Context, input bin.Encoder, output bin.Decoder) error {
+	s.calls++
+	if len(s.errs) > 0 {
+		err := s.errs[0]
+		s.errs = s.errs[1:]
+		return err
+	}
+	return nil
+}
+
+func testMiddleware() (*retryMiddleware, *[]time.Duration, *[]time.Duration) {
+	var notified, slept []time.Duration
+	m := newRetryMiddleware(func(wait time.Duration) { notified = append(notified, wait) })
+	m.sleep = func(ctx context.Context, d time.Duration) error {
+		if d > 0 {
+			slept = append(slept, d)
+		}
+		return nil
+	}
+	return m, &notified, &slept
+}
+
+func TestRetryMiddlewareWaitsOutFloodWait(t *testing.T) {
+	m, notified, slept := testMiddleware()
+	next := &scriptedInvoker{errs: []error{tgerr.New(420, "FLOOD_WAIT_12")}}
+
+	err := m.Handle(next).Invoke(context.Background(), &tg.MessagesSendMessageRequest{}, nil)
+	if err != nil {
+		t.Fatalf("expected success after waiting, got %v", err)
+	}
+	if next.calls != 2 {
+		t.Errorf("calls = %d, want 2", next.calls)
+	}
+	if len(*slept) != 1 || (*slept)[0] != 12*time.Second {
+		t.Errorf("slept %v, want [12s]", *slept)
+	}
+	if want := []time.Duration{12 * time.Second, 0}; len(*notified) != 2 || (*notified)[0] != want[0] || (*notified)[1] != want[1] {
+		t.Errorf("notified %v, want %v", *notified, want)
+	}
+
+	// Waits beyond the limit fail straight away.
+	next = &scriptedInvoker{errs: []error{tgerr.New(420, "FLOOD_WAIT_3600")}}
+	if err := m.Handle(next).Invoke(context.Background(), &tg.MessagesGetHistoryRequest{}, nil); err == nil {
+		t.Error("expected a long flood wait to fail")
+	}
+}
+
+func TestRetryMiddlewareRetriesOnlyIdempotentCalls(t *testing.T) {
+	m, _, slept := testMiddleware()
+	internal := func() error { return tgerr.New(500, "INTERNAL") }
+
+	next := &scriptedInvoker{errs: []error{internal(), internal()}}
+	if err := m.Handle(next).Invoke(context.Background(), &tg.MessagesGetHistoryRequest{}, nil); err != nil {
+		t.Fatalf("expected history fetch to succeed on retry, got %v", err)
+	}
+	if want := []time.Duration{retryBackoff, 2 * retryBackoff}; len(*slept) != 2 || (*slept)[0] != want[0] || (*slept)[1] != want[1] {
+		t.Errorf("backoff %v, want %v", *slept, want)
+	}
+
+	next = &scriptedInvoker{errs: []error{internal()}}
+	if err := m.Handle(next).Invoke(context.Background(), &tg.MessagesSendMessageRequest{}, nil); err == nil {
+		t.Error("expected send to fail without retry")
+	}
+	if next.calls != 1 {
+		t.Errorf("send calls = %d, want 1", next.calls)
+	}
+
+	next = &scriptedInvoker{errs: []error{internal(), internal(), internal(), internal()}}
+	if err := m.Handle(next).Invoke(context.Background(), &tg.MessagesGetHistoryRequest{}, nil); err == nil {
+		t.Error("expected failure once retries run out")
+	}
+	if next.calls != maxRetries+1 {
+		t.Errorf("calls = %d, want %d", next.calls, maxRetries+1)
+	}
+}
+
+func TestChainMiddlewaresWrapsInOrder(t *testing.T) {
+	c := &Client{ctx: context.Background()}
+	retry, notified, _ := testMiddleware()
+	c.setConnState(StateOffline, nil)
+
+	// A media DC download sits out its flood wait like any other call,
+	// and a failure while offline is still marked as such.
+	next := &scriptedInvoker{errs: []error{tgerr.New(420, "FLOOD_WAIT_3"), tgerr.New(400, "FILE_REFERENCE_EXPIRED")}}
+	err := chainMiddlewares(next, offlineMiddleware{c: c}, retry).Invoke(context.Background(), &tg.UploadGetFileRequest{}, nil)
+	if next.calls != 2 || len(*notified) == 0 || (*notified)[0] != 3*time.Second {
+		t.Errorf("expected the flood wait retried, calls = %d, notified %v", next.calls, *notified)
+	}
+	if !errors.Is(err, ErrOffline) || !tgerr.Is(err, "FILE_REFERENCE_EXPIRED") {
+		t.Errorf("expected the offline middleware outermost, got %v", err)
+	}
+}
+
+func TestLimiterSpreadsBursts(t *testing.T) {
+	l := &limiter{burst: 3, interval: 100 * time.Millisecond}
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		if d := l.reserve(now); d != 0 {
+			t.Fatalf("call %d within burst delayed by %v", i, d)
+		}
+	}
+	if d := l.reserve(now); d != 100*time.Millisecond {
+		t.Errorf("first call past burst delayed %v, want 100ms", d)
+	}
+	if d := l.reserve(now); d != 200*time.Millisecond {
+		t.Errorf("second call past burst delayed %v, want 200ms", d)
+	}
+	// Idle time refills the bucket.
+	if d := l.reserve(now.Add(time.Second)); d != 0 {
+		t.Errorf("call after idle delayed %v", d)
+	}
+}
@@ -0,0 +1,92 @@
+package telegram
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gotd/td/tg"
+)
+
+type PresenceKind int
+
+const (
+	PresenceUnknown PresenceKind = iota // hidden, bots, groups
+	PresenceOnline
+	PresenceOffline
+	PresenceRecently
+	PresenceLastWeek
+	PresenceLastMonth
+)
+
+// Presence is a user's online status as last reported by the server.
+type Presence struct {
+	Kind      PresenceKind
+	Expires   int // PresenceOnline: when the status lapses to offline
+	WasOnline int // PresenceOffline: when the user was last online
+}
+
+// UserStatusMsg reports a presence change of a user.
+type UserStatusMsg struct {
+	UserID   int64
+	Presence Presence
+}
+
+// Online reports whether the user is online at now. An online status
+// whose expiry has passed counts as offline since then.
+func (p Presence) Online(now time.Time) bool {
+	return p.Kind == PresenceOnline && now.Unix() < int64(p.Expires)
+}
+
+// Describe returns "online", "last seen 5m ago" and the like, or "" when
+// the status is unknown.
+func (p Presence) Describe(now time.Time) string {
+	switch p.Kind {
+	case PresenceOnline:
+		if p.Online(now) {
+			return "online"
+		}
+		return lastSeen(now, p.Expires)
+	case PresenceOffline:
+		return lastSeen(now, p.WasOnline)
+	case PresenceRecently:
+		return "last seen recently"
+	case PresenceLastWeek:
+		return "last seen within a week"
+	case PresenceLastMonth:
+		return "last seen within a month"
+	}
+	return ""
+}
+
+func lastSeen(now time.Time, unix int) string {
+	at := time.Unix(int64(unix), 0)
+	d := now.Sub(at)
+	switch {
+	case d < time.Minute:
+		return "last seen just now"
+	case d < time.Hour:
+		return fmt.Sprintf("last seen %dm ago", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("last seen %dh ago", int(d.Hours()))
+	case d < 7*24*time.Hour:
+		return "last seen " + at.Format("Mon 15:04")
+	default:
+		return "last seen " + at.Format("02/01/2006")
+	}
+}
+
+func convertUserStatus(status tg.UserStatusClass) Presence {
+	switch s := status.(type) {
+	case *tg.UserStatusOnline:
+		return Presence{Kind: PresenceOnline, Expires: s.Expires}
+	case *tg.UserStatusOffline:
+		return Presence{Kind: PresenceOffline, WasOnline: s.WasOnline}
+	case *tg.UserStatusRecently:
+		return Presence{Kind: PresenceRecently}
+	case *tg.UserStatusLastWeek:
+		return Presence{Kind: PresenceLastWeek}
+	case *tg.UserStatusLastMonth:
+		return Presence{Kind: PresenceLastMonth}
+	}
+	return Presence{}
+}
@@ -0,0 +1,236 @@
+package telegram
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gotd/td/mtproxy"
+	"github.com/gotd/td/telegram/dcs"
+	"golang.org/x/net/proxy"
+)
+
+// ProxyKind is the protocol spoken to a proxy.
+type ProxyKind int
+
+const (
+	ProxyNone ProxyKind = iota
+	ProxySOCKS5
+	ProxyHTTP    // HTTP CONNECT
+	ProxyMTProto // MTProxy, including fake-TLS secrets
+)
+
+func (k ProxyKind) String() string {
+	switch k {
+	case ProxySOCKS5:
+		return "SOCKS5 proxy"
+	case ProxyHTTP:
+		return "HTTP proxy"
+	case ProxyMTProto:
+		return "MTProxy"
+	default:
+		return "direct"
+	}
+}
+
+// Proxy is how connections to Telegram are made.
+type Proxy struct {
+	Kind     ProxyKind
+	Addr     string // host:port
+	User     string // SOCKS5 and HTTP only
+	Password string
+	Secret   []byte // MTProxy only, decoded
+}
+
+// ParseProxy parses a proxy given as a URL. An empty string means no
+// proxy. Accepted forms:
+//
+//	socks5://[user:pass@]host:port
+//	http://[user:pass@]host:port
+//	mtproxy://secret@host:port
+//	tg://proxy?server=host&port=443&secret=...   (and https://t.me/proxy?...)
+//	tg://socks?server=host&port=1080&user=...&pass=...
+//
+// MTProxy secrets may be hex or base64; "ee"-prefixed ones use fake TLS.
+func ParseProxy(s string) (*Proxy, error) {
+	if s == "" {
+		return &Proxy{Kind: ProxyNone}, nil
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy %q: %w", s, err)
+	}
+
+	p := &Proxy{}
+	switch {
+	case u.Scheme == "socks5" || u.Scheme == "socks5h":
+		p.Kind = ProxySOCKS5
+	case u.Scheme == "http":
+		p.Kind = ProxyHTTP
+	case u.Scheme == "mtproxy":
+		p.Kind = ProxyMTProto
+		if u.User == nil {
+			return nil, fmt.Errorf("invalid proxy %q: missing secret", s)
+		}
+		if p.Secret, err = parseProxySecret(u.User.Username()); err != nil {
+			return nil, err
+		}
+	case isShareLink(u, "proxy"):
+		q := u.Query()
+		p.Kind = ProxyMTProto
+		p.Addr = net.JoinHostPort(q.Get("server"), q.Get("port"))
+		if p.Secret, err = parseProxySecret(q.Get("secret")); err != nil {
+			return nil, err
+		}
+	case isShareLink(u, "socks"):
+		q := u.Query()
+		p.Kind = ProxySOCKS5
+		p.Addr = net.JoinHostPort(q.Get("server"), q.Get("port"))
+		p.User, p.Password = q.Get("user"), q.Get("pass")
+	default:
+		return nil, fmt.Errorf("unsupported proxy %q: use socks5://, http://, mtproxy:// or a tg://proxy link", s)
+	}
+
+	if p.Addr == "" {
+		p.Addr = u.Host
+		if p.Kind != ProxyMTProto && u.User != nil {
+			p.User = u.User.Username()
+			p.Password, _ = u.User.Password()
+		}
+	}
+	host, port, err := net.SplitHostPort(p.Addr)
+	if err != nil || host == "" || port == "" {
+		return nil, fmt.Errorf("invalid proxy %q: want host:port", s)
+	}
+	return p, nil
+}
+
+// isShareLink reports whether u is a tg://name or https://t.me/name link,
+// as shared by Telegram clients.
+func isShareLink(u *url.URL, name string) bool {
+	switch u.Scheme {
+	case "tg":
+		return u.Host == name
+	case "https":
+		return u.Host == "t.me" && u.Path == "/"+name
+	}
+	return false
+}
+
+func parseProxySecret(s string) ([]byte, error) {
+	secret, err := hex.DecodeString(s)
+	if err != nil {
+		secret, err = base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid MTProxy secret: not hex or base64")
+	}
+	if _, err := mtproxy.ParseSecret(secret); err != nil {
+		return nil, fmt.Errorf("invalid MTProxy secret: %w", err)
+	}
+	return secret, nil
+}
+
+func (p *Proxy) String() string {
+	if p.Kind == ProxyNone {
+		return p.Kind.String()
+	}
+	return p.Kind.String() + " " + p.Addr
+}
+
+// resolver returns the gotd resolver connecting through p, dialing with
+// dial.
+func (p *Proxy) resolver(dial dcs.DialFunc) (dcs.Resolver, error) {
+	if p.Kind == ProxyMTProto {
+		return dcs.MTProxy(p.Addr, p.Secret, dcs.MTProxyOptions{Dial: dial})
+	}
+	return dcs.Plain(dcs.PlainOptions{Dial: dial}), nil
+}
+
+// dialer returns the function opening TCP connections: to the proxy for
+// MTProxy, and through it to Telegram for SOCKS5 and HTTP.
+func (p *Proxy) dialer() (dcs.DialFunc, error) {
+	var d net.Dialer
+	switch p.Kind {
+	case ProxySOCKS5:
+		var auth *proxy.Auth
+		if p.User != "" {
+			auth = &proxy.Auth{User: p.User, Password: p.Password}
+		}
+		socks, err := proxy.SOCKS5("tcp", p.Addr, auth, &d)
+		if err != nil {
+			return nil, err
+		}
+		return socks.(proxy.ContextDialer).DialContext, nil
+	case ProxyHTTP:
+		return p.dialHTTP, nil
+	default:
+		return d.DialContext, nil
+	}
+}
+
+// dialHTTP opens a tunnel to addr with an HTTP CONNECT request.
+func (p *Proxy) dialHTTP(ctx context.Context, network, addr string) (net.Conn, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, p.Addr)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if p.User != "" {
+		creds := base64.StdEncoding.EncodeToString([]byte(p.User + ":" + p.Password))
+		req.Header.Set("Proxy-Authorization", "Basic "+creds)
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy refused tunnel: %s", resp.Status)
+	}
+	if !stop() {
+		return nil, ctx.Err()
+	}
+	conn.SetDeadline(time.Time{})
+	if br.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn is a tunnel whose first bytes were read along with the
+// proxy's response.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
@@ -0,0 +1,264 @@
+package telegram
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gotd/td/tg"
+)
+
+// SearchQuery is a search typed in the prompt: words to match, narrowed
+// down by optional filters.
+type SearchQuery struct {
+	Text   string
+	From   string    // sender's username, or "me"
+	Type   string    // one of SearchTypes, "" for any message
+	After  time.Time // messages on or after this day, zero for no bound
+	Before time.Time // messages before this day, zero for no bound
+}
+
+// SearchTypes lists the values of type: in a search query.
+var SearchTypes = []string{"photo", "video", "media", "file", "link", "gif", "voice", "music", "round", "location", "contact"}
+
+// searchFilters maps SearchTypes to the server's message filters.
+var searchFilters = map[string]tg.MessagesFilterClass{
+	"photo":    &tg.InputMessagesFilterPhotos{},
+	"video":    &tg.InputMessagesFilterVideo{},
+	"media":    &tg.InputMessagesFilterPhotoVideo{},
+	"file":     &tg.InputMessagesFilterDocument{},
+	"link":     &tg.InputMessagesFilterURL{},
+	"gif":      &tg.InputMessagesFilterGif{},
+	"voice":    &tg.InputMessagesFilterVoice{},
+	"music":    &tg.InputMessagesFilterMusic{},
+	"round":    &tg.InputMessagesFilterRoundVideo{},
+	"location": &tg.InputMessagesFilterGeo{},
+	"contact":  &tg.InputMessagesFilterContacts{},
+}
+
+const searchDateLayout = "2006-01-02"
+
+// ParseSearchQuery reads a query such as
+//
+//	report from:@bob type:file after:2025-03-01 before:2025-06-01
+//
+// Words other than the filters from:, type:, after: and before: are
+// searched for. Dates are days in local time; after: includes its day
+// and before: does not.
+func ParseSearchQuery(s string) (SearchQuery, error) {
+	var q SearchQuery
+	var words []string
+	for _, word := range strings.Fields(s) {
+		key, value, ok := strings.Cut(word, ":")
+		if !ok || !slices.Contains([]string{"from", "type", "after", "before"}, key) {
+			words = append(words, word)
+			continue
+		}
+		if value == "" {
+			return q, fmt.Errorf("%s: missing value", key)
+		}
+		switch key {
+		case "from":
+			q.From = strings.TrimPrefix(value, "@")
+		case "type":
+			if !slices.Contains(SearchTypes, value) {
+				return q, fmt.Errorf("type: unknown type %q, want one of %s", value, strings.Join(SearchTypes, ", "))
+			}
+			q.Type = value
+		case "after", "before":
+			day, err := time.ParseInLocation(searchDateLayout, value, time.Local)
+			if err != nil {
+				return q, fmt.Errorf("%s: invalid date %q, want YYYY-MM-DD", key, value)
+			}
+			if key == "after" {
+				q.After = day
+			} else {
+				q.Before = day
+			}
+		}
+	}
+	q.Text = strings.Join(words, " ")
+	if !q.After.IsZero() && !q.Before.IsZero() && !q.After.Before(q.Before) {
+		return q, fmt.Errorf("after: must be earlier than before:")
+	}
+	return q, nil
+}
+
+// String returns q in the syntax ParseSearchQuery reads.
+func (q SearchQuery) String() string {
+	var parts []string
+	if q.Text != "" {
+		parts = append(parts, q.Text)
+	}
+	if q.From != "" {
+		parts = append(parts, "from:"+q.From)
+	}
+	if q.Type != "" {
+		parts = append(parts, "type:"+q.Type)
+	}
+	if !q.After.IsZero() {
+		parts = append(parts, "after:"+q.After.Format(searchDateLayout))
+	}
+	if !q.Before.IsZero() {
+		parts = append(parts, "before:"+q.Before.Format(searchDateLayout))
+	}
+	return strings.Join(parts, " ")
+}
+
+// filter returns the server filter for q's type.
+func (q SearchQuery) filter() tg.MessagesFilterClass {
+	if f, ok := searchFilters[q.Type]; ok {
+		return f
+	}
+	return &tg.InputMessagesFilterEmpty{}
+}
+
+// resolveSender looks up the peer of a from: filter.
+func (c *Client) resolveSender(username string) (tg.InputPeerClass, error) {
+	if username == "me" {
+		return &tg.InputPeerSelf{}, nil
+	}
+	resolved, err := c.api.ContactsResolveUsername(c.ctx, &tg.ContactsResolveUsernameRequest{Username: username})
+	if err != nil {
+		return nil, fmt.Errorf("from: @%s: %w", username, err)
+	}
+	userMap := usersByID(resolved.Users)
+	chatMap, channelMap := chatsByID(resolved.Chats)
+	chat, ok := peerChat(resolved.Peer, userMap, chatMap, channelMap)
+	if !ok {
+		return nil, fmt.Errorf("from: @%s not found", username)
+	}
+	return c.chatToInputPeer(chat), nil
+}
+
+// globalSearchPageSize is how many results each MessagesSearchGlobal call
+// asks for.
+const globalSearchPageSize = 50
+
+// GlobalSearchCursor marks where the next page of a global search starts:
+// the server's next_rate and the chat and ID of the last result so far.
+type GlobalSearchCursor struct {
+	Query      string
+	OffsetRate int
+	OffsetPeer Chat
+	OffsetID   int
+}
+
+// SearchHit is a message found by a global search, with its chat.
+type SearchHit struct {
+	Chat    Chat
+	Message Message
+}
+
+// GlobalSearchResultMsg carries the first page of a search across all
+// chats, newest first.
+type GlobalSearchResultMsg struct {
+	Query   string
+	Hits    []SearchHit
+	Next    GlobalSearchCursor
+	HasMore bool
+}
+
+// GlobalSearchMoreMsg carries a further page of a global search.
+type GlobalSearchMoreMsg struct {
+	Query   string
+	Hits    []SearchHit
+	Next    GlobalSearchCursor
+	HasMore bool
+}
+
+type GlobalSearchErrorMsg struct {
+	Query string
+	Err   error
+}
+
+// SearchGlobal searches the messages of every chat for query.
+func (c *Client) SearchGlobal(query string) func() interface{} {
+	return func() interface{} {
+		hits, next, hasMore, err := c.searchGlobalPage(GlobalSearchCursor{Query: query})
+		if err != nil {
+			return GlobalSearchErrorMsg{Query: query, Err: err}
+		}
+		return GlobalSearchResultMsg{Query: query, Hits: hits, Next: next, HasMore: hasMore}
+	}
+}
+
+// SearchGlobalMore loads the page of global search results after cursor.
+func (c *Client) SearchGlobalMore(cursor GlobalSearchCursor) func() interface{} {
+	return func() interface{} {
+		hits, next, hasMore, err := c.searchGlobalPage(cursor)
+		if err != nil {
+			return GlobalSearchErrorMsg{Query: cursor.Query, Err: err}
+		}
+		return GlobalSearchMoreMsg{Query: cursor.Query, Hits: hits, Next: next, HasMore: hasMore}
+	}
+}
+
+// searchGlobalPage returns one page of results in server order, the
+// cursor for the page after it and whether there is one.
+func (c *Client) searchGlobalPage(cursor GlobalSearchCursor) ([]SearchHit, GlobalSearchCursor, bool, error) {
+	var offsetPeer tg.InputPeerClass = &tg.InputPeerEmpty{}
+	if cursor.OffsetPeer.ID != 0 {
+		offsetPeer = c.chatToInputPeer(cursor.OffsetPeer)
+	}
+
+	result, err := c.api.MessagesSearchGlobal(c.ctx, &tg.MessagesSearchGlobalRequest{
+		Q:          cursor.Query,
+		Filter:     &tg.InputMessagesFilterEmpty{},
+		OffsetRate: cursor.OffsetRate,
+		OffsetPeer: offsetPeer,
+		OffsetID:   cursor.OffsetID,
+		Limit:      globalSearchPageSize,
+	})
+	if err != nil {
+		return nil, GlobalSearchCursor{}, false, err
+	}
+
+	var (
+		messages []tg.MessageClass
+		users    []tg.UserClass
+		chats    []tg.ChatClass
+		nextRate int
+		hasMore  bool
+	)
+	switch r := result.(type) {
+	case *tg.MessagesMessages:
+		messages, users, chats = r.Messages, r.Users, r.Chats
+	case *tg.MessagesMessagesSlice:
+		messages, users, chats = r.Messages, r.Users, r.Chats
+		nextRate = r.NextRate
+		// A short page means the server has nothing more, whatever Count says.
+		hasMore = len(r.Messages) == globalSearchPageSize
+	}
+
+	userMap := usersByID(users)
+	chatMap, channelMap := chatsByID(chats)
+	var hits []SearchHit
+	for _, m := range messages {
+		msg, ok := m.(*tg.Message)
+		if !ok {
+			continue
+		}
+		chat, ok := peerChat(msg.PeerID, userMap, chatMap, channelMap)
+		if !ok {
+			if chat, ok = c.knownChat(extractChatID(msg.PeerID)); !ok {
+				continue
+			}
+		}
+		c.rememberChat(chat)
+		hits = append(hits, SearchHit{Chat: chat, Message: convertMessage(msg, chat.ID, userMap)})
+	}
+
+	if len(hits) == 0 {
+		return nil, GlobalSearchCursor{}, false, nil
+	}
+	last := hits[len(hits)-1]
+	next := GlobalSearchCursor{
+		Query:      cursor.Query,
+		OffsetRate: nextRate,
+		OffsetPeer: last.Chat,
+		OffsetID:   last.Message.ID,
+	}
+	return hits, next, hasMore, nil
+}
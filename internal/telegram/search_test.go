@@ -0,0 +1,109 @@
+package telegram
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/tg"
+)
+
+func TestParseSearchQuery(t *testing.T) {
+	day := func(m time.Month, d int) time.Time { return time.Date(2026, m, d, 0, 0, 0, 0, time.Local) }
+
+	tests := []struct {
+		in   string
+		want SearchQuery
+	}{
+		{"hello world", SearchQuery{Text: "hello world"}},
+		{"from:@alice  type:photo", SearchQuery{From: "alice", Type: "photo"}},
+		{"notes from:me after:2026-01-01 before:2026-02-01 draft", SearchQuery{Text: "notes draft", From: "me", After: day(1, 1), Before: day(2, 1)}},
+		{"see https://example.com", SearchQuery{Text: "see https://example.com"}},
+	}
+	for _, tt := range tests {
+		got, err := ParseSearchQuery(tt.in)
+		if err != nil {
+			t.Errorf("ParseSearchQuery(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseSearchQuery(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+		if again, _ := ParseSearchQuery(got.String()); again != got {
+			t.Errorf("%q does not parse back to %+v", got.String(), got)
+		}
+	}
+
+	for _, tt := range []struct{ in, want string }{
+		{"type:sticker", `unknown type "sticker"`},
+		{"before:yesterday", `invalid date "yesterday"`},
+		{"from:", "missing value"},
+		{"after:2026-02-01 before:2026-01-01", "earlier than"},
+	} {
+		if _, err := ParseSearchQuery(tt.in); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ParseSearchQuery(%q): got error %v, want %q", tt.in, err, tt.want)
+		}
+	}
+}
+
+// searchServer answers messages.search with full pages, newest first,
+// below the requested offset, and resolves any username to user 7.
+type searchServer struct {
+	resolves int
+	fromIDs  []tg.InputPeerClass
+}
+
+func (s *searchServer) Invoke(ctx context.Context, input bin.Encoder, output bin.Decoder) error {
+	var result bin.Encoder
+	switch req := input.(type) {
+	case *tg.ContactsResolveUsernameRequest:
+		s.resolves++
+		result = &tg.ContactsResolvedPeer{
+			Peer:  &tg.PeerUser{UserID: 7},
+			Users: []tg.UserClass{&tg.User{ID: 7, AccessHash: 77, FirstName: "Bob"}},
+		}
+	case *tg.MessagesSearchRequest:
+		s.fromIDs = append(s.fromIDs, req.FromID)
+		top := req.OffsetID
+		if top == 0 {
+			top = 1000
+		}
+		var msgs []tg.MessageClass
+		for id := top - 1; id >= top-req.Limit; id-- {
+			msgs = append(msgs, &tg.Message{ID: id, PeerID: &tg.PeerUser{UserID: 42}, FromID: &tg.PeerUser{UserID: 7}})
+		}
+		result = &tg.MessagesMessagesSlice{Count: 1000, Messages: msgs}
+	}
+	var b bin.Buffer
+	if err := result.Encode(&b); err != nil {
+		return err
+	}
+	return output.Decode(&b)
+}
+
+func TestSearchResolvesSenderOnce(t *testing.T) {
+	srv := &searchServer{}
+	c := &Client{ctx: context.Background(), api: tg.NewClient(srv)}
+	chat := Chat{ID: 42, Type: ChatTypePrivate}
+
+	first, ok := c.SearchHistory(chat, SearchQuery{Text: "report", From: "bob"})().(SearchResultMsg)
+	if !ok || !first.HasMore || first.Next.OffsetID != first.Messages[0].ID {
+		t.Fatalf("expected a full first page with a cursor below it, got %+v", first)
+	}
+	more, ok := c.SearchHistoryMore(chat, first.Next)().(SearchMoreMsg)
+	if !ok || more.Messages[len(more.Messages)-1].ID >= first.Messages[0].ID {
+		t.Fatalf("expected the page before the first, got %+v", more)
+	}
+	c.SearchHistoryMore(chat, more.Next)()
+
+	if srv.resolves != 1 {
+		t.Errorf("expected the username resolved once, got %d lookups", srv.resolves)
+	}
+	for i, from := range srv.fromIDs {
+		if peer, ok := from.(*tg.InputPeerUser); !ok || peer.UserID != 7 || peer.AccessHash != 77 {
+			t.Errorf("page %d: expected from_id of user 7, got %#v", i+1, from)
+		}
+	}
+}
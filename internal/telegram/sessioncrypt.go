@@ -0,0 +1,228 @@
+package telegram
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/gotd/td/session"
+	"golang.org/x/crypto/scrypt"
+)
+
+// ErrWrongPassphrase is returned when an encrypted session does not open
+// with the given passphrase.
+var ErrWrongPassphrase = errors.New("wrong passphrase")
+
+// scrypt cost for new sessions: about 100ms on a laptop. Existing files
+// keep the parameters they were written with.
+const (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+// encryptedSession is the on-disk form of an EncryptedSessionStorage. The
+// KDF field is what tells it apart from a plaintext session file.
+type encryptedSession struct {
+	KDF   *kdfParams `json:"kdf"`
+	Nonce []byte     `json:"nonce"`
+	Data  []byte     `json:"data"` // session sealed with AES-256-GCM
+}
+
+type kdfParams struct {
+	Name string `json:"name"`
+	N    int    `json:"n"`
+	R    int    `json:"r"`
+	P    int    `json:"p"`
+	Salt []byte `json:"salt"`
+}
+
+// EncryptedSessionStorage keeps the session sealed with a key derived
+// from a passphrase, so the auth key is useless to whoever copies the
+// file. Create it with OpenEncryptedSession.
+type EncryptedSessionStorage struct {
+	Path string
+
+	kdf kdfParams
+	key []byte
+}
+
+// OpenEncryptedSession derives the key for the session at path. An
+// existing file must decrypt with passphrase, or ErrWrongPassphrase is
+// returned; a missing one is created encrypted on the first store.
+func OpenEncryptedSession(path, passphrase string) (*EncryptedSessionStorage, error) {
+	stored, err := readEncryptedSession(path)
+	if errors.Is(err, session.ErrNotFound) {
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, err
+		}
+		s := &EncryptedSessionStorage{
+			Path: path,
+			kdf:  kdfParams{Name: "scrypt", N: scryptN, R: scryptR, P: scryptP, Salt: salt},
+		}
+		if s.key, err = s.kdf.derive(passphrase); err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s := &EncryptedSessionStorage{Path: path, kdf: *stored.KDF}
+	if s.key, err = s.kdf.derive(passphrase); err != nil {
+		return nil, err
+	}
+	if _, err := s.open(stored); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *EncryptedSessionStorage) LoadSession(_ context.Context) ([]byte, error) {
+	stored, err := readEncryptedSession(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	return s.open(stored)
+}
+
+func (s *EncryptedSessionStorage) StoreSession(_ context.Context, data []byte) error {
+	gcm, err := s.cipher()
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	kdf := s.kdf
+	out, err := json.Marshal(encryptedSession{
+		KDF:   &kdf,
+		Nonce: nonce,
+		Data:  gcm.Seal(nil, nonce, data, nil),
+	})
+	if err != nil {
+		return err
+	}
+	tmp := s.Path + ".tmp"
+	if err := os.WriteFile(tmp, out, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.Path)
+}
+
+func (s *EncryptedSessionStorage) open(stored *encryptedSession) ([]byte, error) {
+	gcm, err := s.cipher()
+	if err != nil {
+		return nil, err
+	}
+	if len(stored.Nonce) != gcm.NonceSize() {
+		return nil, fmt.Errorf("corrupt session file %s", s.Path)
+	}
+	data, err := gcm.Open(nil, stored.Nonce, stored.Data, nil)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	return data, nil
+}
+
+func (s *EncryptedSessionStorage) cipher() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(s.key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Bounds on the scrypt parameters read from a session file, so a damaged
+// one cannot make deriving the key take minutes or gigabytes. The largest
+// allowed costs 1 GiB of memory, 32 times the default.
+const (
+	maxScryptN = 1 << 20
+	maxScryptR = 8
+	maxScryptP = 4
+)
+
+func (p kdfParams) derive(passphrase string) ([]byte, error) {
+	if p.Name != "scrypt" {
+		return nil, fmt.Errorf("unsupported key derivation %q", p.Name)
+	}
+	if p.N < 2 || p.N > maxScryptN || p.N&(p.N-1) != 0 || p.R < 1 || p.R > maxScryptR || p.P < 1 || p.P > maxScryptP {
+		return nil, fmt.Errorf("invalid scrypt parameters N=%d r=%d p=%d", p.N, p.R, p.P)
+	}
+	return scrypt.Key([]byte(passphrase), p.Salt, p.N, p.R, p.P, 32)
+}
+
+// readEncryptedSession reads the session at path, returning
+// session.ErrNotFound when there is none and an error when it is stored
+// in plaintext.
+func readEncryptedSession(path string) (*encryptedSession, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, session.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var stored encryptedSession
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, err
+	}
+	if stored.KDF == nil {
+		return nil, fmt.Errorf("session %s is not encrypted", path)
+	}
+	return &stored, nil
+}
+
+// SessionEncrypted reports whether the session at path is stored
+// encrypted. A missing session is not.
+func SessionEncrypted(path string) (bool, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	var stored encryptedSession
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return false, err
+	}
+	return stored.KDF != nil, nil
+}
+
+// EncryptSession rewrites the plaintext session at path encrypted with
+// passphrase.
+func EncryptSession(path, passphrase string) error {
+	encrypted, err := SessionEncrypted(path)
+	if err != nil {
+		return err
+	}
+	if encrypted {
+		return fmt.Errorf("session %s is already encrypted", path)
+	}
+	plain := &FileSessionStorage{Path: path}
+	data, err := plain.LoadSession(context.Background())
+	if errors.Is(err, session.ErrNotFound) {
+		return fmt.Errorf("no session at %s; log in first", path)
+	}
+	if err != nil {
+		return err
+	}
+	os.Remove(path + ".new") // left over from an interrupted run
+	s, err := OpenEncryptedSession(path+".new", passphrase)
+	if err != nil {
+		return err
+	}
+	if err := s.StoreSession(context.Background(), data); err != nil {
+		return err
+	}
+	return os.Rename(s.Path, path)
+}
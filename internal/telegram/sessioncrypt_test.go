@@ -0,0 +1,79 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEncryptSessionMigratesPlaintext(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "session.json")
+	authKey := []byte("mtproto auth key")
+
+	plain := &FileSessionStorage{Path: path}
+	if err := plain.StoreSession(ctx, authKey); err != nil {
+		t.Fatal(err)
+	}
+	if err := EncryptSession(path, "hunter2"); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(raw, authKey) || bytes.Contains(raw, []byte("bXRwcm90byBhdXRoIGtleQ")) {
+		t.Error("session file still contains the auth key in the clear")
+	}
+	if encrypted, err := SessionEncrypted(path); err != nil || !encrypted {
+		t.Fatalf("SessionEncrypted = %v, %v; want true", encrypted, err)
+	}
+	if err := EncryptSession(path, "hunter2"); err == nil {
+		t.Error("expected encrypting twice to fail")
+	}
+
+	if _, err := OpenEncryptedSession(path, "wrong"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("wrong passphrase: got %v, want ErrWrongPassphrase", err)
+	}
+
+	s, err := OpenEncryptedSession(path, "hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.LoadSession(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, authKey) {
+		t.Errorf("LoadSession = %q, want %q", got, authKey)
+	}
+
+	// Later stores from the client stay readable with the same passphrase.
+	if err := s.StoreSession(ctx, []byte("rotated key")); err != nil {
+		t.Fatal(err)
+	}
+	s, err = OpenEncryptedSession(path, "hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.LoadSession(ctx); string(got) != "rotated key" {
+		t.Errorf("after store: LoadSession = %q", got)
+	}
+}
+
+func TestDeriveRejectsCostlyParameters(t *testing.T) {
+	for _, p := range []kdfParams{
+		{Name: "scrypt", N: 1 << 30, R: scryptR, P: scryptP},
+		{Name: "scrypt", N: 1<<15 + 1, R: scryptR, P: scryptP},
+		{Name: "scrypt", N: scryptN, R: 1 << 20, P: scryptP},
+		{Name: "scrypt", N: scryptN, R: scryptR, P: 0},
+	} {
+		if _, err := p.derive("hunter2"); err == nil {
+			t.Errorf("derive with N=%d r=%d p=%d: expected an error", p.N, p.R, p.P)
+		}
+	}
+}
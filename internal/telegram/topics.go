@@ -0,0 +1,174 @@
+package telegram
+
+import (
+	"github.com/gotd/td/tg"
+)
+
+// GeneralTopicID is the topic every forum has; its messages carry no
+// topic reply header.
+const GeneralTopicID = 1
+
+// topicsPageSize is how many topics each MessagesGetForumTopics call asks for.
+const topicsPageSize = 100
+
+// Topic is a thread of a forum supergroup. Only General can be Hidden:
+// collapsed into the forum by its admins, but still open for messages.
+type Topic struct {
+	ID             int
+	Title          string
+	UnreadCount    int
+	ReadInboxMaxID int
+	Pinned         bool
+	Closed         bool
+	Hidden         bool
+	LastMessage    *Message
+}
+
+// TopicsCursor marks where the next page of a forum's topics starts: the
+// last topic of the previous page and its latest message.
+type TopicsCursor struct {
+	OffsetDate  int
+	OffsetID    int
+	OffsetTopic int
+}
+
+// ForumTopicsLoadedMsg carries the first page of a forum's topics.
+type ForumTopicsLoadedMsg struct {
+	ChatID  int64
+	Topics  []Topic
+	Next    TopicsCursor
+	HasMore bool
+}
+
+type ForumTopicsErrorMsg struct {
+	ChatID int64
+	Err    error
+}
+
+// MoreTopicsLoadedMsg carries a further page of a forum's topics.
+type MoreTopicsLoadedMsg struct {
+	ChatID  int64
+	Topics  []Topic
+	Next    TopicsCursor
+	HasMore bool
+}
+
+type MoreTopicsErrorMsg struct {
+	ChatID int64
+	Err    error
+}
+
+// FetchForumTopics loads the first page of a forum's topics, pinned first
+// and then by latest activity.
+func (c *Client) FetchForumTopics(chat Chat) func() interface{} {
+	return func() interface{} {
+		topics, next, hasMore, err := c.fetchTopicsPage(chat, TopicsCursor{})
+		if err != nil {
+			return ForumTopicsErrorMsg{ChatID: chat.ID, Err: err}
+		}
+		return ForumTopicsLoadedMsg{ChatID: chat.ID, Topics: topics, Next: next, HasMore: hasMore}
+	}
+}
+
+// FetchMoreForumTopics loads the page of a forum's topics after cursor.
+func (c *Client) FetchMoreForumTopics(chat Chat, cursor TopicsCursor) func() interface{} {
+	return func() interface{} {
+		topics, next, hasMore, err := c.fetchTopicsPage(chat, cursor)
+		if err != nil {
+			return MoreTopicsErrorMsg{ChatID: chat.ID, Err: err}
+		}
+		return MoreTopicsLoadedMsg{ChatID: chat.ID, Topics: topics, Next: next, HasMore: hasMore}
+	}
+}
+
+// fetchTopicsPage returns one page of topics in server order, the cursor
+// for the page after it and whether there is one.
+func (c *Client) fetchTopicsPage(chat Chat, cursor TopicsCursor) ([]Topic, TopicsCursor, bool, error) {
+	result, err := c.api.MessagesGetForumTopics(c.ctx, &tg.MessagesGetForumTopicsRequest{
+		Peer:        c.chatToInputPeer(chat),
+		OffsetDate:  cursor.OffsetDate,
+		OffsetID:    cursor.OffsetID,
+		OffsetTopic: cursor.OffsetTopic,
+		Limit:       topicsPageSize,
+	})
+	if err != nil {
+		return nil, TopicsCursor{}, false, err
+	}
+
+	userMap := make(map[int64]*tg.User)
+	for _, u := range result.Users {
+		if user, ok := u.(*tg.User); ok {
+			userMap[user.ID] = user
+		}
+	}
+	msgMap := make(map[int]*tg.Message)
+	for _, m := range result.Messages {
+		if msg, ok := m.(*tg.Message); ok {
+			msgMap[msg.ID] = msg
+		}
+	}
+
+	var (
+		topics []Topic
+		next   TopicsCursor
+	)
+	for _, t := range result.Topics {
+		topic, ok := t.(*tg.ForumTopic)
+		if !ok {
+			continue
+		}
+		converted := Topic{
+			ID:             topic.ID,
+			Title:          topic.Title,
+			UnreadCount:    topic.UnreadCount,
+			ReadInboxMaxID: topic.ReadInboxMaxID,
+			Pinned:         topic.Pinned,
+			Closed:         topic.Closed,
+			Hidden:         topic.Hidden,
+		}
+		next = TopicsCursor{OffsetDate: topic.Date, OffsetID: topic.TopMessage, OffsetTopic: topic.ID}
+		if msg, exists := msgMap[topic.TopMessage]; exists {
+			last := convertMessage(msg, chat.ID, userMap)
+			converted.LastMessage = &last
+			next.OffsetDate = msg.Date
+		}
+		topics = append(topics, converted)
+	}
+	// A short page means the server has nothing more, whatever Count says.
+	hasMore := len(result.Topics) == topicsPageSize && next.OffsetTopic != 0
+	return topics, next, hasMore, nil
+}
+
+// getHistory runs a history request against the whole chat or, for a
+// forum topic, against the topic's thread.
+func (c *Client) getHistory(chat Chat, req *tg.MessagesGetHistoryRequest) (tg.MessagesMessagesClass, error) {
+	if chat.TopicID == 0 {
+		return c.api.MessagesGetHistory(c.ctx, req)
+	}
+	return c.api.MessagesGetReplies(c.ctx, &tg.MessagesGetRepliesRequest{
+		Peer:       req.Peer,
+		MsgID:      chat.TopicID,
+		OffsetID:   req.OffsetID,
+		OffsetDate: req.OffsetDate,
+		AddOffset:  req.AddOffset,
+		Limit:      req.Limit,
+	})
+}
+
+// topicReplyTo builds the reply header that posts into chat's topic,
+// optionally replying to a message inside it. It returns nil when no
+// header is needed.
+func topicReplyTo(chat Chat, replyToID int) *tg.InputReplyToMessage {
+	reply := &tg.InputReplyToMessage{ReplyToMsgID: replyToID}
+	if chat.TopicID != 0 && chat.TopicID != GeneralTopicID {
+		if replyToID == 0 {
+			reply.ReplyToMsgID = chat.TopicID
+		} else {
+			reply.SetTopMsgID(chat.TopicID)
+		}
+	}
+	if reply.ReplyToMsgID == 0 {
+		return nil
+	}
+	return reply
+}
@@ -0,0 +1,69 @@
+package telegram
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/tg"
+)
+
+// topicsServer answers messages.getForumTopics with topics count..1,
+// newest first, each with its own latest message; General is hidden.
+type topicsServer struct {
+	count    int
+	requests []*tg.MessagesGetForumTopicsRequest
+}
+
+func (s *topicsServer) Invoke(ctx context.Context, input bin.Encoder, output bin.Decoder) error {
+	req := input.(*tg.MessagesGetForumTopicsRequest)
+	s.requests = append(s.requests, req)
+	top := s.count
+	if req.OffsetTopic != 0 {
+		top = req.OffsetTopic - 1
+	}
+	result := &tg.MessagesForumTopics{Count: s.count}
+	for id := top; id > top-req.Limit && id > 0; id-- {
+		result.Topics = append(result.Topics, &tg.ForumTopic{
+			ID:         id,
+			Peer:       &tg.PeerChannel{ChannelID: 77},
+			FromID:     &tg.PeerUser{UserID: 7},
+			Title:      "topic",
+			TopMessage: 1000 + id,
+			Hidden:     id == GeneralTopicID,
+		})
+		result.Messages = append(result.Messages, &tg.Message{ID: 1000 + id, Date: 5000 + id, PeerID: &tg.PeerChannel{ChannelID: 77}})
+	}
+	var b bin.Buffer
+	if err := result.Encode(&b); err != nil {
+		return err
+	}
+	return output.Decode(&b)
+}
+
+func TestForumTopicsPages(t *testing.T) {
+	srv := &topicsServer{count: 150}
+	c := &Client{ctx: context.Background(), api: tg.NewClient(srv)}
+	forum := Chat{ID: 77, AccessHash: 5, Type: ChatTypeGroup, Forum: true}
+
+	got := c.FetchForumTopics(forum)()
+	first, ok := got.(ForumTopicsLoadedMsg)
+	if !ok || len(first.Topics) != topicsPageSize || !first.HasMore {
+		t.Fatalf("expected a full first page, got %+v", got)
+	}
+	if want := (TopicsCursor{OffsetDate: 5051, OffsetID: 1051, OffsetTopic: 51}); first.Next != want {
+		t.Errorf("cursor = %+v, want %+v", first.Next, want)
+	}
+
+	got = c.FetchMoreForumTopics(forum, first.Next)()
+	more, ok := got.(MoreTopicsLoadedMsg)
+	if !ok || len(more.Topics) != 50 || more.HasMore {
+		t.Fatalf("expected the last 50 topics, got %+v", got)
+	}
+	if req := srv.requests[1]; req.OffsetTopic != 51 || req.OffsetID != 1051 || req.OffsetDate != 5051 {
+		t.Errorf("second request did not continue from the cursor: %+v", req)
+	}
+	if general := more.Topics[len(more.Topics)-1]; general.ID != GeneralTopicID || !general.Hidden {
+		t.Errorf("expected hidden General listed and marked, got %+v", general)
+	}
+}
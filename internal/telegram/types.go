@@ -1,6 +1,10 @@
 package telegram
 
-import "github.com/gotd/td/tg"
+import (
+	"time"
+
+	"github.com/gotd/td/tg"
+)
 
 type ChatType int
 
@@ -11,13 +15,40 @@ const (
 )
 
 type Chat struct {
-	ID          int64
-	AccessHash  int64
-	Title       string
-	Type        ChatType
-	UnreadCount int
-	Pinned      bool
-	LastMessage *Message
+	ID             int64
+	AccessHash     int64
+	Title          string
+	Type           ChatType
+	UnreadCount    int
+	ReadInboxMaxID int      // last incoming message we have read
+	Presence       Presence // private chats only
+	Pinned         bool
+	FolderID       int  // ArchiveFolderID for archived chats
+	Contact        bool // private chats with users in our contacts
+	Bot            bool
+	MutedUntil     int // unix time notifications are muted until
+	Forum          bool
+	TopicID        int // open forum topic, 0 for the whole chat
+	LastMessage    *Message
+}
+
+// Muted reports whether notifications for the chat are muted at now.
+func (chat Chat) Muted(now time.Time) bool {
+	return int64(chat.MutedUntil) > now.Unix()
+}
+
+// IsChannelPeer reports whether the chat is a channel or supergroup, which
+// have their own message ID space and always delete for everyone.
+func (chat Chat) IsChannelPeer() bool {
+	return chat.Type == ChatTypeChannel || (chat.Type == ChatTypeGroup && chat.AccessHash != 0)
+}
+
+// InTopic reports whether the message was posted in a forum topic.
+func (msg Message) InTopic(topicID int) bool {
+	if msg.TopicID == 0 {
+		return topicID == GeneralTopicID
+	}
+	return msg.TopicID == topicID
 }
 
 type MediaType int
@@ -55,6 +86,9 @@ type MediaInfo struct {
 	DocAccessHash int64
 	DocFileRef    []byte
 	DocDCID       int
+	// ChatID is the chat of the message carrying the media, used to
+	// refetch it when the file reference expires.
+	ChatID int64
 }
 
 type Reaction struct {
@@ -70,6 +104,9 @@ type Message struct {
 	Sender    string
 	Text      string
 	Date      int
+	EditDate  int // 0 if never edited
+	ReplyToID int // parent message in the same chat, 0 if not a reply
+	TopicID   int // forum topic, 0 outside forums and in the General topic
 	Out       bool
 	Entities  []tg.MessageEntityClass
 	Media     *MediaInfo
@@ -0,0 +1,71 @@
+package telegram
+
+import "github.com/gotd/td/tg"
+
+// TypingMsg reports a chat action by another user. An empty Action means
+// the user stopped.
+type TypingMsg struct {
+	ChatID int64
+	UserID int64
+	Name   string
+	Action string // "typing", "sending a photo", ...
+}
+
+// SetTyping tells the chat that we are typing. Telegram clears the status
+// after a few seconds, so callers repeat it while the user keeps typing.
+func (c *Client) SetTyping(chat Chat) func() interface{} {
+	return func() interface{} {
+		req := &tg.MessagesSetTypingRequest{
+			Peer:   c.chatToInputPeer(chat),
+			Action: &tg.SendMessageTypingAction{},
+		}
+		if chat.TopicID != 0 {
+			req.SetTopMsgID(chat.TopicID)
+		}
+		// Best effort: a lost typing notification is not worth reporting.
+		_, _ = c.api.MessagesSetTyping(c.ctx, req)
+		return nil
+	}
+}
+
+func (c *Client) sendTyping(chatID int64, from tg.PeerClass, action tg.SendMessageActionClass, users map[int64]*tg.User) {
+	peer, ok := from.(*tg.PeerUser)
+	if !ok || peer.UserID == c.selfID {
+		return
+	}
+	name := ""
+	if u, exists := users[peer.UserID]; exists {
+		name = displayName(u.FirstName, u.LastName)
+	}
+	c.send(TypingMsg{
+		ChatID: chatID,
+		UserID: peer.UserID,
+		Name:   name,
+		Action: describeTypingAction(action),
+	})
+}
+
+func describeTypingAction(action tg.SendMessageActionClass) string {
+	switch action.(type) {
+	case *tg.SendMessageCancelAction:
+		return ""
+	case *tg.SendMessageRecordAudioAction:
+		return "recording a voice message"
+	case *tg.SendMessageRecordVideoAction, *tg.SendMessageRecordRoundAction:
+		return "recording a video"
+	case *tg.SendMessageUploadPhotoAction:
+		return "sending a photo"
+	case *tg.SendMessageUploadVideoAction, *tg.SendMessageUploadRoundAction:
+		return "sending a video"
+	case *tg.SendMessageUploadAudioAction:
+		return "sending a voice message"
+	case *tg.SendMessageUploadDocumentAction:
+		return "sending a file"
+	case *tg.SendMessageChooseStickerAction:
+		return "choosing a sticker"
+	case *tg.SendMessageGamePlayAction:
+		return "playing a game"
+	default:
+		return "typing"
+	}
+}
@@ -3,6 +3,7 @@ package telegram
 import (
 	"context"
 
+	"github.com/gotd/td/telegram/updates"
 	"github.com/gotd/td/tg"
 )
 
@@ -10,12 +11,43 @@ type NewMessageMsg struct {
 	Message Message
 }
 
+// MessageEditedMsg carries the new state of an edited message, either from
+// a live update or as the result of our own EditMessage call.
+type MessageEditedMsg struct {
+	Message Message
+}
+
 type ReactionsUpdatedMsg struct {
 	ChatID    int64
 	MsgID     int
 	Reactions []Reaction
 }
 
+// HistoryGapMsg reports that updates for a channel were lost and could not
+// be recovered, so its loaded history may be missing messages.
+type HistoryGapMsg struct {
+	ChatID int64
+}
+
+// UpdatesErrorMsg reports that the updates manager stopped; no further
+// live updates arrive until restart.
+type UpdatesErrorMsg struct {
+	Err error
+}
+
+// startUpdates runs the updates manager for the logged-in user. It first
+// catches up from the stored state with getDifference, then checks every
+// incoming update for gaps.
+func (c *Client) startUpdates() {
+	go func() {
+		err := c.updates.Run(c.ctx, c.api, c.selfID, updates.AuthOptions{})
+		if err != nil && c.ctx.Err() == nil {
+			c.send(UpdatesErrorMsg{Err: err})
+		}
+	}()
+	go c.watchConnection()
+}
+
 func (c *Client) setupHandlers(dispatcher tg.UpdateDispatcher) {
 	dispatcher.OnNewMessage(func(ctx context.Context, e tg.Entities, update *tg.UpdateNewMessage) error {
 		msg, ok := update.Message.(*tg.Message)
@@ -23,72 +55,127 @@ func (c *Client) setupHandlers(dispatcher tg.UpdateDispatcher) {
 			return nil
 		}
 
-		sender := ""
-		senderID := int64(0)
-		if msg.FromID != nil {
-			if peer, ok := msg.FromID.(*tg.PeerUser); ok {
-				senderID = peer.UserID
-				if u, exists := e.Users[peer.UserID]; exists {
-					sender = displayName(u.FirstName, u.LastName)
-				}
-			}
+		chatID := extractChatID(msg.PeerID)
+
+		c.send(NewMessageMsg{
+			Message: convertMessage(msg, chatID, e.Users),
+		})
+		return nil
+	})
+
+	dispatcher.OnNewChannelMessage(func(ctx context.Context, e tg.Entities, update *tg.UpdateNewChannelMessage) error {
+		msg, ok := update.Message.(*tg.Message)
+		if !ok {
+			return nil
 		}
 
 		chatID := extractChatID(msg.PeerID)
 
 		c.send(NewMessageMsg{
-			Message: Message{
-				ID:       msg.ID,
-				ChatID:   chatID,
-				SenderID: senderID,
-				Sender:   sender,
-				Text:     msg.Message,
-				Date:     msg.Date,
-				Out:      msg.Out,
-				Entities: msg.Entities,
-				Media:     extractMediaInfo(msg.Media),
-				Reactions: extractReactions(msg.Reactions),
-			},
+			Message: convertMessage(msg, chatID, e.Users),
 		})
 		return nil
 	})
 
-	dispatcher.OnNewChannelMessage(func(ctx context.Context, e tg.Entities, update *tg.UpdateNewChannelMessage) error {
+	dispatcher.OnEditMessage(func(ctx context.Context, e tg.Entities, update *tg.UpdateEditMessage) error {
 		msg, ok := update.Message.(*tg.Message)
 		if !ok {
 			return nil
 		}
 
-		sender := ""
-		senderID := int64(0)
-		if msg.FromID != nil {
-			if peer, ok := msg.FromID.(*tg.PeerUser); ok {
-				senderID = peer.UserID
-				if u, exists := e.Users[peer.UserID]; exists {
-					sender = displayName(u.FirstName, u.LastName)
-				}
-			}
+		c.send(MessageEditedMsg{
+			Message: convertMessage(msg, extractChatID(msg.PeerID), e.Users),
+		})
+		return nil
+	})
+
+	dispatcher.OnEditChannelMessage(func(ctx context.Context, e tg.Entities, update *tg.UpdateEditChannelMessage) error {
+		msg, ok := update.Message.(*tg.Message)
+		if !ok {
+			return nil
 		}
 
-		chatID := extractChatID(msg.PeerID)
+		c.send(MessageEditedMsg{
+			Message: convertMessage(msg, extractChatID(msg.PeerID), e.Users),
+		})
+		return nil
+	})
 
-		c.send(NewMessageMsg{
-			Message: Message{
-				ID:       msg.ID,
-				ChatID:   chatID,
-				SenderID: senderID,
-				Sender:   sender,
-				Text:     msg.Message,
-				Date:     msg.Date,
-				Out:      msg.Out,
-				Entities: msg.Entities,
-				Media:     extractMediaInfo(msg.Media),
-				Reactions: extractReactions(msg.Reactions),
-			},
+	dispatcher.OnDeleteMessages(func(ctx context.Context, e tg.Entities, update *tg.UpdateDeleteMessages) error {
+		c.send(MessagesDeletedMsg{IDs: update.Messages})
+		return nil
+	})
+
+	dispatcher.OnDeleteChannelMessages(func(ctx context.Context, e tg.Entities, update *tg.UpdateDeleteChannelMessages) error {
+		c.send(MessagesDeletedMsg{ChatID: update.ChannelID, IDs: update.Messages})
+		return nil
+	})
+
+	dispatcher.OnReadHistoryInbox(func(ctx context.Context, e tg.Entities, update *tg.UpdateReadHistoryInbox) error {
+		c.send(InboxReadMsg{
+			ChatID:      extractChatID(update.Peer),
+			MaxID:       update.MaxID,
+			StillUnread: update.StillUnreadCount,
+		})
+		return nil
+	})
+
+	dispatcher.OnReadChannelInbox(func(ctx context.Context, e tg.Entities, update *tg.UpdateReadChannelInbox) error {
+		c.send(InboxReadMsg{
+			ChatID:      update.ChannelID,
+			MaxID:       update.MaxID,
+			StillUnread: update.StillUnreadCount,
+		})
+		return nil
+	})
+
+	dispatcher.OnUserTyping(func(ctx context.Context, e tg.Entities, update *tg.UpdateUserTyping) error {
+		c.sendTyping(update.UserID, &tg.PeerUser{UserID: update.UserID}, update.Action, e.Users)
+		return nil
+	})
+
+	dispatcher.OnChatUserTyping(func(ctx context.Context, e tg.Entities, update *tg.UpdateChatUserTyping) error {
+		c.sendTyping(update.ChatID, update.FromID, update.Action, e.Users)
+		return nil
+	})
+
+	dispatcher.OnChannelUserTyping(func(ctx context.Context, e tg.Entities, update *tg.UpdateChannelUserTyping) error {
+		c.sendTyping(update.ChannelID, update.FromID, update.Action, e.Users)
+		return nil
+	})
+
+	dispatcher.OnUserStatus(func(ctx context.Context, e tg.Entities, update *tg.UpdateUserStatus) error {
+		c.send(UserStatusMsg{
+			UserID:   update.UserID,
+			Presence: convertUserStatus(update.Status),
 		})
 		return nil
 	})
 
+	dispatcher.OnFolderPeers(func(ctx context.Context, e tg.Entities, update *tg.UpdateFolderPeers) error {
+		for _, fp := range update.FolderPeers {
+			c.send(ChatFolderChangedMsg{
+				ChatID:   extractChatID(fp.Peer),
+				FolderID: fp.FolderID,
+			})
+		}
+		return nil
+	})
+
+	// Folder edits on other devices: reload the whole list, it is small.
+	dispatcher.OnDialogFilter(func(ctx context.Context, e tg.Entities, update *tg.UpdateDialogFilter) error {
+		go func() { c.send(c.FetchFolders()()) }()
+		return nil
+	})
+	dispatcher.OnDialogFilters(func(ctx context.Context, e tg.Entities, update *tg.UpdateDialogFilters) error {
+		go func() { c.send(c.FetchFolders()()) }()
+		return nil
+	})
+	dispatcher.OnDialogFilterOrder(func(ctx context.Context, e tg.Entities, update *tg.UpdateDialogFilterOrder) error {
+		go func() { c.send(c.FetchFolders()()) }()
+		return nil
+	})
+
 	dispatcher.OnMessageReactions(func(ctx context.Context, e tg.Entities, update *tg.UpdateMessageReactions) error {
 		chatID := extractChatID(update.Peer)
 		c.send(ReactionsUpdatedMsg{
@@ -0,0 +1,230 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gotd/td/telegram/updates"
+)
+
+// stateFlushDelay is how long changes to the update state wait in memory
+// before being written, so a burst of updates costs one write.
+const stateFlushDelay = time.Second
+
+// FileStateStorage keeps the update sequence state (pts, qts, seq, date
+// and per-channel pts) and channel access hashes in a JSON file, so the
+// updates manager can fetch whatever was missed while tgtui was closed.
+// Changes are kept in memory and written stateFlushDelay after the first
+// one, or by Flush at shutdown.
+type FileStateStorage struct {
+	Path string
+
+	mu     sync.Mutex
+	loaded bool
+	data   stateFile
+	dirty  bool        // data has changes not yet written
+	flush  *time.Timer // scheduled write, nil when none is pending
+}
+
+var (
+	_ updates.StateStorage        = (*FileStateStorage)(nil)
+	_ updates.ChannelAccessHasher = (*FileStateStorage)(nil)
+)
+
+type stateFile struct {
+	States       map[int64]updates.State   `json:"states"`
+	Channels     map[int64]map[int64]int   `json:"channels"`
+	AccessHashes map[int64]map[int64]int64 `json:"access_hashes"`
+}
+
+// view runs f on the loaded state.
+func (s *FileStateStorage) view(f func(d *stateFile)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return err
+	}
+	f(&s.data)
+	return nil
+}
+
+// update runs f on the loaded state and schedules writing the result back.
+func (s *FileStateStorage) update(f func(d *stateFile) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return err
+	}
+	if err := f(&s.data); err != nil {
+		return err
+	}
+	s.dirty = true
+	if s.flush == nil {
+		s.flush = time.AfterFunc(stateFlushDelay, func() { s.Flush() })
+	}
+	return nil
+}
+
+// Flush writes pending changes now. After a failed write the changes stay
+// pending, so the next update or Flush tries again.
+func (s *FileStateStorage) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.flush != nil {
+		s.flush.Stop()
+		s.flush = nil
+	}
+	if !s.dirty {
+		return nil
+	}
+	if err := s.save(); err != nil {
+		return err
+	}
+	s.dirty = false
+	return nil
+}
+
+func (s *FileStateStorage) load() error {
+	if s.loaded {
+		return nil
+	}
+	data, err := os.ReadFile(s.Path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &s.data); err != nil {
+			return fmt.Errorf("read update state: %w", err)
+		}
+	}
+	if s.data.States == nil {
+		s.data.States = make(map[int64]updates.State)
+	}
+	if s.data.Channels == nil {
+		s.data.Channels = make(map[int64]map[int64]int)
+	}
+	if s.data.AccessHashes == nil {
+		s.data.AccessHashes = make(map[int64]map[int64]int64)
+	}
+	s.loaded = true
+	return nil
+}
+
+// save writes through a temporary file so a crash never leaves a
+// truncated state behind.
+func (s *FileStateStorage) save() error {
+	out, err := json.Marshal(s.data)
+	if err != nil {
+		return err
+	}
+	tmp := s.Path + ".tmp"
+	if err := os.WriteFile(tmp, out, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.Path)
+}
+
+func (s *FileStateStorage) GetState(_ context.Context, userID int64) (state updates.State, found bool, err error) {
+	err = s.view(func(d *stateFile) {
+		state, found = d.States[userID]
+	})
+	return state, found, err
+}
+
+func (s *FileStateStorage) SetState(_ context.Context, userID int64, state updates.State) error {
+	return s.update(func(d *stateFile) error {
+		d.States[userID] = state
+		d.Channels[userID] = make(map[int64]int)
+		return nil
+	})
+}
+
+// setField changes one field of an existing state.
+func (s *FileStateStorage) setField(userID int64, set func(st *updates.State)) error {
+	return s.update(func(d *stateFile) error {
+		state, ok := d.States[userID]
+		if !ok {
+			return fmt.Errorf("no update state for user %d", userID)
+		}
+		set(&state)
+		d.States[userID] = state
+		return nil
+	})
+}
+
+func (s *FileStateStorage) SetPts(_ context.Context, userID int64, pts int) error {
+	return s.setField(userID, func(st *updates.State) { st.Pts = pts })
+}
+
+func (s *FileStateStorage) SetQts(_ context.Context, userID int64, qts int) error {
+	return s.setField(userID, func(st *updates.State) { st.Qts = qts })
+}
+
+func (s *FileStateStorage) SetDate(_ context.Context, userID int64, date int) error {
+	return s.setField(userID, func(st *updates.State) { st.Date = date })
+}
+
+func (s *FileStateStorage) SetSeq(_ context.Context, userID int64, seq int) error {
+	return s.setField(userID, func(st *updates.State) { st.Seq = seq })
+}
+
+func (s *FileStateStorage) SetDateSeq(_ context.Context, userID int64, date, seq int) error {
+	return s.setField(userID, func(st *updates.State) { st.Date, st.Seq = date, seq })
+}
+
+func (s *FileStateStorage) GetChannelPts(_ context.Context, userID, channelID int64) (pts int, found bool, err error) {
+	err = s.view(func(d *stateFile) {
+		pts, found = d.Channels[userID][channelID]
+	})
+	return pts, found, err
+}
+
+func (s *FileStateStorage) SetChannelPts(_ context.Context, userID, channelID int64, pts int) error {
+	return s.update(func(d *stateFile) error {
+		channels, ok := d.Channels[userID]
+		if !ok {
+			return fmt.Errorf("no update state for user %d", userID)
+		}
+		channels[channelID] = pts
+		return nil
+	})
+}
+
+func (s *FileStateStorage) ForEachChannels(ctx context.Context, userID int64, f func(ctx context.Context, channelID int64, pts int) error) error {
+	var channels map[int64]int
+	if err := s.view(func(d *stateFile) {
+		channels = make(map[int64]int, len(d.Channels[userID]))
+		for id, pts := range d.Channels[userID] {
+			channels[id] = pts
+		}
+	}); err != nil {
+		return err
+	}
+	for id, pts := range channels {
+		if err := f(ctx, id, pts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *FileStateStorage) SetChannelAccessHash(_ context.Context, userID, channelID, accessHash int64) error {
+	return s.update(func(d *stateFile) error {
+		if d.AccessHashes[userID] == nil {
+			d.AccessHashes[userID] = make(map[int64]int64)
+		}
+		d.AccessHashes[userID][channelID] = accessHash
+		return nil
+	})
+}
+
+func (s *FileStateStorage) GetChannelAccessHash(_ context.Context, userID, channelID int64) (accessHash int64, found bool, err error) {
+	err = s.view(func(d *stateFile) {
+		accessHash, found = d.AccessHashes[userID][channelID]
+	})
+	return accessHash, found, err
+}
@@ -0,0 +1,74 @@
+package telegram
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gotd/td/telegram/updates"
+)
+
+func TestFileStateStoragePersists(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "updates.json")
+
+	s := &FileStateStorage{Path: path}
+	if err := s.SetPts(ctx, 1, 10); err == nil {
+		t.Error("expected SetPts to fail before any state is stored")
+	}
+	if err := s.SetState(ctx, 1, updates.State{Pts: 10, Qts: 2, Date: 100, Seq: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetPts(ctx, 1, 15); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetDateSeq(ctx, 1, 200, 4); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetChannelPts(ctx, 1, 777, 50); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetChannelAccessHash(ctx, 1, 777, 99); err != nil {
+		t.Fatal(err)
+	}
+
+	// Changes stay in memory until flushed.
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected no write before Flush, got %v", err)
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A fresh instance, as after a restart, sees everything.
+	s = &FileStateStorage{Path: path}
+	state, found, err := s.GetState(ctx, 1)
+	if err != nil || !found {
+		t.Fatalf("GetState: found=%v err=%v", found, err)
+	}
+	if want := (updates.State{Pts: 15, Qts: 2, Date: 200, Seq: 4}); state != want {
+		t.Errorf("state = %+v, want %+v", state, want)
+	}
+	if pts, found, _ := s.GetChannelPts(ctx, 1, 777); !found || pts != 50 {
+		t.Errorf("channel pts = %d (found %v), want 50", pts, found)
+	}
+	if hash, found, _ := s.GetChannelAccessHash(ctx, 1, 777); !found || hash != 99 {
+		t.Errorf("access hash = %d (found %v), want 99", hash, found)
+	}
+	var channels []int64
+	if err := s.ForEachChannels(ctx, 1, func(_ context.Context, id int64, _ int) error {
+		channels = append(channels, id)
+		return nil
+	}); err != nil || len(channels) != 1 || channels[0] != 777 {
+		t.Errorf("ForEachChannels = %v, %v", channels, err)
+	}
+
+	// Resetting the state forgets channel positions.
+	if err := s.SetState(ctx, 1, updates.State{Pts: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, found, _ := s.GetChannelPts(ctx, 1, 777); found {
+		t.Error("expected channel pts cleared by SetState")
+	}
+}
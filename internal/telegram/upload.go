@@ -0,0 +1,187 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/gotd/td/telegram/uploader"
+	"github.com/gotd/td/tg"
+)
+
+// SendAs selects how an attached file is sent.
+type SendAs int
+
+const (
+	SendAsDocument SendAs = iota
+	SendAsPhoto
+	SendAsVideo
+	SendAsVoice
+)
+
+func (a SendAs) String() string {
+	switch a {
+	case SendAsPhoto:
+		return "photo"
+	case SendAsVideo:
+		return "video"
+	case SendAsVoice:
+		return "voice"
+	default:
+		return "document"
+	}
+}
+
+// SendFileOptions holds optional parameters for SendFile.
+type SendFileOptions struct {
+	As        SendAs
+	Caption   string
+	ReplyToID int
+}
+
+// UploadProgressMsg reports upload progress of a file being sent.
+type UploadProgressMsg struct {
+	ChatID   int64
+	Name     string
+	Uploaded int64
+	Total    int64
+}
+
+type FileSentMsg struct {
+	ChatID int64
+	Name   string
+}
+
+type FileSendErrorMsg struct {
+	Name string
+	Err  error
+}
+
+// DetectMIME guesses a file's MIME type from its extension, falling back
+// to sniffing its first bytes.
+func DetectMIME(path string) string {
+	if t := mime.TypeByExtension(strings.ToLower(filepath.Ext(path))); t != "" {
+		return t
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "application/octet-stream"
+	}
+	defer f.Close()
+	head := make([]byte, 512)
+	n, _ := f.Read(head)
+	return http.DetectContentType(head[:n])
+}
+
+// DefaultSendAs picks how a file of the given MIME type is sent unless the
+// user chooses otherwise.
+func DefaultSendAs(mimeType string) SendAs {
+	mimeType, _, _ = strings.Cut(mimeType, ";")
+	switch {
+	case mimeType == "image/jpeg" || mimeType == "image/png" || mimeType == "image/webp":
+		return SendAsPhoto
+	case strings.HasPrefix(mimeType, "video/"):
+		return SendAsVideo
+	case mimeType == "audio/ogg" || mimeType == "audio/opus":
+		return SendAsVoice
+	default:
+		return SendAsDocument
+	}
+}
+
+// SendFile uploads a local file and sends it to chat, reporting progress
+// with UploadProgressMsg while the upload runs.
+func (c *Client) SendFile(chat Chat, path string, opts SendFileOptions) func() interface{} {
+	return func() interface{} {
+		name := filepath.Base(path)
+		f, err := os.Open(path)
+		if err != nil {
+			return FileSendErrorMsg{Name: name, Err: err}
+		}
+		defer f.Close()
+		stat, err := f.Stat()
+		if err != nil {
+			return FileSendErrorMsg{Name: name, Err: err}
+		}
+		if stat.IsDir() {
+			return FileSendErrorMsg{Name: name, Err: fmt.Errorf("%s is a directory", path)}
+		}
+
+		progress := &uploadProgress{c: c, chatID: chat.ID}
+		up := uploader.NewUploader(c.api).WithProgress(progress)
+		file, err := up.Upload(c.ctx, uploader.NewUpload(name, f, stat.Size()))
+		if err != nil {
+			return FileSendErrorMsg{Name: name, Err: err}
+		}
+
+		mimeType := DetectMIME(path)
+		var media tg.InputMediaClass
+		switch opts.As {
+		case SendAsPhoto:
+			media = &tg.InputMediaUploadedPhoto{File: file}
+		default:
+			attrs := []tg.DocumentAttributeClass{&tg.DocumentAttributeFilename{FileName: name}}
+			switch opts.As {
+			case SendAsVideo:
+				attrs = append(attrs, &tg.DocumentAttributeVideo{SupportsStreaming: true})
+			case SendAsVoice:
+				attrs = append(attrs, &tg.DocumentAttributeAudio{Voice: true})
+			}
+			doc := &tg.InputMediaUploadedDocument{
+				File:       file,
+				MimeType:   mimeType,
+				Attributes: attrs,
+			}
+			// Without this, the server would turn e.g. a PNG sent as a
+			// document back into a compressed photo.
+			doc.ForceFile = opts.As == SendAsDocument
+			media = doc
+		}
+
+		req := &tg.MessagesSendMediaRequest{
+			Peer:     c.chatToInputPeer(chat),
+			Media:    media,
+			Message:  opts.Caption,
+			RandomID: randomID(),
+		}
+		if reply := topicReplyTo(chat, opts.ReplyToID); reply != nil {
+			req.SetReplyTo(reply)
+		}
+		if _, err := c.api.MessagesSendMedia(c.ctx, req); err != nil {
+			return FileSendErrorMsg{Name: name, Err: err}
+		}
+		return FileSentMsg{ChatID: chat.ID, Name: name}
+	}
+}
+
+// uploadProgress forwards uploader progress to the UI, once per percent.
+// Parts of big files upload in parallel, so Chunk may run concurrently.
+type uploadProgress struct {
+	c      *Client
+	chatID int64
+
+	mu      sync.Mutex
+	percent int64
+}
+
+func (p *uploadProgress) Chunk(ctx context.Context, state uploader.ProgressState) error {
+	if state.Total <= 0 {
+		return nil
+	}
+	percent := state.Uploaded * 100 / state.Total
+	p.mu.Lock()
+	changed := percent > p.percent
+	if changed {
+		p.percent = percent
+	}
+	p.mu.Unlock()
+	if changed {
+		p.c.send(UploadProgressMsg{ChatID: p.chatID, Name: state.Name, Uploaded: state.Uploaded, Total: state.Total})
+	}
+	return nil
+}
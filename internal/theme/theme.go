@@ -0,0 +1,380 @@
+// Package theme loads color themes: a palette and the named styles the
+// UI and message formatting draw with. Themes are TOML files; the
+// built-in ones are embedded.
+package theme
+
+import (
+	"embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+//go:embed themes/*.toml
+var builtin embed.FS
+
+// Builtin lists the themes that need no file.
+var Builtin = []string{"dark", "light", "high-contrast"}
+
+// Theme is a loaded theme. Every color and style is set.
+type Theme struct {
+	Name string
+
+	// Palette, for borders, cursors and the like
+	Primary   lipgloss.TerminalColor
+	Secondary lipgloss.TerminalColor
+	Muted     lipgloss.TerminalColor
+	Error     lipgloss.TerminalColor
+	Warning   lipgloss.TerminalColor
+
+	// Named styles
+	Title      Style
+	Selected   Style
+	MutedText  Style
+	ErrorText  Style
+	Sender     Style
+	SenderSelf Style
+	Timestamp  Style
+	Unread     Style
+	MediaLabel Style
+	Code       Style // inline code
+	Pre        Style // code blocks
+	Link       Style
+	LinkURL    Style // the target shown after a text link
+	Mention    Style
+	Hashtag    Style // also bot commands and cashtags
+	Quote      Style
+	Spoiler    Style
+	Match      Style // search matches
+}
+
+func (t *Theme) colors() map[string]*lipgloss.TerminalColor {
+	return map[string]*lipgloss.TerminalColor{
+		"primary":   &t.Primary,
+		"secondary": &t.Secondary,
+		"muted":     &t.Muted,
+		"error":     &t.Error,
+		"warning":   &t.Warning,
+	}
+}
+
+func (t *Theme) styles() map[string]*Style {
+	return map[string]*Style{
+		"title":       &t.Title,
+		"selected":    &t.Selected,
+		"muted":       &t.MutedText,
+		"error":       &t.ErrorText,
+		"sender":      &t.Sender,
+		"sender_self": &t.SenderSelf,
+		"timestamp":   &t.Timestamp,
+		"unread":      &t.Unread,
+		"media_label": &t.MediaLabel,
+		"code":        &t.Code,
+		"pre":         &t.Pre,
+		"link":        &t.Link,
+		"link_url":    &t.LinkURL,
+		"mention":     &t.Mention,
+		"hashtag":     &t.Hashtag,
+		"quote":       &t.Quote,
+		"spoiler":     &t.Spoiler,
+		"match":       &t.Match,
+	}
+}
+
+// Style is a named style. Nil colors leave the terminal's own.
+type Style struct {
+	Fg, Bg    lipgloss.TerminalColor
+	Bold      bool
+	Italic    bool
+	Underline bool
+	Reverse   bool
+}
+
+// Lipgloss returns s as a lipgloss style, which lipgloss renders in the
+// terminal's color profile.
+func (s Style) Lipgloss() lipgloss.Style {
+	st := lipgloss.NewStyle().
+		Bold(s.Bold).
+		Italic(s.Italic).
+		Underline(s.Underline).
+		Reverse(s.Reverse)
+	if s.Fg != nil {
+		st = st.Foreground(s.Fg)
+	}
+	if s.Bg != nil {
+		st = st.Background(s.Bg)
+	}
+	return st
+}
+
+// SGR returns the SGR parameters of s for raw escape sequences, with
+// colors degraded to what profile p supports. termenv.Ascii, chosen when
+// NO_COLOR is set, keeps only the attributes.
+func (s Style) SGR(p termenv.Profile) []string {
+	var codes []string
+	if s.Bold {
+		codes = append(codes, "1")
+	}
+	if s.Italic {
+		codes = append(codes, "3")
+	}
+	if s.Underline {
+		codes = append(codes, "4")
+	}
+	if s.Reverse {
+		codes = append(codes, "7")
+	}
+	if seq := colorSequence(p, s.Fg, false); seq != "" {
+		codes = append(codes, seq)
+	}
+	if seq := colorSequence(p, s.Bg, true); seq != "" {
+		codes = append(codes, seq)
+	}
+	return codes
+}
+
+func colorSequence(p termenv.Profile, c lipgloss.TerminalColor, bg bool) string {
+	var s string
+	switch c := c.(type) {
+	case lipgloss.Color:
+		s = string(c)
+	case lipgloss.CompleteColor:
+		switch p {
+		case termenv.TrueColor:
+			s = c.TrueColor
+		case termenv.ANSI256:
+			s = c.ANSI256
+		default:
+			s = c.ANSI
+		}
+	default:
+		return ""
+	}
+	tc := p.Color(s)
+	if tc == nil {
+		return ""
+	}
+	return tc.Sequence(bg)
+}
+
+// Default returns the built-in dark theme.
+func Default() *Theme {
+	t, err := Load("dark", "")
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// Load returns the theme called name: a built-in one, a file if name is
+// a path ending in .toml, or else the file name.toml in dir.
+func Load(name, dir string) (*Theme, error) {
+	if slices.Contains(Builtin, name) {
+		data, err := builtin.ReadFile("themes/" + name + ".toml")
+		if err != nil {
+			return nil, err
+		}
+		return parse(name, string(data))
+	}
+
+	var path string
+	switch {
+	case strings.HasSuffix(name, ".toml"):
+		path = name
+		if strings.HasPrefix(path, "~/") {
+			if home, err := os.UserHomeDir(); err == nil {
+				path = filepath.Join(home, path[2:])
+			}
+		}
+	case dir != "" && !strings.ContainsAny(name, `/\`):
+		path = filepath.Join(dir, name+".toml")
+	}
+	data, err := os.ReadFile(path)
+	if path == "" || (os.IsNotExist(err) && path != name) {
+		return nil, fmt.Errorf("unknown theme %q: want %s, a .toml file or a theme in %s", name, strings.Join(Builtin, ", "), dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+	t, err := parse(strings.TrimSuffix(filepath.Base(path), ".toml"), string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return t, nil
+}
+
+// parse reads a theme file, which must set every color and style:
+//
+//	[colors]
+//	primary = "#7AA2F7"
+//
+//	[styles]
+//	sender = { fg = "#9ECE6A", bold = true }
+//	spoiler = { reverse = true }
+//	link = "#7AA2F7"   # a color alone sets fg
+func parse(name, data string) (*Theme, error) {
+	var raw map[string]interface{}
+	if _, err := toml.Decode(data, &raw); err != nil {
+		return nil, err
+	}
+	for key := range raw {
+		if key != "colors" && key != "styles" {
+			return nil, fmt.Errorf("%s: unknown key, want [colors] and [styles]", key)
+		}
+	}
+
+	t := &Theme{Name: name}
+	colors, _ := raw["colors"].(map[string]interface{})
+	fields := t.colors()
+	if err := checkKeys("colors", colors, fields); err != nil {
+		return nil, err
+	}
+	for key, field := range fields {
+		c, err := parseColor(colors[key])
+		if err != nil {
+			return nil, fmt.Errorf("colors.%s: %w", key, err)
+		}
+		*field = c
+	}
+
+	styles, _ := raw["styles"].(map[string]interface{})
+	styleFields := t.styles()
+	if err := checkKeys("styles", styles, styleFields); err != nil {
+		return nil, err
+	}
+	for key, field := range styleFields {
+		s, err := parseStyle(styles[key])
+		if err != nil {
+			return nil, fmt.Errorf("styles.%s: %w", key, err)
+		}
+		*field = s
+	}
+	return t, nil
+}
+
+// checkKeys reports the first missing or unknown key of a table.
+func checkKeys[V any](table string, got map[string]interface{}, want map[string]V) error {
+	var missing []string
+	for key := range want {
+		if _, ok := got[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		slices.Sort(missing)
+		return fmt.Errorf("%s.%s: missing; a theme sets every one of its keys", table, missing[0])
+	}
+	for key := range got {
+		if _, ok := want[key]; !ok {
+			return fmt.Errorf("%s.%s: unknown key", table, key)
+		}
+	}
+	return nil
+}
+
+func parseStyle(v interface{}) (Style, error) {
+	var s Style
+	switch v := v.(type) {
+	case string:
+		c, err := parseColor(v)
+		s.Fg = c
+		return s, err
+	case map[string]interface{}:
+		for key, val := range v {
+			var err error
+			switch key {
+			case "fg":
+				s.Fg, err = parseColor(val)
+			case "bg":
+				s.Bg, err = parseColor(val)
+			case "bold":
+				s.Bold, err = parseBool(val)
+			case "italic":
+				s.Italic, err = parseBool(val)
+			case "underline":
+				s.Underline, err = parseBool(val)
+			case "reverse":
+				s.Reverse, err = parseBool(val)
+			default:
+				err = fmt.Errorf("unknown key, want fg, bg, bold, italic, underline or reverse")
+			}
+			if err != nil {
+				return s, fmt.Errorf("%s: %w", key, err)
+			}
+		}
+		return s, nil
+	}
+	return s, fmt.Errorf("expected a color or a table, got %v", v)
+}
+
+func parseBool(v interface{}) (bool, error) {
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("expected true or false, got %v", v)
+	}
+	return b, nil
+}
+
+var hexColor = regexp.MustCompile(`^#[0-9A-Fa-f]{6}$`)
+
+// parseColor reads a color: "#RRGGBB", an ANSI color number "0" to
+// "255", or a table giving each profile's color explicitly:
+//
+//	{ truecolor = "#7AA2F7", ansi256 = "111", ansi = "12" }
+//
+// Other colors are degraded to the terminal's profile automatically.
+func parseColor(v interface{}) (lipgloss.TerminalColor, error) {
+	switch v := v.(type) {
+	case string:
+		if err := checkColor(v, 255); err != nil {
+			return nil, err
+		}
+		return lipgloss.Color(v), nil
+	case map[string]interface{}:
+		var c lipgloss.CompleteColor
+		for key, val := range v {
+			s, ok := val.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s: expected a string, got %v", key, val)
+			}
+			var err error
+			switch key {
+			case "truecolor":
+				c.TrueColor, err = s, checkColor(s, 255)
+			case "ansi256":
+				c.ANSI256, err = s, checkColor(s, 255)
+			case "ansi":
+				c.ANSI, err = s, checkColor(s, 15)
+			default:
+				err = fmt.Errorf("unknown key, want truecolor, ansi256 and ansi")
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+		}
+		if c.TrueColor == "" || c.ANSI256 == "" || c.ANSI == "" {
+			return nil, fmt.Errorf("set all of truecolor, ansi256 and ansi")
+		}
+		return c, nil
+	}
+	return nil, fmt.Errorf("expected a color, got %v", v)
+}
+
+// checkColor accepts "#RRGGBB" or a color number up to maxIndex.
+func checkColor(s string, maxIndex int) error {
+	if hexColor.MatchString(s) {
+		return nil
+	}
+	if n, err := strconv.Atoi(s); err == nil && n >= 0 && n <= maxIndex {
+		return nil
+	}
+	return fmt.Errorf("invalid color %q, want \"#RRGGBB\" or 0-%d", s, maxIndex)
+}
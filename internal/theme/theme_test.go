@@ -0,0 +1,103 @@
+package theme
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+func TestBuiltin(t *testing.T) {
+	for _, name := range Builtin {
+		th, err := Load(name, "")
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		for key, s := range th.styles() {
+			if *s == (Style{}) {
+				t.Errorf("%s: styles.%s is empty", name, key)
+			}
+		}
+	}
+}
+
+// minimal is a theme file setting every key.
+func minimal() string {
+	var sb strings.Builder
+	sb.WriteString("[colors]\n")
+	for key := range (&Theme{}).colors() {
+		sb.WriteString(key + " = \"#FFFFFF\"\n")
+	}
+	sb.WriteString("[styles]\n")
+	for key := range (&Theme{}).styles() {
+		sb.WriteString(key + " = \"7\"\n")
+	}
+	return sb.String()
+}
+
+func TestLoadFile(t *testing.T) {
+	dir := t.TempDir()
+	data := strings.Replace(minimal(), "code = \"7\"",
+		`code = { fg = { truecolor = "#FF9E64", ansi256 = "215", ansi = "3" }, bold = true }`, 1)
+	if err := os.WriteFile(filepath.Join(dir, "mine.toml"), []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	th, err := Load("mine", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if th.Name != "mine" {
+		t.Errorf("name: got %q", th.Name)
+	}
+	want := lipgloss.CompleteColor{TrueColor: "#FF9E64", ANSI256: "215", ANSI: "3"}
+	if th.Code.Fg != want || !th.Code.Bold {
+		t.Errorf("code: got %+v", th.Code)
+	}
+	if _, err := Load(filepath.Join(dir, "mine.toml"), ""); err != nil {
+		t.Errorf("by path: %v", err)
+	}
+	if _, err := Load("other", dir); err == nil || !strings.Contains(err.Error(), `unknown theme "other"`) {
+		t.Errorf("missing theme: got %v", err)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, tc := range []struct {
+		from, to string
+		want     string
+	}{
+		{"spoiler = \"7\"\n", "", "styles.spoiler: missing"},
+		{"primary = \"#FFFFFF\"\n", "primary = \"#FFFFFF\"\naccent = \"1\"\n", "colors.accent: unknown key"},
+		{"link = \"7\"\n", "link = \"blue\"\n", `styles.link: invalid color "blue"`},
+		{"link = \"7\"\n", "link = { fg = \"7\", blink = true }\n", "styles.link: blink: unknown key"},
+		{"error = \"#FFFFFF\"\n", "error = { truecolor = \"#FF0000\" }\n", "colors.error: set all of"},
+	} {
+		_, err := parse("test", strings.Replace(minimal(), tc.from, tc.to, 1))
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("got error %v, want %q", err, tc.want)
+		}
+	}
+}
+
+func TestSGR(t *testing.T) {
+	s := Style{Fg: lipgloss.Color("#FF8700"), Bg: lipgloss.Color("0"), Underline: true}
+
+	if got := s.SGR(termenv.TrueColor); !slices.Equal(got, []string{"4", "38;2;255;135;0", "40"}) {
+		t.Errorf("truecolor: got %v", got)
+	}
+	if got := s.SGR(termenv.ANSI256); !slices.Equal(got, []string{"4", "38;5;208", "40"}) {
+		t.Errorf("256 colors: got %v", got)
+	}
+	if got := s.SGR(termenv.ANSI); len(got) != 3 || !strings.HasPrefix(got[1], "9") {
+		t.Errorf("16 colors: got %v", got)
+	}
+	// NO_COLOR keeps the attributes only.
+	if got := s.SGR(termenv.Ascii); !slices.Equal(got, []string{"4"}) {
+		t.Errorf("no color: got %v", got)
+	}
+}
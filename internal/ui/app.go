@@ -2,13 +2,18 @@ package ui
 
 import (
 	"fmt"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/paramon-tech/tgtui/internal/config"
 	"github.com/paramon-tech/tgtui/internal/telegram"
 	"github.com/paramon-tech/tgtui/internal/ui/auth"
 	"github.com/paramon-tech/tgtui/internal/ui/chatlist"
 	"github.com/paramon-tech/tgtui/internal/ui/chatview"
+	"github.com/paramon-tech/tgtui/internal/ui/common"
+	"github.com/paramon-tech/tgtui/internal/ui/keymap"
+	"github.com/paramon-tech/tgtui/internal/ui/search"
 	"github.com/paramon-tech/tgtui/internal/ui/statusbar"
 )
 
@@ -28,32 +33,59 @@ const (
 )
 
 type App struct {
-	tg            *telegram.Client
+	tg            telegram.Backend
 	screen        screen
 	focus         focusPane
 	auth          auth.Model
 	chatList      chatlist.Model
 	chatView      chatview.Model
+	search        search.Model // global search, shown in place of chatView
 	statusBar     statusbar.Model
 	selectedChat  *telegram.Chat
 	width, height int
 	fatalErr      error
+	conn          ConnectionStateMsg // latest connection state
+	listPercent   int                // chat list width, percent of the terminal
+	keys          *keymap.Keymap
+	pending       keymap.Sequence // keys typed towards a multi-key binding
+	showHelp      bool
 	// Forward flow state
 	forwardFromChat   *telegram.Chat
 	forwardMessageIDs []int
 }
 
-func NewApp(tg *telegram.Client) App {
+func NewApp(tg telegram.Backend) App {
 	return App{
-		tg:        tg,
-		screen:    screenLoading,
-		auth:      auth.New(tg),
-		chatList:  chatlist.New(),
-		chatView:  chatview.New(tg),
-		statusBar: statusbar.New(),
+		tg:          tg,
+		screen:      screenLoading,
+		auth:        auth.New(tg),
+		chatList:    chatlist.New(),
+		chatView:    chatview.New(tg),
+		search:      search.New(tg),
+		statusBar:   statusbar.New(),
+		listPercent: 30,
+		keys:        keymap.Default(),
 	}
 }
 
+// ApplyConfig takes the UI settings from cfg. Its key bindings are
+// checked when the config is loaded; should they be invalid anyway the
+// current ones stay.
+func (a App) ApplyConfig(cfg *config.Config) App {
+	a.listPercent = cfg.ChatListWidth
+	a.chatView = a.chatView.ApplyConfig(cfg)
+	a.search = a.search.ApplyConfig(cfg)
+	if keys, err := keymap.New(cfg.Keymap, cfg.Keys); err == nil {
+		a.keys = keys
+		a.pending = nil
+		a.chatList = a.chatList.SetKeymap(keys)
+		a.chatView = a.chatView.SetKeymap(keys)
+		a.search = a.search.SetKeymap(keys)
+	}
+	a.updateSizes()
+	return a
+}
+
 func (a App) Init() tea.Cmd {
 	return nil
 }
@@ -63,80 +95,102 @@ func (a App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		if msg.String() == "ctrl+c" {
-			return a, tea.Quit
-		}
-
-		if a.screen == screenMain {
-			if msg.String() == "tab" {
-				if a.isPickingForwardDest() || a.chatView.IsSearching() {
-					return a, nil
-				}
-				a.toggleFocus()
-				a.updateMode()
-				return a, nil
-			}
-			if msg.String() == "esc" {
-				if a.isPickingForwardDest() {
-					a.forwardFromChat = nil
-					a.forwardMessageIDs = nil
-					a.chatList = a.chatList.SetPickingForwardDest(false)
-					a.chatView = a.chatView.CancelSelection()
-					a.focus = focusChatView
-					a.chatList = a.chatList.SetFocus(false)
-					a.chatView = a.chatView.SetFocus(true)
-					a.updateMode()
-					a.statusBar, _ = a.statusBar.Update(StatusMsg{Text: "Forward cancelled"})
-					return a, nil
-				}
-				// Let chatview handle Esc when searching or showing search results
-				if a.focus == focusChatView && (a.chatView.IsSearching() || a.chatView.HasSearchResults()) {
-					break
-				}
-				if a.focus == focusChatView {
-					if a.chatView.InputFocused() {
-						a.chatView = a.chatView.SetInputFocus(false)
-						a.updateMode()
-						return a, nil
-					}
-					if a.chatView.HasExpanded() {
-						a.chatView = a.chatView.CollapseExpanded()
-						return a, nil
-					}
-				}
-			}
+		if a.showHelp {
+			a.showHelp = false
+			return a, nil
 		}
+		a, cmd := a.handleKey(msg)
+		return a, cmd
 
 	case tea.WindowSizeMsg:
 		a.width = msg.Width
 		a.height = msg.Height
 		a.updateSizes()
 
-	case NeedAuthMsg:
+	case NeedAuthMsg, NeedPassphraseMsg:
 		a.screen = screenAuth
 
+	case SessionUnlockedMsg:
+		// Connecting resumes; a login, if needed, starts from the top.
+		a.screen = screenLoading
+		a.auth = auth.New(a.tg).SetSize(a.width, a.height)
+		return a, nil
+
+	case ConnectionStateMsg:
+		a.conn = msg
+		a.statusBar, _ = a.statusBar.Update(msg)
+		return a, nil
+
 	case AuthorizedMsg:
 		a.screen = screenMain
-		a.statusBar, _ = a.statusBar.Update(msg)
 		tg := a.tg
+		return a, tea.Batch(
+			func() tea.Msg {
+				return tg.FetchDialogs(0)()
+			},
+			func() tea.Msg {
+				return tg.FetchDialogs(telegram.ArchiveFolderID)()
+			},
+			func() tea.Msg {
+				return tg.FetchFolders()()
+			},
+			presenceTick(),
+		)
+
+	case common.PresenceTickMsg:
+		// Nothing to update: views render presence relative to now.
+		return a, presenceTick()
+
+	case common.LoadMoreDialogsMsg:
+		tg := a.tg
+		cursor := msg.Cursor
 		return a, func() tea.Msg {
-			return tg.FetchDialogs()()
+			return tg.FetchMoreDialogs(cursor)()
+		}
+
+	case common.MoveChatMsg:
+		tg := a.tg
+		chat, folderID := msg.Chat, msg.FolderID
+		return a, func() tea.Msg {
+			return tg.SetChatFolder(chat, folderID)()
 		}
 
 	case ChatSelectedMsg:
 		chat := msg.Chat
-		a.selectedChat = &chat
-		a.chatView = a.chatView.SetChat(&chat)
-		a.focus = focusChatView
-		a.chatList = a.chatList.SetFocus(false)
-		a.chatList = a.chatList.SetActiveChat(chat.ID)
-		a.chatView = a.chatView.SetFocus(true)
-		a.updateMode()
+		a.openChat(chat)
 		tg := a.tg
+		if chat.Forum {
+			return a, func() tea.Msg {
+				return tg.FetchForumTopics(chat)()
+			}
+		}
 		return a, func() tea.Msg {
 			return tg.FetchHistory(chat)()
 		}
 
+	case OpenMessageMsg:
+		// Prefer the chat list's copy, which knows the read state.
+		chat := msg.Chat
+		if known, ok := a.chatList.Chat(chat.ID); ok {
+			known.TopicID = chat.TopicID
+			chat = known
+		}
+		a.openChat(chat)
+		a.chatView = a.chatView.SetInputFocus(false)
+		a.updateMode()
+		tg := a.tg
+		msgID := msg.MessageID
+		cmds := []tea.Cmd{func() tea.Msg {
+			return tg.FetchHistoryAround(chat, msgID)()
+		}}
+		if chat.Forum {
+			// For the topic's title, and the list to go back to.
+			cmds = append(cmds, func() tea.Msg {
+				return tg.FetchForumTopics(chat)()
+			})
+		}
+		return a, tea.Batch(cmds...)
+
 	case ForwardRequestMsg:
 		a.forwardFromChat = &msg.FromChat
 		a.forwardMessageIDs = msg.MessageIDs
@@ -186,11 +240,23 @@ func (a App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return StatusMsg{Text: "Forward failed: " + msg.Err.Error()}
 		}
 
+	case TypingMsg:
+		cmds = append(cmds, tea.Tick(common.TypingTimeout, func(time.Time) tea.Msg {
+			return common.TypingExpiredMsg{}
+		}))
+
 	case FatalErrorMsg:
 		a.fatalErr = msg.Err
 		return a, tea.Quit
 	}
 
+	cmds = append(cmds, a.route(msg)...)
+	return a, tea.Batch(cmds...)
+}
+
+// route passes msg to the models of the current screen.
+func (a *App) route(msg tea.Msg) []tea.Cmd {
+	var cmds []tea.Cmd
 	switch a.screen {
 	case screenAuth:
 		var cmd tea.Cmd
@@ -212,6 +278,15 @@ func (a App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			cmds = append(cmds, cmd)
 		}
 
+		wasActive := a.search.Active()
+		a.search, cmd = a.search.Update(msg)
+		if cmd != nil {
+			cmds = append(cmds, cmd)
+		}
+		if wasActive && !a.search.Active() {
+			a.refocus()
+		}
+
 		a.statusBar, cmd = a.statusBar.Update(msg)
 		if cmd != nil {
 			cmds = append(cmds, cmd)
@@ -219,10 +294,110 @@ func (a App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		a.updateMode()
 	}
+	return cmds
+}
 
+// handleKey resolves msg in the current mode. Keys that start a binding
+// wait for the rest of it; keys of a sequence that went nowhere, and
+// unbound keys, are passed on as typed.
+func (a App) handleKey(msg tea.KeyMsg) (App, tea.Cmd) {
+	var action keymap.Action
+	var flushed []tea.KeyMsg
+	a.pending, action, flushed = a.keys.Feed(a.keyMode(), a.pending, msg)
+
+	var cmds []tea.Cmd
+	for _, key := range flushed {
+		cmds = append(cmds, a.route(key)...)
+	}
+	switch {
+	case action != "":
+		cmds = append(cmds, a.handleAction(action, msg)...)
+	case len(a.pending) == 0:
+		cmds = append(cmds, a.route(msg)...)
+	}
 	return a, tea.Batch(cmds...)
 }
 
+// handleAction carries out the actions that concern the whole app and
+// passes the rest on to the focused pane.
+func (a *App) handleAction(action keymap.Action, key tea.KeyMsg) []tea.Cmd {
+	switch action {
+	case keymap.Quit:
+		return []tea.Cmd{tea.Quit}
+	case keymap.SwitchAccount:
+		return []tea.Cmd{func() tea.Msg { return SwitchAccountMsg{} }}
+	}
+	if a.screen != screenMain {
+		return a.route(key)
+	}
+
+	switch action {
+	case keymap.ToggleFocus:
+		if a.search.Active() || a.isPickingForwardDest() || a.chatView.IsSearching() || a.chatView.IsConfirmingDelete() {
+			return nil
+		}
+		a.toggleFocus()
+		a.updateMode()
+		return nil
+
+	case keymap.Help:
+		a.showHelp = true
+		return nil
+
+	case keymap.GlobalSearch:
+		if a.search.Active() || a.isPickingForwardDest() {
+			break
+		}
+		a.search = a.search.Open()
+		a.chatList = a.chatList.SetFocus(false)
+		a.chatView = a.chatView.SetFocus(false)
+		a.updateMode()
+		return nil
+
+	case keymap.Back:
+		if a.search.Active() {
+			break
+		}
+		if a.isPickingForwardDest() {
+			a.forwardFromChat = nil
+			a.forwardMessageIDs = nil
+			a.chatList = a.chatList.SetPickingForwardDest(false)
+			a.chatView = a.chatView.CancelSelection()
+			a.focus = focusChatView
+			a.chatList = a.chatList.SetFocus(false)
+			a.chatView = a.chatView.SetFocus(true)
+			a.updateMode()
+			a.statusBar, _ = a.statusBar.Update(StatusMsg{Text: "Forward cancelled"})
+			return nil
+		}
+		// Let chatview handle Back when searching, showing search results or confirming a delete
+		if a.focus == focusChatView && (a.chatView.IsSearching() || a.chatView.HasSearchResults() || a.chatView.IsConfirmingDelete()) {
+			break
+		}
+		if a.focus == focusChatView {
+			if a.chatView.InputFocused() {
+				a.chatView = a.chatView.SetInputFocus(false)
+				a.updateMode()
+				return nil
+			}
+			if a.chatView.HasExpanded() {
+				a.chatView = a.chatView.CollapseExpanded()
+				return nil
+			}
+		}
+	}
+	return a.route(common.ActionMsg{Action: action, Key: key})
+}
+
+// presenceRefresh is how often "last seen" times are re-rendered.
+const presenceRefresh = 30 * time.Second
+
+func presenceTick() tea.Cmd {
+	return tea.Tick(presenceRefresh, func(time.Time) tea.Msg {
+		return common.PresenceTickMsg{}
+	})
+}
+
 func (a App) View() string {
 	if a.fatalErr != nil {
 		return StyleError.Render("Fatal error: " + a.fatalErr.Error())
@@ -231,23 +406,48 @@ func (a App) View() string {
 	switch a.screen {
 	case screenLoading:
 		return lipgloss.Place(a.width, a.height, lipgloss.Center, lipgloss.Center,
-			StyleMuted.Render("Connecting to Telegram..."))
+			a.connectingView())
 
 	case screenAuth:
 		return a.auth.View()
 
 	case screenMain:
+		if a.showHelp {
+			return lipgloss.Place(a.width, a.height, lipgloss.Center, lipgloss.Center,
+				renderHelp(a.keys, a.width, a.height))
+		}
 		return a.mainView()
 	}
 
 	return ""
 }
 
+// connectingView shows how connecting is going, with the last error once
+// an attempt failed.
+func (a App) connectingView() string {
+	text := "Connecting to Telegram..."
+	if a.conn.Proxy != "" {
+		text = "Connecting to Telegram via " + a.conn.Proxy + "..."
+	}
+	if a.conn.State != telegram.StateConnecting {
+		text = a.conn.State.String()
+		if a.conn.Proxy != "" {
+			text += " (via " + a.conn.Proxy + ")"
+		}
+	}
+	if a.conn.Err == nil {
+		return StyleMuted.Render(text)
+	}
+	return lipgloss.JoinVertical(lipgloss.Center,
+		StyleMuted.Render(text),
+		StyleError.Render(a.conn.Err.Error()))
+}
+
 func (a App) mainView() string {
 	statusHeight := 1
 	mainHeight := a.height - statusHeight
 
-	listWidth := a.width * 30 / 100
+	listWidth := a.width * a.listPercent / 100
 	if listWidth < 20 {
 		listWidth = 20
 	}
@@ -265,6 +465,9 @@ func (a App) mainView() string {
 
 	list := a.chatList.View()
 	view := a.chatView.View()
+	if a.search.Active() {
+		view = a.search.View()
+	}
 
 	main := lipgloss.JoinHorizontal(lipgloss.Top, list, separator, view)
 	status := a.statusBar.View()
@@ -272,6 +475,11 @@ func (a App) mainView() string {
 	return lipgloss.JoinVertical(lipgloss.Left, main, status)
 }
 
+// UnreadCount is the account's unread badge.
+func (a App) UnreadCount() int {
+	return a.chatList.UnreadCount()
+}
+
 func (a *App) toggleFocus() {
 	if a.focus == focusChatList {
 		a.focus = focusChatView
@@ -284,7 +492,30 @@ func (a *App) toggleFocus() {
 	}
 }
 
+// openChat shows chat in the chat view and focuses it.
+func (a *App) openChat(chat telegram.Chat) {
+	a.selectedChat = &chat
+	a.chatView = a.chatView.SetChat(&chat)
+	a.focus = focusChatView
+	a.chatList = a.chatList.SetActiveChat(chat.ID)
+	a.refocus()
+}
+
+// refocus gives the keyboard back to the pane a.focus names, once the
+// search pane is closed.
+func (a *App) refocus() {
+	a.chatList = a.chatList.SetFocus(a.focus == focusChatList)
+	a.chatView = a.chatView.SetFocus(a.focus == focusChatView)
+	a.updateMode()
+}
+
 func (a *App) currentMode() string {
+	if a.search.Editing() {
+		return "SRH"
+	}
+	if a.search.Active() {
+		return "NOR"
+	}
 	if a.isPickingForwardDest() {
 		return "FWD"
 	}
@@ -303,6 +534,22 @@ func (a *App) currentMode() string {
 	return "NOR"
 }
 
+// keyMode is the keymap mode keys are resolved in. Outside the main
+// screen only global bindings apply.
+func (a *App) keyMode() keymap.Mode {
+	switch {
+	case a.screen != screenMain:
+		return keymap.ModeGlobal
+	case a.search.Active():
+		return a.search.KeyMode()
+	case a.isPickingForwardDest():
+		return keymap.ModeForward
+	case a.focus == focusChatList:
+		return keymap.ModeNormal
+	}
+	return a.chatView.KeyMode()
+}
+
 func (a *App) isPickingForwardDest() bool {
 	return a.forwardFromChat != nil && len(a.forwardMessageIDs) > 0
 }
@@ -315,7 +562,7 @@ func (a *App) updateSizes() {
 	statusHeight := 1
 	mainHeight := a.height - statusHeight
 
-	listWidth := a.width * 30 / 100
+	listWidth := a.width * a.listPercent / 100
 	if listWidth < 20 {
 		listWidth = 20
 	}
@@ -324,5 +571,6 @@ func (a *App) updateSizes() {
 	a.auth = a.auth.SetSize(a.width, a.height)
 	a.chatList = a.chatList.SetSize(listWidth, mainHeight)
 	a.chatView = a.chatView.SetSize(viewWidth, mainHeight)
+	a.search = a.search.SetSize(viewWidth, mainHeight)
 	a.statusBar = a.statusBar.SetSize(a.width)
 }
@@ -18,14 +18,16 @@ const (
 	stepCode
 	stepPassword
 	stepQR
+	stepPassphrase // unlock an encrypted session before connecting
 )
 
 type Model struct {
-	tg            *telegram.Client
+	tg            telegram.Backend
 	step          step
 	phone         string
 	code          string
 	password      string
+	passphrase    string
 	phoneCodeHash string
 	codeType      string
 	err           string
@@ -35,7 +37,7 @@ type Model struct {
 	width, height int
 }
 
-func New(tg *telegram.Client) Model {
+func New(tg telegram.Backend) Model {
 	return Model{tg: tg}
 }
 
@@ -83,6 +85,10 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 				if len(m.password) > 0 {
 					m.password = m.password[:len(m.password)-1]
 				}
+			case stepPassphrase:
+				if len(m.passphrase) > 0 {
+					m.passphrase = m.passphrase[:len(m.passphrase)-1]
+				}
 			}
 		case tea.KeyRunes:
 			r := string(msg.Runes)
@@ -94,6 +100,8 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 				m.code += r
 			case stepPassword:
 				m.password += r
+			case stepPassphrase:
+				m.passphrase += r
 			}
 		}
 
@@ -113,6 +121,9 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 		m.qrCode = ""
 		m.step = stepPassword
 
+	case common.NeedPassphraseMsg:
+		m.step = stepPassphrase
+
 	case common.AuthErrorMsg:
 		m.loading = false
 		m.err = msg.Err.Error()
@@ -195,6 +206,19 @@ func (m Model) submit() (Model, tea.Cmd) {
 		return m, func() tea.Msg {
 			return m.tg.Submit2FA(password)()
 		}
+
+	case stepPassphrase:
+		passphrase := m.passphrase
+		if passphrase == "" {
+			m.err = "Passphrase is required"
+			return m, nil
+		}
+		m.loading = true
+		m.err = ""
+		m.passphrase = ""
+		return m, func() tea.Msg {
+			return m.tg.UnlockSession(passphrase)()
+		}
 	}
 
 	return m, nil
@@ -254,9 +278,18 @@ func (m Model) View() string {
 		b.WriteString("  Password: ")
 		b.WriteString(strings.Repeat("•", len(m.password)))
 		b.WriteString("█")
+
+	case stepPassphrase:
+		b.WriteString("Your session is encrypted. Enter its passphrase:\n\n")
+		b.WriteString("  Passphrase: ")
+		b.WriteString(strings.Repeat("•", len(m.passphrase)))
+		b.WriteString("█")
 	}
 
-	if m.loading && m.step != stepQR {
+	if m.loading && m.step == stepPassphrase {
+		b.WriteString("\n\n")
+		b.WriteString(common.StyleMuted.Render("  Unlocking session..."))
+	} else if m.loading && m.step != stepQR {
 		b.WriteString("\n\n")
 		b.WriteString(common.StyleMuted.Render("  Authenticating..."))
 	}
@@ -272,6 +305,8 @@ func (m Model) View() string {
 		b.WriteString(common.StyleMuted.Render("  Press Enter to select • Ctrl+C to quit"))
 	case stepQR:
 		b.WriteString(common.StyleMuted.Render("  Press Esc to go back • Ctrl+C to quit"))
+	case stepPassphrase:
+		b.WriteString(common.StyleMuted.Render("  Press Enter to unlock • Ctrl+C to quit"))
 	default:
 		b.WriteString(common.StyleMuted.Render("  Press Enter to submit • Esc to go back • Ctrl+C to quit"))
 	}
@@ -3,25 +3,52 @@ package chatlist
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/paramon-tech/tgtui/internal/telegram"
 	"github.com/paramon-tech/tgtui/internal/ui/common"
+	"github.com/paramon-tech/tgtui/internal/ui/keymap"
 )
 
 type Model struct {
-	chats         []telegram.Chat
+	chats         []telegram.Chat // every loaded chat, main list and archive
+	visible       []telegram.Chat // chats in the current tab
 	cursor        int
 	offset        int
 	focused       bool
 	activeChatID       int64
 	width, height      int
 	pickingForwardDest bool
+	typing             common.TypingState
+	// Dialog pagination, per peer folder (main list and archive)
+	pages [2]dialogPages
+	// Folder tabs: All, the user's folders, then Archive
+	folders []telegram.Folder
+	tab     int
+	keys    *keymap.Keymap
 }
 
+type dialogPages struct {
+	next        telegram.DialogsCursor
+	hasMore     bool
+	loadingMore bool
+	loaded      bool
+}
+
+// loadMoreThreshold is how close to the bottom of the list the cursor gets
+// before the next page of dialogs is requested.
+const loadMoreThreshold = 10
+
 func New() Model {
-	return Model{focused: true}
+	return Model{focused: true, keys: keymap.Default()}
+}
+
+// SetKeymap sets the key bindings.
+func (m Model) SetKeymap(keys *keymap.Keymap) Model {
+	m.keys = keys
+	return m
 }
 
 func (m Model) Init() tea.Cmd {
@@ -31,46 +58,132 @@ func (m Model) Init() tea.Cmd {
 func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case common.DialogsLoadedMsg:
-		m.chats = msg.Chats
-		m.cursor = 0
-		m.offset = 0
+		m.replaceFolder(msg.FolderID, msg.Chats)
+		m.pages[msg.FolderID] = dialogPages{next: msg.Next, hasMore: msg.HasMore, loaded: true}
+		m.refresh()
+		return m, m.maybeLoadMore()
+
+	case common.MoreDialogsLoadedMsg:
+		pages := &m.pages[msg.FolderID]
+		pages.loadingMore = false
+		pages.next = msg.Next
+		pages.hasMore = msg.HasMore
+		m.mergeChats(msg.Chats)
+		m.refresh()
+		return m, m.maybeLoadMore()
+
+	case common.MoreDialogsErrorMsg:
+		m.pages[msg.FolderID].loadingMore = false
+		m.pages[msg.FolderID].hasMore = false
+		return m, func() tea.Msg {
+			return common.StatusMsg{Text: "Failed to load more chats: " + msg.Err.Error()}
+		}
+
+	case common.FoldersLoadedMsg:
+		var current int
+		if f, ok := m.currentFolder(); ok {
+			current = f.ID
+		}
+		archive := m.isArchiveTab()
+		m.folders = msg.Folders
+		m.tab = 0
+		for i, f := range m.folders {
+			if f.ID == current {
+				m.tab = i + 1
+			}
+		}
+		if archive {
+			m.tab = len(m.folders) + 1
+		}
+		m.refresh()
+		return m, m.maybeLoadMore()
+
+	case common.ChatFolderChangedMsg:
+		for i, c := range m.chats {
+			if c.ID == msg.ChatID {
+				m.chats[i].FolderID = msg.FolderID
+				// Pinning is per folder; the chat lands unpinned.
+				m.chats[i].Pinned = false
+				m.chats = telegram.OrderChats(m.chats)
+				break
+			}
+		}
+		m.refresh()
+
+	case common.FolderChangeErrorMsg:
+		return m, func() tea.Msg {
+			return common.StatusMsg{Text: "Failed to move chat: " + msg.Err.Error()}
+		}
 
 	case common.NewMessageMsg:
+		m.typing.Stop(msg.Message.ChatID, msg.Message.SenderID)
 		m.updateOnNewMessage(msg.Message)
+		m.refresh()
 
-	case tea.KeyMsg:
-		if !m.focused {
-			return m, nil
+	case common.TypingMsg:
+		m.typing.Apply(msg, time.Now())
+
+	case common.UserStatusMsg:
+		for i, c := range m.chats {
+			if c.Type == telegram.ChatTypePrivate && c.ID == msg.UserID {
+				m.chats[i].Presence = msg.Presence
+				break
+			}
 		}
-		switch msg.String() {
-		case "up", "k":
-			if m.cursor > 0 {
-				m.cursor--
-				if m.cursor < m.offset {
-					m.offset = m.cursor
-				}
+		m.refresh()
+
+	case common.TypingExpiredMsg:
+		m.typing.Prune(time.Now())
+
+	case common.InboxReadMsg:
+		for i, c := range m.chats {
+			if c.ID != msg.ChatID {
+				continue
 			}
-		case "down", "j":
-			if m.cursor < len(m.chats)-1 {
-				m.cursor++
-				visible := m.visibleCount()
-				if m.cursor >= m.offset+visible {
-					m.offset = m.cursor - visible + 1
+			if msg.TopicID != 0 {
+				// A topic read says nothing about the rest of the forum.
+				if msg.StillUnread >= 0 {
+					m.chats[i].UnreadCount = msg.StillUnread
 				}
+				break
 			}
-		case "enter":
-			if m.cursor < len(m.chats) {
-				chat := m.chats[m.cursor]
-				if m.pickingForwardDest {
-					return m, func() tea.Msg {
-						return common.ForwardDestSelectedMsg{Chat: chat}
-					}
-				}
-				return m, func() tea.Msg {
-					return common.ChatSelectedMsg{Chat: chat}
-				}
+			if msg.MaxID > c.ReadInboxMaxID {
+				m.chats[i].ReadInboxMaxID = msg.MaxID
+			}
+			switch {
+			case msg.StillUnread >= 0:
+				m.chats[i].UnreadCount = msg.StillUnread
+			case c.LastMessage == nil || msg.MaxID >= c.LastMessage.ID:
+				m.chats[i].UnreadCount = 0
 			}
+			break
+		}
+		m.refresh()
+
+	case common.MessageEditedMsg:
+		for i, c := range m.chats {
+			if c.ID == msg.Message.ChatID && c.LastMessage != nil && c.LastMessage.ID == msg.Message.ID {
+				last := *c.LastMessage
+				last.Text = msg.Message.Text
+				m.chats[i].LastMessage = &last
+				break
+			}
+		}
+		m.refresh()
+
+	case tea.KeyMsg:
+		if !m.focused {
+			return m, nil
+		}
+		if action, ok := m.keys.Lookup(m.keyMode(), msg.String()); ok {
+			return m.handleAction(action)
+		}
+
+	case common.ActionMsg:
+		if !m.focused {
+			return m, nil
 		}
+		return m.handleAction(msg.Action)
 
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
@@ -80,10 +193,87 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 	return m, nil
 }
 
+func (m Model) keyMode() keymap.Mode {
+	if m.pickingForwardDest {
+		return keymap.ModeForward
+	}
+	return keymap.ModeNormal
+}
+
+func (m Model) handleAction(action keymap.Action) (Model, tea.Cmd) {
+	switch action {
+	case keymap.Up:
+		if m.cursor > 0 {
+			m.cursor--
+			if m.cursor < m.offset {
+				m.offset = m.cursor
+			}
+		}
+	case keymap.Down:
+		if m.cursor < len(m.visible)-1 {
+			m.cursor++
+			visible := m.visibleCount()
+			if m.cursor >= m.offset+visible {
+				m.offset = m.cursor - visible + 1
+			}
+		}
+		return m, m.maybeLoadMore()
+	case keymap.Top:
+		m.cursor, m.offset = 0, 0
+	case keymap.Bottom:
+		if len(m.visible) > 0 {
+			m.cursor = len(m.visible) - 1
+			if visible := m.visibleCount(); m.cursor >= m.offset+visible {
+				m.offset = m.cursor - visible + 1
+			}
+		}
+		return m, m.maybeLoadMore()
+	case keymap.NextFolder:
+		if m.tab < m.tabCount()-1 {
+			m.tab++
+			m.cursor, m.offset = 0, 0
+			m.refresh()
+		}
+		return m, m.maybeLoadMore()
+	case keymap.PrevFolder:
+		if m.tab > 0 {
+			m.tab--
+			m.cursor, m.offset = 0, 0
+			m.refresh()
+		}
+		return m, m.maybeLoadMore()
+	case keymap.Archive:
+		if m.pickingForwardDest || m.cursor >= len(m.visible) {
+			return m, nil
+		}
+		chat := m.visible[m.cursor]
+		folderID := telegram.ArchiveFolderID
+		if chat.FolderID == telegram.ArchiveFolderID {
+			folderID = 0
+		}
+		return m, func() tea.Msg {
+			return common.MoveChatMsg{Chat: chat, FolderID: folderID}
+		}
+	case keymap.Open:
+		if m.cursor < len(m.visible) {
+			chat := m.visible[m.cursor]
+			if m.pickingForwardDest {
+				return m, func() tea.Msg {
+					return common.ForwardDestSelectedMsg{Chat: chat}
+				}
+			}
+			return m, func() tea.Msg {
+				return common.ChatSelectedMsg{Chat: chat}
+			}
+		}
+	}
+	return m, nil
+}
+
 func (m *Model) updateOnNewMessage(msg telegram.Message) {
 	for i, c := range m.chats {
 		if c.ID == msg.ChatID {
-			if !msg.Out {
+			if !msg.Out && msg.ID > c.ReadInboxMaxID {
 				m.chats[i].UnreadCount++
 			}
 			m.chats[i].LastMessage = &telegram.Message{
@@ -96,41 +286,195 @@ func (m *Model) updateOnNewMessage(msg telegram.Message) {
 				Out:      msg.Out,
 				Media:    msg.Media,
 			}
-			// Move to top and adjust cursor/offset
-			chat := m.chats[i]
-			copy(m.chats[1:i+1], m.chats[0:i])
-			m.chats[0] = chat
-
-			if m.cursor == i {
-				// Cursor was on the moved chat — follow it to top
-				m.cursor = 0
-				m.offset = 0
-			} else if m.cursor < i {
-				// Cursor is above the moved chat — items shifted down
-				m.cursor++
-				if m.cursor >= m.offset+m.visibleCount() {
-					m.offset++
-				}
+			if c.Pinned {
+				// Pinned chats keep their place
+				return
+			}
+			// Move to the top of the unpinned chats; refresh keeps the
+			// cursor on whichever chat it was on.
+			top := 0
+			for top < len(m.chats) && m.chats[top].Pinned {
+				top++
 			}
+			chat := m.chats[i]
+			copy(m.chats[top+1:i+1], m.chats[top:i])
+			m.chats[top] = chat
 			return
 		}
 	}
 }
 
-func (m Model) View() string {
-	if len(m.chats) == 0 {
-		return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center,
-			common.StyleMuted.Render("Loading chats..."))
+// replaceFolder swaps in a fresh first page for a peer folder.
+func (m *Model) replaceFolder(folderID int, page []telegram.Chat) {
+	kept := make([]telegram.Chat, 0, len(m.chats)+len(page))
+	for _, c := range m.chats {
+		if c.FolderID != folderID {
+			kept = append(kept, c)
+		}
+	}
+	m.chats = kept
+	m.mergeChats(page)
+}
+
+// mergeChats adds a page of dialogs, skipping chats already listed (a new
+// message may have moved one up meanwhile).
+func (m *Model) mergeChats(page []telegram.Chat) {
+	seen := make(map[int64]bool, len(m.chats))
+	for _, c := range m.chats {
+		seen[c.ID] = true
+	}
+	merged := append([]telegram.Chat(nil), m.chats...)
+	for _, c := range page {
+		if !seen[c.ID] {
+			merged = append(merged, c)
+		}
+	}
+	m.chats = telegram.OrderChats(merged)
+}
+
+// refresh recomputes the chats of the current tab, keeping the cursor on
+// the same chat where it is still listed.
+func (m *Model) refresh() {
+	var selectedID int64
+	if m.cursor < len(m.visible) {
+		selectedID = m.visible[m.cursor].ID
+	}
+	if m.tab >= m.tabCount() {
+		m.tab = m.tabCount() - 1
+	}
+
+	if f, ok := m.currentFolder(); ok {
+		m.visible = f.Chats(m.chats, time.Now())
+	} else {
+		folderID := 0
+		if m.isArchiveTab() {
+			folderID = telegram.ArchiveFolderID
+		}
+		m.visible = m.visible[:0:0]
+		for _, c := range m.chats {
+			if c.FolderID == folderID {
+				m.visible = append(m.visible, c)
+			}
+		}
+	}
+
+	for i, c := range m.visible {
+		if c.ID == selectedID {
+			m.cursor = i
+			break
+		}
+	}
+	if m.cursor >= len(m.visible) {
+		m.cursor = len(m.visible) - 1
+	}
+	if m.cursor < 0 {
+		m.cursor = 0
+	}
+	if m.cursor < m.offset {
+		m.offset = m.cursor
+	} else if visible := m.visibleCount(); m.cursor >= m.offset+visible {
+		m.offset = m.cursor - visible + 1
+	}
+}
+
+// tabCount is All, one tab per folder, and Archive once archived chats
+// are known to exist.
+func (m Model) tabCount() int {
+	n := 1 + len(m.folders)
+	if m.hasArchive() {
+		n++
+	}
+	return n
+}
+
+func (m Model) hasArchive() bool {
+	if m.pages[telegram.ArchiveFolderID].hasMore {
+		return true
+	}
+	for _, c := range m.chats {
+		if c.FolderID == telegram.ArchiveFolderID {
+			return true
+		}
+	}
+	return false
+}
+
+func (m Model) isArchiveTab() bool {
+	return m.hasArchive() && m.tab == len(m.folders)+1
+}
+
+func (m Model) currentFolder() (telegram.Folder, bool) {
+	if m.tab >= 1 && m.tab <= len(m.folders) {
+		return m.folders[m.tab-1], true
+	}
+	return telegram.Folder{}, false
+}
+
+// tabFolders lists the peer folders the current tab draws chats from.
+func (m Model) tabFolders() []int {
+	if m.isArchiveTab() {
+		return []int{telegram.ArchiveFolderID}
+	}
+	if f, ok := m.currentFolder(); ok && !f.ExcludeArchived {
+		return []int{0, telegram.ArchiveFolderID}
+	}
+	return []int{0}
+}
+
+// maybeLoadMore requests the next page of dialogs once the cursor nears the
+// end of the current tab.
+func (m *Model) maybeLoadMore() tea.Cmd {
+	if m.cursor < len(m.visible)-loadMoreThreshold {
+		return nil
+	}
+	for _, folderID := range m.tabFolders() {
+		pages := &m.pages[folderID]
+		if pages.loadingMore {
+			return nil
+		}
+		if !pages.hasMore {
+			continue
+		}
+		pages.loadingMore = true
+		cursor := pages.next
+		return func() tea.Msg {
+			return common.LoadMoreDialogsMsg{Cursor: cursor}
+		}
 	}
+	return nil
+}
 
+func (m Model) loadingMore() bool {
+	for _, folderID := range m.tabFolders() {
+		if m.pages[folderID].loadingMore {
+			return true
+		}
+	}
+	return false
+}
+
+func (m Model) View() string {
 	var lines []string
-	visible := m.visibleCount()
+	if m.tabCount() > 1 {
+		lines = append(lines, m.renderTabs())
+	}
 
-	for i := m.offset; i < len(m.chats) && i < m.offset+visible; i++ {
-		chat := m.chats[i]
+	switch {
+	case !m.pages[0].loaded:
+		lines = append(lines, common.StyleMuted.Render("  Loading chats..."))
+	case len(m.visible) == 0 && !m.loadingMore():
+		lines = append(lines, common.StyleMuted.Render("  No chats here"))
+	}
+
+	visible := m.visibleCount()
+	for i := m.offset; i < len(m.visible) && i < m.offset+visible; i++ {
+		chat := m.visible[i]
 		line := m.renderChat(chat, i == m.cursor)
 		lines = append(lines, line)
 	}
+	if m.loadingMore() && len(lines) < m.height {
+		lines = append(lines, common.StyleMuted.Render("  Loading more chats..."))
+	}
 
 	// Pad to fixed height
 	for len(lines) < m.height {
@@ -140,6 +484,25 @@ func (m Model) View() string {
 	return strings.Join(lines[:m.height], "\n")
 }
 
+func (m Model) renderTabs() string {
+	titles := []string{"All"}
+	for _, f := range m.folders {
+		titles = append(titles, f.Title)
+	}
+	if m.hasArchive() {
+		titles = append(titles, "Archive")
+	}
+	var parts []string
+	for i, t := range titles {
+		if i == m.tab {
+			parts = append(parts, common.StyleSelected.Render(t))
+		} else {
+			parts = append(parts, common.StyleMuted.Render(t))
+		}
+	}
+	return lipgloss.NewStyle().MaxWidth(m.width).Render(" " + strings.Join(parts, common.StyleMuted.Render(" │ ")))
+}
+
 func (m Model) renderChat(chat telegram.Chat, selected bool) string {
 	width := m.width - 2
 	if width < 4 {
@@ -154,6 +517,9 @@ func (m Model) renderChat(chat telegram.Chat, selected bool) string {
 		typePrefix = ">"
 	default:
 		typePrefix = " "
+		if chat.Presence.Online(time.Now()) {
+			typePrefix = lipgloss.NewStyle().Foreground(common.ColorSecondary).Render("●")
+		}
 	}
 
 	name := truncate(chat.Title, width-8)
@@ -163,7 +529,12 @@ func (m Model) renderChat(chat telegram.Chat, selected bool) string {
 		unread = common.StyleUnread.Render(fmt.Sprintf(" (%d)", chat.UnreadCount))
 	}
 
-	line := fmt.Sprintf(" %s %s%s", typePrefix, name, unread)
+	var typing string
+	if m.typing.Active(chat.ID, time.Now()) {
+		typing = common.StyleMuted.Render(" ✎")
+	}
+
+	line := fmt.Sprintf(" %s %s%s%s", typePrefix, name, unread, typing)
 	isActive := chat.ID == m.activeChatID && m.activeChatID != 0
 
 	var marker string
@@ -187,6 +558,9 @@ func (m Model) visibleCount() int {
 	if m.height <= 0 {
 		return 20
 	}
+	if m.tabCount() > 1 {
+		return m.height - 1
+	}
 	return m.height
 }
 
@@ -202,8 +576,18 @@ func (m Model) SetFocus(focused bool) Model {
 }
 
 func (m Model) SelectedChat() (telegram.Chat, bool) {
-	if m.cursor < len(m.chats) {
-		return m.chats[m.cursor], true
+	if m.cursor < len(m.visible) {
+		return m.visible[m.cursor], true
+	}
+	return telegram.Chat{}, false
+}
+
+// Chat returns the loaded chat with the given ID.
+func (m Model) Chat(id int64) (telegram.Chat, bool) {
+	for _, chat := range m.chats {
+		if chat.ID == id {
+			return chat, true
+		}
 	}
 	return telegram.Chat{}, false
 }
@@ -217,6 +601,19 @@ func (m Model) Focused() bool {
 	return m.focused
 }
 
+// UnreadCount is the number of unread messages in unmuted chats, as on
+// the app icon badge of official clients.
+func (m Model) UnreadCount() int {
+	now := time.Now()
+	total := 0
+	for _, chat := range m.chats {
+		if !chat.Muted(now) {
+			total += chat.UnreadCount
+		}
+	}
+	return total
+}
+
 func (m Model) SetPickingForwardDest(picking bool) Model {
 	m.pickingForwardDest = picking
 	return m
@@ -0,0 +1,167 @@
+package chatlist
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/paramon-tech/tgtui/internal/telegram"
+	"github.com/paramon-tech/tgtui/internal/telegram/fake"
+	"github.com/paramon-tech/tgtui/internal/ui/common"
+)
+
+func TestPaginationKeepsPinnedFirst(t *testing.T) {
+	tg := fake.New(1)
+	tg.SetDialogsPageSize(20)
+	var chats []telegram.Chat
+	for i := 1; i <= 45; i++ {
+		chats = append(chats, telegram.Chat{
+			ID:          int64(i),
+			Title:       fmt.Sprintf("chat %d", i),
+			Pinned:      i <= 2,
+			LastMessage: &telegram.Message{ID: i, Date: 1000 - i},
+		})
+	}
+	tg.SetDialogs(chats...)
+
+	m := New().SetSize(30, 10)
+	m, cmd := m.Update(tg.FetchDialogs(0)())
+	if len(m.chats) != 20 {
+		t.Fatalf("expected first page of 20, got %d", len(m.chats))
+	}
+
+	// Walk to the bottom, answering page requests the way App does.
+	for i := 0; i < 60; i++ {
+		for cmd != nil {
+			msg := cmd()
+			req, ok := msg.(common.LoadMoreDialogsMsg)
+			if !ok {
+				t.Fatalf("unexpected command result %T", msg)
+			}
+			m, cmd = m.Update(tg.FetchMoreDialogs(req.Cursor)())
+		}
+		m, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'j'}})
+	}
+
+	if len(m.chats) != 45 {
+		t.Fatalf("expected all 45 chats loaded, got %d", len(m.chats))
+	}
+	if m.pages[0].hasMore || m.loadingMore() {
+		t.Errorf("expected pagination finished, hasMore=%v loadingMore=%v", m.pages[0].hasMore, m.loadingMore())
+	}
+
+	m, _ = m.Update(common.NewMessageMsg{Message: telegram.Message{ID: 100, ChatID: 40, Date: 2000}})
+	if m.chats[0].ID != 1 || m.chats[1].ID != 2 || m.chats[2].ID != 40 {
+		t.Errorf("expected pinned chats first then chat 40, got %d %d %d", m.chats[0].ID, m.chats[1].ID, m.chats[2].ID)
+	}
+}
+
+func TestFoldersAndArchive(t *testing.T) {
+	tg := fake.New(1)
+	tg.SetDialogs(
+		telegram.Chat{ID: 1, Title: "Alice", Contact: true},
+		telegram.Chat{ID: 2, Title: "Team", Type: telegram.ChatTypeGroup, UnreadCount: 3},
+		telegram.Chat{ID: 3, Title: "News", Type: telegram.ChatTypeChannel},
+		telegram.Chat{ID: 4, Title: "Old group", Type: telegram.ChatTypeGroup, FolderID: telegram.ArchiveFolderID},
+	)
+	tg.SetFolders(telegram.Folder{ID: 5, Title: "Work", Groups: true, ExcludeArchived: true, Include: []int64{3}})
+
+	m := New().SetSize(30, 10)
+	m, _ = m.Update(tg.FetchDialogs(0)())
+	m, _ = m.Update(tg.FetchDialogs(telegram.ArchiveFolderID)())
+	m, _ = m.Update(tg.FetchFolders()())
+
+	if got := titles(m.visible); got != "Alice,Team,News" {
+		t.Errorf("All tab: got %s", got)
+	}
+	key := func(r rune) tea.KeyMsg { return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}} }
+
+	m, _ = m.Update(key(']'))
+	if got := titles(m.visible); got != "Team,News" {
+		t.Errorf("Work tab: got %s", got)
+	}
+	m, _ = m.Update(key(']'))
+	if got := titles(m.visible); got != "Old group" {
+		t.Errorf("Archive tab: got %s", got)
+	}
+
+	// Unarchive the only archived chat; the Archive tab goes away.
+	m, cmd := m.Update(key('a'))
+	req := cmd().(common.MoveChatMsg)
+	if req.Chat.ID != 4 || req.FolderID != 0 {
+		t.Fatalf("expected unarchive request for chat 4, got %+v", req)
+	}
+	m, _ = m.Update(tg.SetChatFolder(req.Chat, req.FolderID)())
+	if m.tabCount() != 2 || m.tab != 1 {
+		t.Errorf("expected Archive tab gone and Work selected, got %d tabs, tab %d", m.tabCount(), m.tab)
+	}
+	if got := titles(m.visible); got != "Team,News,Old group" {
+		t.Errorf("Work tab after unarchive: got %s", got)
+	}
+}
+
+func titles(chats []telegram.Chat) string {
+	var s []string
+	for _, c := range chats {
+		s = append(s, c.Title)
+	}
+	return strings.Join(s, ",")
+}
+
+func TestInboxReadUpdatesBadge(t *testing.T) {
+	tg := fake.New(1)
+	chat := telegram.Chat{ID: 1, Title: "Alice", UnreadCount: 5, LastMessage: &telegram.Message{ID: 5}}
+	forum := telegram.Chat{ID: 2, Title: "Gophers", Type: telegram.ChatTypeGroup, Forum: true, UnreadCount: 3, LastMessage: &telegram.Message{ID: 13}}
+	tg.SetDialogs(chat, forum)
+	var history []telegram.Message
+	for id := 1; id <= 5; id++ {
+		history = append(history, telegram.Message{ID: id, SenderID: 42})
+	}
+	tg.SetHistory(chat.ID, history...)
+	tg.SetHistory(forum.ID,
+		telegram.Message{ID: 11, SenderID: 42, TopicID: 10},
+		telegram.Message{ID: 12, SenderID: 42, TopicID: 10},
+		telegram.Message{ID: 13, SenderID: 42},
+	)
+
+	m := New().SetSize(30, 10)
+	m, _ = m.Update(tg.FetchDialogs(0)())
+	unread := func(id int64) int {
+		t.Helper()
+		c, ok := m.Chat(id)
+		if !ok {
+			t.Fatalf("chat %d missing", id)
+		}
+		return c.UnreadCount
+	}
+
+	m, _ = m.Update(tg.MarkRead(chat, 3, -1)())
+	if got := unread(chat.ID); got != 2 {
+		t.Errorf("partial read: expected 2 unread, got %d", got)
+	}
+
+	m, _ = m.Update(tg.ReadRemote(chat.ID, 4))
+	if got := unread(chat.ID); got != 1 {
+		t.Errorf("remote read: expected 1 unread, got %d", got)
+	}
+
+	m, _ = m.Update(common.InboxReadMsg{ChatID: chat.ID, MaxID: 5, StillUnread: -1})
+	if got := unread(chat.ID); got != 0 {
+		t.Errorf("full read with unknown count: expected 0 unread, got %d", got)
+	}
+
+	topic := forum
+	topic.TopicID = 10
+	m, _ = m.Update(common.InboxReadMsg{ChatID: forum.ID, TopicID: 10, MaxID: 12, StillUnread: -1})
+	if got := unread(forum.ID); got != 3 {
+		t.Errorf("topic read with unknown count: expected badge left at 3, got %d", got)
+	}
+	m, _ = m.Update(tg.MarkRead(topic, 12, -1)())
+	if got := unread(forum.ID); got != 1 {
+		t.Errorf("topic read: expected the forum's 1 unread, got %d", got)
+	}
+	if c, _ := m.Chat(forum.ID); c.ReadInboxMaxID != 0 {
+		t.Errorf("topic read: expected the forum's read mark untouched, got %d", c.ReadInboxMaxID)
+	}
+}
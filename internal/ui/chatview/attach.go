@@ -0,0 +1,104 @@
+package chatview
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/paramon-tech/tgtui/internal/telegram"
+	"github.com/paramon-tech/tgtui/internal/ui/common"
+	"github.com/paramon-tech/tgtui/internal/ui/keymap"
+)
+
+// attachCommand, typed into the composer followed by a path, attaches a
+// local file to the next message.
+const attachCommand = ":attach"
+
+// reloadCommand, typed into the composer, reloads the config file.
+const reloadCommand = ":reload"
+
+// startAttach handles ":attach <path>": it checks the file and switches the
+// composer to collecting an optional caption.
+func (m Model) startAttach(arg string) (Model, tea.Cmd) {
+	path := expandHome(strings.TrimSpace(arg))
+	if path == "" {
+		return m, func() tea.Msg {
+			return common.StatusMsg{Text: "Usage: " + attachCommand + " <path>"}
+		}
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		return m, func() tea.Msg {
+			return common.StatusMsg{Text: "Cannot attach: " + err.Error()}
+		}
+	}
+	if stat.IsDir() {
+		return m, func() tea.Msg {
+			return common.StatusMsg{Text: "Cannot attach a directory: " + path}
+		}
+	}
+	m.attachPath = path
+	m.attachAs = telegram.DefaultSendAs(telegram.DetectMIME(path))
+	m.input = ""
+	send, cycle := m.keys.Hint(keymap.ModeInsert, keymap.Send), m.keys.Hint(keymap.ModeInsert, keymap.CycleAttach)
+	return m, func() tea.Msg {
+		return common.StatusMsg{Text: "Type a caption and press " + send + " to send — " + cycle + " changes how it is sent"}
+	}
+}
+
+// sendAttachment uploads the attached file with the composer text as its
+// caption.
+func (m Model) sendAttachment() (Model, tea.Cmd) {
+	chat := *m.chat
+	path := m.attachPath
+	opts := telegram.SendFileOptions{
+		As:        m.attachAs,
+		Caption:   strings.TrimSpace(m.input),
+		ReplyToID: m.replyToMsgID,
+	}
+	m.attachPath = ""
+	m.input = ""
+	m.replyToMsgID = 0
+	tg := m.tg
+	return m, tea.Batch(
+		func() tea.Msg {
+			return common.StatusMsg{Text: fmt.Sprintf("Uploading %s...", filepath.Base(path))}
+		},
+		func() tea.Msg {
+			return tg.SendFile(chat, path, opts)()
+		},
+	)
+}
+
+// cycleAttachAs switches between sending the attachment as a photo,
+// video, voice message or plain document.
+func (m Model) cycleAttachAs() Model {
+	switch m.attachAs {
+	case telegram.SendAsDocument:
+		m.attachAs = telegram.SendAsPhoto
+	case telegram.SendAsPhoto:
+		m.attachAs = telegram.SendAsVideo
+	case telegram.SendAsVideo:
+		m.attachAs = telegram.SendAsVoice
+	default:
+		m.attachAs = telegram.SendAsDocument
+	}
+	return m
+}
+
+func (m Model) renderAttachPrefix() string {
+	label := fmt.Sprintf("[%s] %s caption> ", m.attachAs, filepath.Base(m.attachPath))
+	return lipgloss.NewStyle().Foreground(common.ColorSecondary).Render(label)
+}
+
+func expandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[1:])
+		}
+	}
+	return path
+}
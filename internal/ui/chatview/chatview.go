@@ -4,21 +4,28 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/paramon-tech/tgtui/internal/config"
 	"github.com/paramon-tech/tgtui/internal/format"
 	"github.com/paramon-tech/tgtui/internal/telegram"
 	"github.com/paramon-tech/tgtui/internal/ui/common"
+	"github.com/paramon-tech/tgtui/internal/ui/keymap"
 )
 
 type Model struct {
 	chat          *telegram.Chat
 	messages      []telegram.Message
 	input         string
-	tg            *telegram.Client
+	editingMsgID  int // message being edited in the composer, 0 if none
+	replyToMsgID  int // message the composer replies to, 0 if none
+	attachPath    string // file attached via :attach, "" if none
+	attachAs      telegram.SendAs
+	tg            telegram.Backend
 	focused       bool
 	width, height int
 	scrollOffset  int
@@ -28,48 +35,153 @@ type Model struct {
 	// History pagination
 	loadingOlder bool
 	noMoreHistory bool
+	loadingNewer bool
+	hasNewer     bool // loaded window ends before the latest message
+	// Read acknowledgement
+	readMaxID   int  // highest message acknowledged (or being acknowledged)
+	readPending bool // a MarkRead request is in flight
+	// Reply parents outside the loaded history
+	replyParents map[int]*telegram.Message // msgID → parent, nil while fetching or missing
 	// Photo thumbnail cache
 	photoCache   map[int]string // msgID → rendered half-block string
 	photoLines   map[int]int    // msgID → line count of rendered image
 	photoLoading map[int]bool   // msgID → currently downloading
 	// File download state
-	fileSaving map[int]bool // msgID → currently saving to disk
+	fileSaving map[int]string // msgID → destination of a queued or running download
 	// Visual/selection mode
 	selecting bool
 	selected  map[int]bool // selected message IDs
 	// Search mode
 	searching      bool
 	searchQuery    string
-	searchResults  []telegram.Message // messages returned by search
-	searchActive   bool               // true when showing search results
+	searchResults  []telegram.Message    // messages returned by search
+	searchActive   bool                  // true when showing search results
+	searchFor      telegram.SearchQuery  // query the results are for
+	searchNext     telegram.SearchCursor // where older results start
+	searchHasMore  bool                  // older results remain
+	loadingResults bool                  // older results are being fetched
+	highlight      []string              // words of the search, marked in message text
+	// Typing indicators
+	typing         common.TypingState
+	lastTypingSent time.Time // throttles our own SetTyping calls
+	// Delete confirmation
+	confirmingDelete bool
+	deleteIDs        []int // awaiting confirmation, then awaiting the server
+	// Forum topics
+	topics        []telegram.Topic
+	topicCursor   int
+	topicTitle    string // title of the open topic
+	topicsNext    telegram.TopicsCursor
+	topicsHasMore bool
+	loadingTopics bool // a further page of topics is on its way
+	// Settings
+	timestampFormat string
+	downloadDir     string
+	noPhotos        bool // image_protocol = "none": no inline previews
+	keys            *keymap.Keymap
 }
 
-func New(tg *telegram.Client) Model {
+func New(tg telegram.Backend) Model {
 	return Model{
-		tg:            tg,
-		inputFocused:  true,
-		expandedMsgID: -1,
+		tg:              tg,
+		inputFocused:    true,
+		expandedMsgID:   -1,
+		timestampFormat: defaultTimestampFormat,
+		keys:            keymap.Default(),
 	}
 }
 
+const defaultTimestampFormat = "Mon 02/01/2006 15:04"
+
+// ApplyConfig takes the settings that affect the chat view. Rendered
+// photos are dropped, since the image protocol may have changed.
+func (m Model) ApplyConfig(cfg *config.Config) Model {
+	m.timestampFormat = cfg.TimestampFormat
+	m.downloadDir = cfg.DownloadDir
+	m.noPhotos = cfg.ImageProtocol == "none"
+	m.photoCache = nil
+	m.photoLines = nil
+	return m
+}
+
+// SetKeymap sets the key bindings.
+func (m Model) SetKeymap(keys *keymap.Keymap) Model {
+	m.keys = keys
+	return m
+}
+
 func (m Model) Init() tea.Cmd {
 	return nil
 }
 
 func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
+	m, cmd := m.update(msg)
+	if read := m.maybeMarkRead(); read != nil {
+		return m, tea.Batch(cmd, read)
+	}
+	return m, cmd
+}
+
+func (m Model) update(msg tea.Msg) (Model, tea.Cmd) {
 	switch msg := msg.(type) {
-	case common.HistoryLoadedMsg:
+	case common.ForumTopicsLoadedMsg:
 		if m.chat != nil && msg.ChatID == m.chat.ID {
+			m.topics = msg.Topics
+			m.topicsNext = msg.Next
+			m.topicsHasMore = msg.HasMore
+			m.loadingTopics = false
+			if m.topicCursor >= len(m.topics) {
+				m.topicCursor = 0
+			}
+			m.findTopicTitle()
+			return m, m.maybeLoadMoreTopics()
+		}
+
+	case common.ForumTopicsErrorMsg:
+		return m, func() tea.Msg {
+			return common.StatusMsg{Text: "Failed to load topics: " + msg.Err.Error()}
+		}
+
+	case common.MoreTopicsLoadedMsg:
+		if m.chat != nil && msg.ChatID == m.chat.ID && m.loadingTopics {
+			m.loadingTopics = false
+			m.topicsNext = msg.Next
+			m.topicsHasMore = msg.HasMore
+			m.mergeTopics(msg.Topics)
+			m.findTopicTitle()
+			return m, m.maybeLoadMoreTopics()
+		}
+
+	case common.MoreTopicsErrorMsg:
+		if m.chat != nil && msg.ChatID == m.chat.ID {
+			m.loadingTopics = false
+			m.topicsHasMore = false
+			return m, func() tea.Msg {
+				return common.StatusMsg{Text: "Failed to load more topics: " + msg.Err.Error()}
+			}
+		}
+
+	case common.HistoryLoadedMsg:
+		if m.chat != nil && msg.ChatID == m.chat.ID && msg.TopicID == m.chat.TopicID {
 			m.messages = msg.Messages
 			m.scrollOffset = 0
 			m.cursor = len(m.messages) - 1
 			m.expandedMsgID = -1
 			m.loadingOlder = false
 			m.noMoreHistory = false
+			m.loadingNewer = false
+			m.hasNewer = msg.HasNewer
+			if msg.FocusID != 0 {
+				if idx := m.indexOf(msg.FocusID); idx >= 0 {
+					m.cursor = idx
+				}
+				m.centerCursor()
+			}
+			return m, m.fetchMissingParents(m.messages)
 		}
 
 	case common.OlderHistoryLoadedMsg:
-		if m.chat != nil && msg.ChatID == m.chat.ID {
+		if m.chat != nil && msg.ChatID == m.chat.ID && msg.TopicID == m.chat.TopicID {
 			m.loadingOlder = false
 			if len(msg.Messages) == 0 {
 				m.noMoreHistory = true
@@ -78,17 +190,129 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 				m.cursor += len(msg.Messages)
 				m.messages = append(msg.Messages, m.messages...)
 				m.ensureCursorVisible()
+				return m, m.fetchMissingParents(msg.Messages)
 			}
 		}
 
 	case common.OlderHistoryErrorMsg:
 		m.loadingOlder = false
 
+	case common.NewerHistoryLoadedMsg:
+		if m.chat != nil && msg.ChatID == m.chat.ID && msg.TopicID == m.chat.TopicID {
+			m.loadingNewer = false
+			var fresh []telegram.Message
+			for _, nm := range msg.Messages {
+				if len(m.messages) == 0 || nm.ID > m.messages[len(m.messages)-1].ID {
+					fresh = append(fresh, nm)
+				}
+			}
+			if len(fresh) == 0 {
+				m.hasNewer = false
+				return m, nil
+			}
+			m.messages = append(m.messages, fresh...)
+			m.ensureCursorVisible()
+			return m, m.fetchMissingParents(fresh)
+		}
+
+	case common.NewerHistoryErrorMsg:
+		m.loadingNewer = false
+
+	case common.MessagesFetchedMsg:
+		if m.chat != nil && msg.ChatID == m.chat.ID {
+			if m.replyParents == nil {
+				m.replyParents = make(map[int]*telegram.Message)
+			}
+			for i := range msg.Messages {
+				m.replyParents[msg.Messages[i].ID] = &msg.Messages[i]
+			}
+			m.ensureCursorVisible()
+		}
+
+	case common.TypingMsg:
+		m.typing.Apply(msg, time.Now())
+
+	case common.TypingExpiredMsg:
+		m.typing.Prune(time.Now())
+
+	case common.UserStatusMsg:
+		if m.chat != nil && m.chat.Type == telegram.ChatTypePrivate && m.chat.ID == msg.UserID {
+			chat := *m.chat
+			chat.Presence = msg.Presence
+			m.chat = &chat
+		}
+
 	case common.NewMessageMsg:
-		if m.chat != nil && msg.Message.ChatID == m.chat.ID {
+		m.typing.Stop(msg.Message.ChatID, msg.Message.SenderID)
+		if m.chat != nil && msg.Message.ChatID == m.chat.ID && m.chat.Forum {
+			m.noteTopicMessage(msg.Message)
+			if m.chat.TopicID == 0 || !msg.Message.InTopic(m.chat.TopicID) {
+				return m, nil
+			}
+		}
+		// While viewing an older window the message would leave a gap;
+		// it shows up once the user scrolls down to the latest page.
+		if m.chat != nil && msg.Message.ChatID == m.chat.ID && !m.hasNewer {
 			m.messages = append(m.messages, msg.Message)
 			m.scrollOffset = 0
 			m.expandedMsgID = -1
+			return m, m.fetchMissingParents([]telegram.Message{msg.Message})
+		}
+
+	case common.InboxReadMsg:
+		if m.chat != nil && msg.ChatID == m.chat.ID && msg.TopicID == m.chat.TopicID {
+			m.readPending = false
+			if msg.MaxID > m.readMaxID {
+				m.readMaxID = msg.MaxID
+			}
+		}
+
+	case common.ReadErrorMsg:
+		if m.chat != nil && msg.ChatID == m.chat.ID {
+			m.readPending = false
+		}
+
+	case common.MessageEditedMsg:
+		if m.chat != nil && msg.Message.ChatID == m.chat.ID {
+			applyEdit(m.messages, msg.Message)
+			applyEdit(m.searchResults, msg.Message)
+			m.ensureCursorVisible()
+		}
+
+	case common.MessageEditErrorMsg:
+		return m, func() tea.Msg {
+			return common.StatusMsg{Text: "Edit failed: " + msg.Err.Error()}
+		}
+
+	case common.MessagesDeletedMsg:
+		if m.chat == nil {
+			return m, nil
+		}
+		if msg.ChatID == m.chat.ID || (msg.ChatID == 0 && !m.chat.IsChannelPeer()) {
+			m.removeMessages(msg.IDs)
+		}
+		// Only the deletion of what we asked to delete confirms it; others
+		// in the chat may arrive while the request is in flight.
+		if msg.ChatID == m.chat.ID && m.deleteIDs != nil && !m.confirmingDelete {
+			deleted := 0
+			for _, id := range msg.IDs {
+				if slices.Contains(m.deleteIDs, id) {
+					deleted++
+				}
+			}
+			if deleted == 0 {
+				return m, nil
+			}
+			m.deleteIDs = nil
+			return m, func() tea.Msg {
+				return common.StatusMsg{Text: fmt.Sprintf("Deleted %d message(s)", deleted)}
+			}
+		}
+
+	case common.DeleteErrorMsg:
+		m.deleteIDs = nil
+		return m, func() tea.Msg {
+			return common.StatusMsg{Text: "Delete failed: " + msg.Err.Error()}
 		}
 
 	case common.ReactionsUpdatedMsg:
@@ -102,9 +326,12 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 		}
 
 	case common.SearchResultMsg:
-		if m.chat != nil && msg.ChatID == m.chat.ID {
+		if m.chat != nil && msg.ChatID == m.chat.ID && msg.Query == m.searchFor {
 			m.searchResults = msg.Messages
 			m.searchActive = true
+			m.searchNext, m.searchHasMore = msg.Next, msg.HasMore
+			m.loadingResults = false
+			m.highlight = strings.Fields(msg.Query.Text)
 			m.scrollOffset = 0
 			m.expandedMsgID = -1
 			if len(msg.Messages) > 0 {
@@ -112,12 +339,29 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 			} else {
 				m.cursor = -1
 			}
+			found := fmt.Sprintf("Found %d result(s)", len(msg.Messages))
+			if msg.HasMore {
+				found = fmt.Sprintf("Found more than %d results", len(msg.Messages))
+			}
+			hint := fmt.Sprintf("%s to go to a message, %s to go back", m.keys.Hint(keymap.ModeNormal, keymap.GoToMessage), m.keys.Hint(keymap.ModeNormal, keymap.Back))
 			return m, func() tea.Msg {
-				return common.StatusMsg{Text: fmt.Sprintf("Found %d result(s) for \"%s\" — Esc to go back", len(msg.Messages), msg.Query)}
+				return common.StatusMsg{Text: fmt.Sprintf("%s for \"%s\" — %s", found, msg.Query.String(), hint)}
 			}
 		}
 
+	case common.SearchMoreMsg:
+		if m.searchActive && m.chat != nil && msg.ChatID == m.chat.ID && msg.Query == m.searchFor {
+			m.loadingResults = false
+			m.searchNext, m.searchHasMore = msg.Next, msg.HasMore
+			// Prepend older results, adjust cursor to keep position
+			m.cursor += len(msg.Messages)
+			m.searchResults = append(msg.Messages, m.searchResults...)
+			m.ensureCursorVisible()
+			return m.maybeLoadOlder()
+		}
+
 	case common.SearchErrorMsg:
+		m.loadingResults = false
 		return m, func() tea.Msg {
 			return common.StatusMsg{Text: "Search failed: " + msg.Err.Error()}
 		}
@@ -132,6 +376,42 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 			return common.StatusMsg{Text: "Send failed: " + msg.Err.Error()}
 		}
 
+	case common.UploadProgressMsg:
+		if m.chat != nil && msg.ChatID == m.chat.ID {
+			return m, func() tea.Msg {
+				return common.StatusMsg{Text: fmt.Sprintf("Uploading %s: %d%%", msg.Name, msg.Uploaded*100/msg.Total)}
+			}
+		}
+
+	case common.FileSendErrorMsg:
+		return m, func() tea.Msg {
+			return common.StatusMsg{Text: "Sending " + msg.Name + " failed: " + msg.Err.Error()}
+		}
+
+	case common.FileSentMsg:
+		if m.chat != nil && msg.ChatID == m.chat.ID {
+			tg := m.tg
+			chat := *m.chat
+			return m, tea.Batch(
+				func() tea.Msg {
+					return common.StatusMsg{Text: "Sent " + msg.Name}
+				},
+				func() tea.Msg {
+					return tg.FetchHistory(chat)()
+				},
+			)
+		}
+
+	case common.HistoryGapMsg:
+		// Updates for the chat were lost; reload rather than show a hole.
+		if m.chat != nil && msg.ChatID == m.chat.ID && !m.searchActive {
+			tg := m.tg
+			chat := *m.chat
+			return m, func() tea.Msg {
+				return tg.FetchHistory(chat)()
+			}
+		}
+
 	case common.MessageSentMsg:
 		if m.chat != nil && msg.ChatID == m.chat.ID {
 			tg := m.tg
@@ -157,6 +437,35 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 	case common.DownloadPhotoErrorMsg:
 		delete(m.photoLoading, msg.MessageID)
 
+	case common.DownloadQueuedMsg:
+		return m, func() tea.Msg {
+			if msg.Position > 0 {
+				return common.StatusMsg{Text: fmt.Sprintf("Queued download #%d: %s", msg.Position, msg.Path)}
+			}
+			return common.StatusMsg{Text: "Downloading to " + msg.Path + "..."}
+		}
+
+	case common.DownloadProgressMsg:
+		name := filepath.Base(msg.Path)
+		text := fmt.Sprintf("Downloading %s: %s", name, telegram.FormatFileSize(msg.Done))
+		if msg.Total > 0 {
+			text = fmt.Sprintf("Downloading %s: %d%% of %s — %s to cancel", name, msg.Done*100/msg.Total, telegram.FormatFileSize(msg.Total), m.keys.Hint(keymap.ModeNormal, keymap.CancelDownload))
+		}
+		return m, func() tea.Msg {
+			return common.StatusMsg{Text: text}
+		}
+
+	case common.DownloadCancelledMsg:
+		for id, path := range m.fileSaving {
+			if path == msg.Path {
+				delete(m.fileSaving, id)
+			}
+		}
+		hint := m.keys.Hint(keymap.ModeNormal, keymap.Download)
+		return m, func() tea.Msg {
+			return common.StatusMsg{Text: "Download cancelled: " + filepath.Base(msg.Path) + " (" + hint + " again to resume)"}
+		}
+
 	case common.SaveFileMsg:
 		delete(m.fileSaving, msg.MessageID)
 		return m, func() tea.Msg {
@@ -173,11 +482,14 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 		if !m.focused || m.chat == nil {
 			return m, nil
 		}
+		action, _ := m.keys.Lookup(m.KeyMode(), msg.String())
+		return m.handleKey(action, msg)
 
-		if m.inputFocused && m.chat.Type != telegram.ChatTypeChannel {
-			return m.handleInputKey(msg)
+	case common.ActionMsg:
+		if !m.focused || m.chat == nil {
+			return m, nil
 		}
-		return m.handleViewportKey(msg)
+		return m.handleKey(msg.Action, msg.Key)
 
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
@@ -187,55 +499,129 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 	return m, nil
 }
 
-func (m Model) handleInputKey(msg tea.KeyMsg) (Model, tea.Cmd) {
-	switch msg.String() {
-	case "pgup":
+// KeyMode is the keymap mode keys are resolved in.
+func (m Model) KeyMode() keymap.Mode {
+	switch {
+	case m.chat == nil || m.inTopicList():
+		return keymap.ModeNormal
+	case m.inputFocused && m.chat.Type != telegram.ChatTypeChannel:
+		return keymap.ModeInsert
+	case m.confirmingDelete:
+		return keymap.ModeNormal
+	case m.searching:
+		return keymap.ModeSearch
+	case m.selecting:
+		return keymap.ModeVisual
+	}
+	return keymap.ModeNormal
+}
+
+// handleKey handles msg, which the keymap resolved to action, or to ""
+// if it is bound to nothing in the current mode.
+func (m Model) handleKey(action keymap.Action, msg tea.KeyMsg) (Model, tea.Cmd) {
+	if m.inTopicList() {
+		return m.handleTopicKey(action)
+	}
+	if m.inputFocused && m.chat.Type != telegram.ChatTypeChannel {
+		return m.handleInputKey(action, msg)
+	}
+	return m.handleViewportKey(action, msg)
+}
+
+func (m Model) handleInputKey(action keymap.Action, msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch action {
+	case keymap.PageUp:
 		m.inputFocused = false
 		m.clampCursor()
 		return m, nil
-	}
-
-	switch msg.Type {
-	case tea.KeyEnter:
+	case keymap.CycleAttach:
+		if m.attachPath != "" {
+			return m.cycleAttachAs(), nil
+		}
+		return m, nil
+	case keymap.Send:
+		if m.attachPath != "" {
+			return m.sendAttachment()
+		}
 		text := strings.TrimSpace(m.input)
 		if text == "" {
 			return m, nil
 		}
+		if m.editingMsgID == 0 && (text == attachCommand || strings.HasPrefix(text, attachCommand+" ")) {
+			return m.startAttach(strings.TrimPrefix(text, attachCommand))
+		}
+		if m.editingMsgID == 0 && text == reloadCommand {
+			m.input = ""
+			return m, func() tea.Msg { return common.ReloadConfigMsg{} }
+		}
 		m.input = ""
 		chat := *m.chat
 		tg := m.tg
+		if m.editingMsgID != 0 {
+			msgID := m.editingMsgID
+			m.editingMsgID = 0
+			return m, func() tea.Msg {
+				return tg.EditMessage(chat, msgID, text)()
+			}
+		}
+		opts := telegram.SendOptions{ReplyToID: m.replyToMsgID}
+		m.replyToMsgID = 0
 		return m, func() tea.Msg {
-			return tg.SendMessage(chat, text)()
+			return tg.SendMessage(chat, text, opts)()
 		}
+	}
+	if action != "" {
+		return m, nil
+	}
 
+	switch msg.Type {
 	case tea.KeyBackspace:
 		if len(m.input) > 0 {
 			m.input = m.input[:len(m.input)-1]
+			return m.notifyTyping()
 		}
 
 	case tea.KeyRunes:
 		m.input += string(msg.Runes)
+		return m.notifyTyping()
 
 	case tea.KeySpace:
 		m.input += " "
+		return m.notifyTyping()
 	}
 
 	return m, nil
 }
 
-func (m Model) handleSelectionKey(msg tea.KeyMsg) (Model, tea.Cmd) {
-	switch msg.String() {
-	case "up", "k":
+// typingResend is how often SetTyping is repeated while the user types.
+const typingResend = 5 * time.Second
+
+// notifyTyping broadcasts our typing status, at most once per typingResend.
+func (m Model) notifyTyping() (Model, tea.Cmd) {
+	if m.editingMsgID != 0 || m.input == "" || time.Since(m.lastTypingSent) < typingResend {
+		return m, nil
+	}
+	m.lastTypingSent = time.Now()
+	tg := m.tg
+	chat := *m.chat
+	return m, func() tea.Msg {
+		return tg.SetTyping(chat)()
+	}
+}
+
+func (m Model) handleSelectionKey(action keymap.Action) (Model, tea.Cmd) {
+	switch action {
+	case keymap.Up:
 		if m.cursor > 0 {
 			m.cursor--
 			m.ensureCursorVisible()
 		}
-	case "down", "j":
+	case keymap.Down:
 		if m.cursor < len(m.messages)-1 {
 			m.cursor++
 			m.ensureCursorVisible()
 		}
-	case " ":
+	case keymap.ToggleSelect:
 		if m.cursor >= 0 && m.cursor < len(m.messages) {
 			msgID := m.messages[m.cursor].ID
 			if m.selected[msgID] {
@@ -247,7 +633,7 @@ func (m Model) handleSelectionKey(msg tea.KeyMsg) (Model, tea.Cmd) {
 				m.selected[msgID] = true
 			}
 		}
-	case "f":
+	case keymap.Forward:
 		if len(m.selected) == 0 {
 			return m, func() tea.Msg {
 				return common.StatusMsg{Text: "No messages selected"}
@@ -266,10 +652,23 @@ func (m Model) handleSelectionKey(msg tea.KeyMsg) (Model, tea.Cmd) {
 				MessageIDs: ids,
 			}
 		}
-	case "esc":
+	case keymap.Delete:
+		if len(m.selected) == 0 {
+			return m, func() tea.Msg {
+				return common.StatusMsg{Text: "No messages selected"}
+			}
+		}
+		var ids []int
+		for _, msg := range m.messages {
+			if m.selected[msg.ID] {
+				ids = append(ids, msg.ID)
+			}
+		}
+		m.startDelete(ids)
+	case keymap.Back:
 		m.selecting = false
 		m.selected = nil
-	case "pgup":
+	case keymap.PageUp:
 		pageSize := m.msgAreaHeight()
 		if pageSize < 1 {
 			pageSize = 1
@@ -279,7 +678,7 @@ func (m Model) handleSelectionKey(msg tea.KeyMsg) (Model, tea.Cmd) {
 			m.cursor = 0
 		}
 		m.ensureCursorVisible()
-	case "pgdown":
+	case keymap.PageDown:
 		pageSize := m.msgAreaHeight()
 		if pageSize < 1 {
 			pageSize = 1
@@ -296,27 +695,39 @@ func (m Model) handleSelectionKey(msg tea.KeyMsg) (Model, tea.Cmd) {
 	return m, nil
 }
 
-func (m Model) handleSearchKey(msg tea.KeyMsg) (Model, tea.Cmd) {
-	switch msg.Type {
-	case tea.KeyEnter:
-		query := strings.TrimSpace(m.searchQuery)
-		if query == "" {
+func (m Model) handleSearchKey(action keymap.Action, msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch action {
+	case keymap.Submit:
+		if strings.TrimSpace(m.searchQuery) == "" {
 			m.searching = false
 			m.searchQuery = ""
 			return m, nil
 		}
+		query, err := telegram.ParseSearchQuery(m.searchQuery)
+		if err != nil {
+			// Keep the prompt open so the query can be corrected.
+			return m, func() tea.Msg {
+				return common.StatusMsg{Text: "Search: " + err.Error()}
+			}
+		}
 		m.searching = false
+		m.searchFor = query
 		chat := *m.chat
 		tg := m.tg
 		return m, func() tea.Msg {
 			return tg.SearchHistory(chat, query)()
 		}
 
-	case tea.KeyEscape:
+	case keymap.Back:
 		m.searching = false
 		m.searchQuery = ""
 		return m, nil
+	}
+	if action != "" {
+		return m, nil
+	}
 
+	switch msg.Type {
 	case tea.KeyBackspace:
 		if len(m.searchQuery) > 0 {
 			m.searchQuery = m.searchQuery[:len(m.searchQuery)-1]
@@ -332,40 +743,110 @@ func (m Model) handleSearchKey(msg tea.KeyMsg) (Model, tea.Cmd) {
 	return m, nil
 }
 
-func (m Model) handleViewportKey(msg tea.KeyMsg) (Model, tea.Cmd) {
+// handleDeleteConfirmKey answers the delete prompt, whose keys are fixed
+// rather than bound in the keymap.
+func (m Model) handleDeleteConfirmKey(action keymap.Action, msg tea.KeyMsg) (Model, tea.Cmd) {
+	if action == keymap.Back {
+		m.confirmingDelete = false
+		m.deleteIDs = nil
+		return m, nil
+	}
+	var revoke bool
+	switch msg.String() {
+	case "y":
+		revoke = true
+	case "m":
+		if m.chat.IsChannelPeer() {
+			return m, nil
+		}
+		revoke = false
+	case "n":
+		m.confirmingDelete = false
+		m.deleteIDs = nil
+		return m, nil
+	default:
+		return m, nil
+	}
+
+	m.confirmingDelete = false
+	m.selecting = false
+	m.selected = nil
+	ids := m.deleteIDs
+	chat := *m.chat
+	tg := m.tg
+	return m, tea.Batch(
+		func() tea.Msg {
+			return common.StatusMsg{Text: fmt.Sprintf("Deleting %d message(s)...", len(ids))}
+		},
+		func() tea.Msg {
+			return tg.DeleteMessages(chat, ids, revoke)()
+		},
+	)
+}
+
+func (m *Model) startDelete(ids []int) {
+	if len(ids) == 0 {
+		return
+	}
+	m.confirmingDelete = true
+	m.deleteIDs = ids
+}
+
+func (m Model) handleViewportKey(action keymap.Action, msg tea.KeyMsg) (Model, tea.Cmd) {
+	if m.confirmingDelete {
+		return m.handleDeleteConfirmKey(action, msg)
+	}
 	if m.searching {
-		return m.handleSearchKey(msg)
+		return m.handleSearchKey(action, msg)
 	}
 	if m.selecting {
-		return m.handleSelectionKey(msg)
+		return m.handleSelectionKey(action)
 	}
 
 	msgs := m.activeMessages()
 
-	switch msg.String() {
-	case "esc":
+	switch action {
+	case keymap.Back:
 		if m.searchActive {
-			m.searchActive = false
-			m.searchResults = nil
-			m.scrollOffset = 0
-			m.cursor = len(m.messages) - 1
+			m.leaveResults()
+			m.highlight = nil
 			m.ensureCursorVisible()
 			return m, func() tea.Msg {
 				return common.StatusMsg{Text: ""}
 			}
 		}
-	case "up", "k":
+		if m.highlight != nil {
+			m.highlight = nil
+			return m, nil
+		}
+		if m.chat.TopicID != 0 {
+			return m.closeTopic()
+		}
+	case keymap.Up:
 		if m.cursor > 0 {
 			m.cursor--
 			m.ensureCursorVisible()
 		}
 		return m.maybeLoadOlder()
-	case "down", "j":
+	case keymap.Down:
 		if m.cursor < len(msgs)-1 {
 			m.cursor++
 			m.ensureCursorVisible()
 		}
-	case "enter":
+		return m.maybeLoadNewer()
+	case keymap.Top:
+		if len(msgs) > 0 {
+			m.cursor = 0
+			m.ensureCursorVisible()
+		}
+		return m.maybeLoadOlder()
+	case keymap.Bottom:
+		if len(msgs) > 0 {
+			m.cursor = len(msgs) - 1
+			m.ensureCursorVisible()
+		}
+		return m.maybeLoadNewer()
+	case keymap.Open:
 		if m.cursor >= 0 && m.cursor < len(msgs) {
 			curMsg := msgs[m.cursor]
 			msgID := curMsg.ID
@@ -374,7 +855,7 @@ func (m Model) handleViewportKey(msg tea.KeyMsg) (Model, tea.Cmd) {
 			} else {
 				m.expandedMsgID = msgID
 				// Trigger photo download if applicable
-				if curMsg.Media != nil && curMsg.Media.Type == telegram.MediaPhoto && curMsg.Media.PhotoThumbSize != "" {
+				if !m.noPhotos && curMsg.Media != nil && curMsg.Media.Type == telegram.MediaPhoto && curMsg.Media.PhotoThumbSize != "" {
 					if !m.photoLoading[msgID] && m.photoCache[msgID] == "" {
 						m.initPhotoCaches()
 						m.photoLoading[msgID] = true
@@ -389,7 +870,7 @@ func (m Model) handleViewportKey(msg tea.KeyMsg) (Model, tea.Cmd) {
 			}
 			m.ensureCursorVisible()
 		}
-	case "pgup":
+	case keymap.PageUp:
 		pageSize := m.msgAreaHeight()
 		if pageSize < 1 {
 			pageSize = 1
@@ -400,7 +881,7 @@ func (m Model) handleViewportKey(msg tea.KeyMsg) (Model, tea.Cmd) {
 		}
 		m.ensureCursorVisible()
 		return m.maybeLoadOlder()
-	case "pgdown":
+	case keymap.PageDown:
 		pageSize := m.msgAreaHeight()
 		if pageSize < 1 {
 			pageSize = 1
@@ -413,11 +894,82 @@ func (m Model) handleViewportKey(msg tea.KeyMsg) (Model, tea.Cmd) {
 			m.cursor = 0
 		}
 		m.ensureCursorVisible()
-	case "i":
+		return m.maybeLoadNewer()
+	case keymap.Compose:
 		if m.chat.Type != telegram.ChatTypeChannel && !m.searchActive {
 			m.inputFocused = true
 		}
-	case "v":
+	case keymap.Edit:
+		if m.chat.Type == telegram.ChatTypeChannel || m.searchActive {
+			return m, nil
+		}
+		if m.cursor >= 0 && m.cursor < len(msgs) {
+			curMsg := msgs[m.cursor]
+			if !curMsg.Out {
+				return m, func() tea.Msg {
+					return common.StatusMsg{Text: "Can only edit your own messages"}
+				}
+			}
+			m.editingMsgID = curMsg.ID
+			m.replyToMsgID = 0
+			m.input = curMsg.Text
+			m.inputFocused = true
+		}
+	case keymap.Reply:
+		if m.chat.Type == telegram.ChatTypeChannel || m.searchActive {
+			return m, nil
+		}
+		if m.cursor >= 0 && m.cursor < len(msgs) {
+			if m.editingMsgID != 0 {
+				m.editingMsgID = 0
+				m.input = ""
+			}
+			m.replyToMsgID = msgs[m.cursor].ID
+			m.inputFocused = true
+		}
+	case keymap.JumpToParent:
+		if m.searchActive || m.cursor < 0 || m.cursor >= len(msgs) {
+			return m, nil
+		}
+		parentID := msgs[m.cursor].ReplyToID
+		if parentID == 0 {
+			return m, func() tea.Msg {
+				return common.StatusMsg{Text: "Not a reply"}
+			}
+		}
+		if idx := m.indexOf(parentID); idx >= 0 {
+			m.cursor = idx
+			m.ensureCursorVisible()
+			return m, nil
+		}
+		tg := m.tg
+		chat := *m.chat
+		return m, tea.Batch(
+			func() tea.Msg {
+				return common.StatusMsg{Text: "Loading replied message..."}
+			},
+			func() tea.Msg {
+				return tg.FetchHistoryAround(chat, parentID)()
+			},
+		)
+	case keymap.GoToMessage:
+		if !m.searchActive || m.cursor < 0 || m.cursor >= len(msgs) {
+			return m, nil
+		}
+		msgID := msgs[m.cursor].ID
+		m.leaveResults()
+		m.ensureCursorVisible()
+		tg := m.tg
+		chat := *m.chat
+		return m, tea.Batch(
+			func() tea.Msg {
+				return common.StatusMsg{Text: "Loading message..."}
+			},
+			func() tea.Msg {
+				return tg.FetchHistoryAround(chat, msgID)()
+			},
+		)
+	case keymap.Select:
 		if m.searchActive {
 			return m, nil
 		}
@@ -428,30 +980,43 @@ func (m Model) handleViewportKey(msg tea.KeyMsg) (Model, tea.Cmd) {
 		if m.cursor >= 0 && m.cursor < len(msgs) {
 			m.selected[msgs[m.cursor].ID] = true
 		}
-	case "/":
+	case keymap.Search:
 		m.searching = true
 		m.searchQuery = ""
 		return m, nil
-	case "D":
+	case keymap.Delete:
+		if m.cursor >= 0 && m.cursor < len(msgs) {
+			m.startDelete([]int{msgs[m.cursor].ID})
+		}
+	case keymap.Download:
 		if m.cursor >= 0 && m.cursor < len(msgs) {
 			curMsg := msgs[m.cursor]
-			if curMsg.Media != nil && m.isDownloadable(curMsg.Media) && !m.fileSaving[curMsg.ID] {
+			if _, saving := m.fileSaving[curMsg.ID]; saving {
+				return m, func() tea.Msg {
+					return common.StatusMsg{Text: "Already downloading — " + m.keys.Hint(keymap.ModeNormal, keymap.CancelDownload) + " to cancel"}
+				}
+			}
+			if curMsg.Media != nil && m.isDownloadable(curMsg.Media) {
 				destPath := m.downloadPath(curMsg.Media)
 				if m.fileSaving == nil {
-					m.fileSaving = make(map[int]bool)
+					m.fileSaving = make(map[int]string)
 				}
-				m.fileSaving[curMsg.ID] = true
+				m.fileSaving[curMsg.ID] = destPath
 				tgClient := m.tg
 				info := curMsg.Media
 				msgID := curMsg.ID
-				return m, tea.Batch(
-					func() tea.Msg {
-						return common.StatusMsg{Text: "Downloading to " + destPath + "..."}
-					},
-					func() tea.Msg {
-						return tgClient.DownloadToFile(msgID, info, destPath)()
-					},
-				)
+				return m, func() tea.Msg {
+					return tgClient.DownloadToFile(msgID, info, destPath)()
+				}
+			}
+		}
+	case keymap.CancelDownload:
+		if m.cursor >= 0 && m.cursor < len(msgs) {
+			if destPath, saving := m.fileSaving[msgs[m.cursor].ID]; saving {
+				tg := m.tg
+				return m, func() tea.Msg {
+					return tg.CancelDownload(destPath)()
+				}
 			}
 		}
 	}
@@ -470,7 +1035,25 @@ func (m Model) View() string {
 		Foreground(common.ColorPrimary).
 		MaxWidth(m.width).
 		Padding(0, 1)
-	title := titleStyle.Render(m.chat.Title)
+	title := m.chat.Title
+	if m.chat.TopicID != 0 {
+		title += " › " + m.topicTitle
+	}
+	now := time.Now()
+	if typing := m.typing.Describe(m.chat.ID, now); typing != "" {
+		title += "  " + lipgloss.NewStyle().Bold(false).Foreground(common.ColorMuted).Italic(true).Render(typing)
+	} else if presence := m.chat.Presence.Describe(now); presence != "" {
+		style := lipgloss.NewStyle().Bold(false).Foreground(common.ColorMuted)
+		if m.chat.Presence.Online(now) {
+			style = style.Foreground(common.ColorSecondary)
+		}
+		title += "  " + style.Render(presence)
+	}
+	title = titleStyle.Render(title)
+
+	if m.inTopicList() {
+		return title + "\n" + m.renderTopics(m.height-1)
+	}
 
 	// Calculate available height
 	inputHeight := 0
@@ -478,7 +1061,7 @@ func (m Model) View() string {
 		inputHeight = 1
 	}
 	searchHeight := 0
-	if m.searching {
+	if m.searching || m.confirmingDelete {
 		searchHeight = 1
 	}
 	msgHeight := m.height - 1 - inputHeight - searchHeight // 1 for title
@@ -496,6 +1079,8 @@ func (m Model) View() string {
 	var searchView string
 	if m.searching {
 		searchView = m.renderSearchInput()
+	} else if m.confirmingDelete {
+		searchView = m.renderDeletePrompt()
 	}
 
 	parts := []string{title, msgView}
@@ -536,12 +1121,18 @@ func (m Model) renderMessages(height int) string {
 	if m.loadingOlder {
 		allLines = append(allLines, common.StyleMuted.Render("  Loading older messages..."))
 	}
+	if m.searchActive && m.loadingResults {
+		allLines = append(allLines, common.StyleMuted.Render("  Loading more results..."))
+	}
 	for i, msg := range msgs {
 		isSelected := (i == m.cursor)
 		isExpanded := msg.ID == m.expandedMsgID
 		lines := m.renderMessageLines(msg, isSelected, isExpanded)
 		allLines = append(allLines, lines...)
 	}
+	if m.loadingNewer {
+		allLines = append(allLines, common.StyleMuted.Render("  Loading newer messages..."))
+	}
 
 	// Apply scroll offset: show from bottom
 	totalLines := len(allLines)
@@ -568,7 +1159,7 @@ func (m Model) renderMessages(height int) string {
 }
 
 func (m Model) renderMessageLines(msg telegram.Message, isSelected, isExpanded bool) []string {
-	ts := time.Unix(int64(msg.Date), 0).Format("Mon 02/01/2006 15:04")
+	ts := time.Unix(int64(msg.Date), 0).Format(m.timestampFormat)
 	timestamp := common.StyleTimestamp.Render("[" + ts + "]")
 
 	var sender string
@@ -595,9 +1186,17 @@ func (m Model) renderMessageLines(msg telegram.Message, isSelected, isExpanded b
 		prefix = lipgloss.NewStyle().Foreground(common.ColorWarning).Render("*") + " "
 	}
 
+	var quote []string
+	if msg.ReplyToID != 0 {
+		quote = []string{m.renderReplyQuote(msg.ReplyToID)}
+	}
+
 	if isExpanded && (msg.Text != "" || msg.Media != nil) {
 		// Header line
 		header := fmt.Sprintf("%s%s %s:", prefix, timestamp, sender)
+		if msg.EditDate != 0 {
+			header += " " + editedMarker(msg)
+		}
 
 		indent := "    "
 		textWidth := m.width - len(indent)
@@ -605,7 +1204,7 @@ func (m Model) renderMessageLines(msg telegram.Message, isSelected, isExpanded b
 			textWidth = 20
 		}
 
-		lines := []string{header}
+		lines := append(quote, header)
 
 		// Media label line
 		if msg.Media != nil {
@@ -625,7 +1224,7 @@ func (m Model) renderMessageLines(msg telegram.Message, isSelected, isExpanded b
 
 		// Full text, word-wrapped
 		if msg.Text != "" {
-			styledText := format.RenderStyledTextMultiline(msg.Text, msg.Entities, textWidth)
+			styledText := format.RenderHighlightedMultiline(msg.Text, msg.Entities, m.highlight, textWidth)
 			for _, tl := range strings.Split(styledText, "\n") {
 				lines = append(lines, indent+tl)
 			}
@@ -643,15 +1242,19 @@ func (m Model) renderMessageLines(msg telegram.Message, isSelected, isExpanded b
 	var text string
 	switch {
 	case msg.Media != nil && msg.Text != "":
-		text = common.StyleMediaLabel.Render(msg.Media.Label) + " " + format.RenderStyledText(msg.Text, msg.Entities)
+		text = common.StyleMediaLabel.Render(msg.Media.Label) + " " + format.RenderHighlighted(msg.Text, msg.Entities, m.highlight)
 	case msg.Media != nil:
 		text = common.StyleMediaLabel.Render(msg.Media.Label)
 	case msg.Text != "":
-		text = format.RenderStyledText(msg.Text, msg.Entities)
+		text = format.RenderHighlighted(msg.Text, msg.Entities, m.highlight)
 	default:
 		text = common.StyleMuted.Render("[empty message]")
 	}
 
+	if msg.EditDate != 0 {
+		text += " " + editedMarker(msg)
+	}
+
 	// Append reactions to collapsed line
 	reactions := ""
 	if len(msg.Reactions) > 0 {
@@ -659,7 +1262,53 @@ func (m Model) renderMessageLines(msg telegram.Message, isSelected, isExpanded b
 	}
 
 	line := fmt.Sprintf("%s%s %s: %s%s", prefix, timestamp, sender, text, reactions)
-	return []string{lipgloss.NewStyle().MaxWidth(m.width).Render(line)}
+	return append(quote, lipgloss.NewStyle().MaxWidth(m.width).Render(line))
+}
+
+// renderReplyQuote renders the one-line quote of a reply's parent message.
+func (m Model) renderReplyQuote(parentID int) string {
+	quote := "reply to a message"
+	if parent, ok := m.findMessage(parentID); ok {
+		sender := parent.Sender
+		if parent.Out {
+			sender = "You"
+		} else if sender == "" {
+			sender = "Unknown"
+		}
+		var text string
+		switch {
+		case parent.Text != "":
+			text = strings.ReplaceAll(parent.Text, "\n", " ")
+		case parent.Media != nil:
+			text = parent.Media.Label
+		}
+		quote = sender + ": " + text
+	}
+	line := "    " + lipgloss.NewStyle().Foreground(common.ColorPrimary).Render("┌ ") + common.StyleMuted.Render(quote)
+	return lipgloss.NewStyle().MaxWidth(m.width).Render(line)
+}
+
+func editedMarker(msg telegram.Message) string {
+	return common.StyleMuted.Render("(edited " + time.Unix(int64(msg.EditDate), 0).Format("15:04") + ")")
+}
+
+// applyEdit updates the message with the same ID in msgs with what an
+// edit changes: text, entities, edit date and media if known. The rest is
+// kept, since an edit reported without the server's copy carries little
+// more.
+func applyEdit(msgs []telegram.Message, edited telegram.Message) {
+	for i := range msgs {
+		if msgs[i].ID != edited.ID {
+			continue
+		}
+		msgs[i].Text = edited.Text
+		msgs[i].Entities = edited.Entities
+		msgs[i].EditDate = edited.EditDate
+		if edited.Media != nil {
+			msgs[i].Media = edited.Media
+		}
+		return
+	}
 }
 
 func renderReactions(reactions []telegram.Reaction) string {
@@ -677,6 +1326,22 @@ func (m Model) renderInput() string {
 		Padding(0, 1)
 
 	prefix := common.StyleMuted.Render("> ")
+	if m.attachPath != "" {
+		prefix = m.renderAttachPrefix()
+	} else if m.editingMsgID != 0 {
+		prefix = lipgloss.NewStyle().Foreground(common.ColorWarning).Render("edit> ")
+	} else if m.replyToMsgID != 0 {
+		to := "message"
+		if parent, ok := m.findMessage(m.replyToMsgID); ok {
+			switch {
+			case parent.Out:
+				to = "yourself"
+			case parent.Sender != "":
+				to = parent.Sender
+			}
+		}
+		prefix = lipgloss.NewStyle().Foreground(common.ColorPrimary).Render("reply to " + to + "> ")
+	}
 	cursor := ""
 	if m.focused && m.inputFocused {
 		cursor = "█"
@@ -686,7 +1351,10 @@ func (m Model) renderInput() string {
 }
 
 func (m Model) maybeLoadOlder() (Model, tea.Cmd) {
-	if m.loadingOlder || m.noMoreHistory || m.searchActive || len(m.messages) == 0 {
+	if m.searchActive {
+		return m.maybeLoadMoreResults()
+	}
+	if m.loadingOlder || m.noMoreHistory || len(m.messages) == 0 {
 		return m, nil
 	}
 	if m.cursor == 0 {
@@ -701,6 +1369,161 @@ func (m Model) maybeLoadOlder() (Model, tea.Cmd) {
 	return m, nil
 }
 
+// resultsLoadThreshold is how close to the oldest search result the
+// cursor gets before older results are requested.
+const resultsLoadThreshold = 10
+
+// maybeLoadMoreResults requests the page of search results before the
+// oldest shown once the cursor nears it.
+func (m Model) maybeLoadMoreResults() (Model, tea.Cmd) {
+	if m.loadingResults || !m.searchHasMore || len(m.searchResults) == 0 || m.cursor >= resultsLoadThreshold {
+		return m, nil
+	}
+	m.loadingResults = true
+	tg := m.tg
+	chat := *m.chat
+	cursor := m.searchNext
+	return m, func() tea.Msg {
+		return tg.SearchHistoryMore(chat, cursor)()
+	}
+}
+
+// leaveResults goes back from search results to the latest of the
+// loaded history.
+func (m *Model) leaveResults() {
+	m.searchActive = false
+	m.searchResults = nil
+	m.searchNext = telegram.SearchCursor{}
+	m.searchHasMore = false
+	m.loadingResults = false
+	m.scrollOffset = 0
+	m.cursor = len(m.messages) - 1
+	m.expandedMsgID = -1
+}
+
+// maybeMarkRead acknowledges messages up to the last one on screen once
+// the user has seen past what was already acknowledged.
+func (m *Model) maybeMarkRead() tea.Cmd {
+	if m.chat == nil || m.readPending || m.searchActive {
+		return nil
+	}
+	maxID := m.lastVisibleMessageID()
+	if maxID <= m.readMaxID {
+		return nil
+	}
+	m.readMaxID = maxID
+	m.readPending = true
+	tg := m.tg
+	chat := *m.chat
+	stillUnread := m.unreadAfter(maxID)
+	return func() tea.Msg {
+		return tg.MarkRead(chat, maxID, stillUnread)()
+	}
+}
+
+// unreadAfter counts the incoming messages after maxID, or returns -1
+// when the loaded history cannot tell: an older window may be missing
+// newer messages, and a topic's count is not the chat's.
+func (m Model) unreadAfter(maxID int) int {
+	if m.hasNewer || m.chat.TopicID != 0 {
+		return -1
+	}
+	n := 0
+	for _, msg := range m.messages {
+		if !msg.Out && msg.ID > maxID {
+			n++
+		}
+	}
+	return n
+}
+
+// lastVisibleMessageID returns the ID of the bottom-most message on screen.
+func (m Model) lastVisibleMessageID() int {
+	height := m.msgAreaHeight()
+	if len(m.messages) == 0 || height <= 0 {
+		return 0
+	}
+	total := 0
+	for _, msg := range m.messages {
+		total += m.visualHeight(msg)
+	}
+	bottom := total - m.scrollOffset - 1
+	line := 0
+	for _, msg := range m.messages {
+		h := m.visualHeight(msg)
+		if bottom < line+h {
+			return msg.ID
+		}
+		line += h
+	}
+	return m.messages[len(m.messages)-1].ID
+}
+
+func (m Model) maybeLoadNewer() (Model, tea.Cmd) {
+	if m.loadingNewer || !m.hasNewer || m.searchActive || len(m.messages) == 0 {
+		return m, nil
+	}
+	if m.cursor == len(m.messages)-1 {
+		m.loadingNewer = true
+		tg := m.tg
+		chat := *m.chat
+		afterID := m.messages[len(m.messages)-1].ID
+		return m, func() tea.Msg {
+			return tg.FetchNewerHistory(chat, afterID)()
+		}
+	}
+	return m, nil
+}
+
+// fetchMissingParents requests reply parents of msgs that are neither
+// loaded nor already requested.
+func (m *Model) fetchMissingParents(msgs []telegram.Message) tea.Cmd {
+	var ids []int
+	for _, msg := range msgs {
+		id := msg.ReplyToID
+		if id == 0 || m.indexOf(id) >= 0 {
+			continue
+		}
+		if _, requested := m.replyParents[id]; requested {
+			continue
+		}
+		if m.replyParents == nil {
+			m.replyParents = make(map[int]*telegram.Message)
+		}
+		m.replyParents[id] = nil
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	tg := m.tg
+	chat := *m.chat
+	return func() tea.Msg {
+		return tg.FetchMessages(chat, ids)()
+	}
+}
+
+// indexOf returns the position of msgID in the loaded history, or -1.
+func (m Model) indexOf(msgID int) int {
+	for i := range m.messages {
+		if m.messages[i].ID == msgID {
+			return i
+		}
+	}
+	return -1
+}
+
+// findMessage looks up a message in the loaded history or the reply cache.
+func (m Model) findMessage(msgID int) (telegram.Message, bool) {
+	if idx := m.indexOf(msgID); idx >= 0 {
+		return m.messages[idx], true
+	}
+	if parent := m.replyParents[msgID]; parent != nil {
+		return *parent, true
+	}
+	return telegram.Message{}, false
+}
+
 func (m Model) activeMessages() []telegram.Message {
 	if m.searchActive && m.searchResults != nil {
 		return m.searchResults
@@ -718,6 +1541,22 @@ func (m Model) renderSearchInput() string {
 	return style.Render(prefix + m.searchQuery + cursor)
 }
 
+func (m Model) renderDeletePrompt() string {
+	style := lipgloss.NewStyle().
+		MaxWidth(m.width).
+		Padding(0, 1)
+
+	warn := lipgloss.NewStyle().Foreground(common.ColorError).Bold(true)
+	prompt := warn.Render(fmt.Sprintf("Delete %d message(s)?", len(m.deleteIDs)))
+	var choices string
+	if m.chat.IsChannelPeer() {
+		choices = " [y] for everyone  [n] cancel"
+	} else {
+		choices = " [y] for everyone  [m] for me only  [n] cancel"
+	}
+	return style.Render(prompt + common.StyleMuted.Render(choices))
+}
+
 // Helper methods
 
 func (m Model) msgAreaHeight() int {
@@ -726,15 +1565,19 @@ func (m Model) msgAreaHeight() int {
 		inputHeight = 1
 	}
 	searchHeight := 0
-	if m.searching {
+	if m.searching || m.confirmingDelete {
 		searchHeight = 1
 	}
 	return m.height - 1 - inputHeight - searchHeight
 }
 
 func (m Model) visualHeight(msg telegram.Message) int {
+	quote := 0
+	if msg.ReplyToID != 0 {
+		quote = 1
+	}
 	if msg.ID == m.expandedMsgID && (msg.Text != "" || msg.Media != nil) {
-		h := 1 // header line
+		h := 1 + quote // header line
 		if msg.Media != nil {
 			h++ // media label line
 		}
@@ -760,7 +1603,7 @@ func (m Model) visualHeight(msg telegram.Message) int {
 		}
 		return h
 	}
-	return 1
+	return 1 + quote
 }
 
 func (m *Model) ensureCursorVisible() {
@@ -813,6 +1656,56 @@ func (m *Model) ensureCursorVisible() {
 	}
 }
 
+// centerCursor scrolls the cursor's message to the middle of the view.
+func (m *Model) centerCursor() {
+	msgs := m.activeMessages()
+	height := m.msgAreaHeight()
+	if len(msgs) == 0 || m.cursor < 0 || height <= 0 {
+		return
+	}
+	total, middle := 0, 0
+	for i, msg := range msgs {
+		h := m.visualHeight(msg)
+		if i == m.cursor {
+			middle = total + h/2
+		}
+		total += h
+	}
+	m.scrollOffset = max(min(total-middle-(height+1)/2, total-height), 0)
+}
+
+// removeMessages drops the given IDs from the loaded history and search
+// results, keeping the cursor on a valid message.
+func (m *Model) removeMessages(ids []int) {
+	drop := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		drop[id] = true
+		delete(m.selected, id)
+		if m.expandedMsgID == id {
+			m.expandedMsgID = -1
+		}
+		if m.editingMsgID == id {
+			m.editingMsgID = 0
+			m.input = ""
+		}
+	}
+	filter := func(msgs []telegram.Message) []telegram.Message {
+		var kept []telegram.Message
+		for _, msg := range msgs {
+			if !drop[msg.ID] {
+				kept = append(kept, msg)
+			}
+		}
+		return kept
+	}
+	m.messages = filter(m.messages)
+	if m.searchResults != nil {
+		m.searchResults = filter(m.searchResults)
+	}
+	m.clampCursor()
+	m.ensureCursorVisible()
+}
+
 func (m *Model) clampCursor() {
 	msgs := m.activeMessages()
 	if len(msgs) == 0 {
@@ -833,12 +1726,21 @@ func (m Model) SetChat(chat *telegram.Chat) Model {
 	m.chat = chat
 	m.messages = nil
 	m.input = ""
+	m.editingMsgID = 0
+	m.replyToMsgID = 0
+	m.attachPath = ""
+	m.lastTypingSent = time.Time{}
 	m.scrollOffset = 0
 	m.cursor = -1
 	m.expandedMsgID = -1
-	m.inputFocused = chat.Type != telegram.ChatTypeChannel
+	m.inputFocused = chat.Type != telegram.ChatTypeChannel && !(chat.Forum && chat.TopicID == 0)
 	m.loadingOlder = false
 	m.noMoreHistory = false
+	m.loadingNewer = false
+	m.hasNewer = false
+	m.readMaxID = chat.ReadInboxMaxID
+	m.readPending = false
+	m.replyParents = nil
 	m.photoCache = nil
 	m.photoLines = nil
 	m.photoLoading = nil
@@ -849,6 +1751,19 @@ func (m Model) SetChat(chat *telegram.Chat) Model {
 	m.searchQuery = ""
 	m.searchResults = nil
 	m.searchActive = false
+	m.searchFor = telegram.SearchQuery{}
+	m.searchNext = telegram.SearchCursor{}
+	m.searchHasMore = false
+	m.loadingResults = false
+	m.highlight = nil
+	m.confirmingDelete = false
+	m.deleteIDs = nil
+	m.topics = nil
+	m.topicCursor = 0
+	m.topicTitle = ""
+	m.topicsNext = telegram.TopicsCursor{}
+	m.topicsHasMore = false
+	m.loadingTopics = false
 	return m
 }
 
@@ -871,8 +1786,21 @@ func (m Model) InputFocused() bool {
 	return m.inputFocused
 }
 
+// SetInputFocus moves focus to or from the composer. Leaving the composer
+// abandons an edit or reply in progress.
 func (m Model) SetInputFocus(focused bool) Model {
 	m.inputFocused = focused
+	if !focused && m.editingMsgID != 0 {
+		m.editingMsgID = 0
+		m.input = ""
+	}
+	if !focused && m.attachPath != "" {
+		m.attachPath = ""
+		m.input = ""
+	}
+	if !focused {
+		m.replyToMsgID = 0
+	}
 	return m
 }
 
@@ -884,6 +1812,10 @@ func (m Model) HasSearchResults() bool {
 	return m.searchActive
 }
 
+func (m Model) IsConfirmingDelete() bool {
+	return m.confirmingDelete
+}
+
 func (m Model) IsSelecting() bool {
 	return m.selecting
 }
@@ -948,25 +1880,30 @@ func (m Model) isDownloadable(info *telegram.MediaInfo) bool {
 }
 
 func (m Model) downloadPath(info *telegram.MediaInfo) string {
-	dir := filepath.Join(os.Getenv("HOME"), "Downloads")
+	dir := m.downloadDir
+	if dir == "" {
+		dir = filepath.Join(os.Getenv("HOME"), "Downloads")
+	}
 	os.MkdirAll(dir, 0o755)
 
 	name := info.FileName
 	if name == "" {
-		ts := time.Now().Format("20060102_150405")
+		// Named after the media's ID, so a cancelled download resumes
+		// into the same file.
+		id := fmt.Sprint(info.DocID)
 		switch info.Type {
 		case telegram.MediaPhoto:
-			name = "photo_" + ts + ".jpg"
+			name = "photo_" + fmt.Sprint(info.PhotoID) + ".jpg"
 		case telegram.MediaVideo:
-			name = "video_" + ts + ".mp4"
+			name = "video_" + id + ".mp4"
 		case telegram.MediaVoice:
-			name = "voice_" + ts + ".ogg"
+			name = "voice_" + id + ".ogg"
 		case telegram.MediaAudio:
-			name = "audio_" + ts + ".mp3"
+			name = "audio_" + id + ".mp3"
 		case telegram.MediaAnimation:
-			name = "animation_" + ts + ".mp4"
+			name = "animation_" + id + ".mp4"
 		default:
-			name = "file_" + ts
+			name = "file_" + id
 		}
 	}
 
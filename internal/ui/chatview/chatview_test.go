@@ -0,0 +1,601 @@
+package chatview
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/paramon-tech/tgtui/internal/telegram"
+	"github.com/paramon-tech/tgtui/internal/telegram/fake"
+	"github.com/paramon-tech/tgtui/internal/ui/common"
+	"github.com/paramon-tech/tgtui/internal/ui/keymap"
+	"github.com/paramon-tech/tgtui/internal/ui/uitest"
+)
+
+var testChat = telegram.Chat{ID: 42, Title: "Alice", Type: telegram.ChatTypePrivate}
+
+func openChat(t *testing.T, tg *fake.Backend) Model {
+	t.Helper()
+	m := New(tg).SetSize(80, 24).SetFocus(true)
+	chat := testChat
+	m = m.SetChat(&chat)
+	m, _ = uitest.Drain(t, m, func() tea.Msg { return tg.FetchHistory(chat)() })
+	return m
+}
+
+func typeText(m Model, text string) Model {
+	for _, r := range text {
+		if r == ' ' {
+			m, _ = m.Update(tea.KeyMsg{Type: tea.KeySpace, Runes: []rune{r}})
+			continue
+		}
+		m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+	}
+	return m
+}
+
+func TestSendMessageRefreshesHistory(t *testing.T) {
+	tg := fake.New(1)
+	tg.SetHistory(testChat.ID, telegram.Message{ID: 10, SenderID: 42, Sender: "Alice", Text: "hi there"})
+
+	m := openChat(t, tg)
+	if len(m.messages) != 1 {
+		t.Fatalf("expected 1 message after open, got %d", len(m.messages))
+	}
+
+	m = typeText(m, "hello back")
+	m, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m, _ = uitest.Drain(t, m, cmd)
+
+	if m.input != "" {
+		t.Errorf("expected composer to be cleared, got %q", m.input)
+	}
+	if len(m.messages) != 2 {
+		t.Fatalf("expected 2 messages after send, got %d", len(m.messages))
+	}
+	last := m.messages[1]
+	if !last.Out || last.Text != "hello back" {
+		t.Errorf("expected outgoing 'hello back', got %+v", last)
+	}
+}
+
+func TestSendFailureReportsStatus(t *testing.T) {
+	tg := fake.New(1)
+	tg.Fail("SendMessage", errors.New("PEER_ID_INVALID"))
+
+	m := openChat(t, tg)
+	m = typeText(m, "x")
+	m, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	_, seen := uitest.Drain(t, m, cmd)
+
+	var status string
+	for _, msg := range seen {
+		if s, ok := msg.(common.StatusMsg); ok {
+			status = s.Text
+		}
+	}
+	if !strings.Contains(status, "PEER_ID_INVALID") {
+		t.Errorf("expected send error in status, got %q (messages: %v)", status, seen)
+	}
+}
+
+func TestIncomingMessageAppends(t *testing.T) {
+	tg := fake.New(1)
+	m := openChat(t, tg)
+
+	m, _ = m.Update(tg.Receive(telegram.Message{ChatID: testChat.ID, SenderID: 42, Sender: "Alice", Text: "ping"}))
+	m, _ = m.Update(tg.Receive(telegram.Message{ChatID: 7, Text: "elsewhere"}))
+
+	if len(m.messages) != 1 || m.messages[0].Text != "ping" {
+		t.Fatalf("expected only the message for the open chat, got %+v", m.messages)
+	}
+	if !strings.Contains(m.View(), "ping") {
+		t.Errorf("expected view to render incoming message")
+	}
+}
+
+func TestEditOwnMessage(t *testing.T) {
+	tg := fake.New(1)
+	tg.SetHistory(testChat.ID,
+		telegram.Message{ID: 10, SenderID: 42, Sender: "Alice", Text: "hi"},
+		telegram.Message{ID: 11, SenderID: 1, Out: true, Text: "helo"},
+	)
+
+	m := openChat(t, tg)
+	m = m.SetInputFocus(false)
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'e'}})
+	if !m.inputFocused || m.input != "helo" {
+		t.Fatalf("expected composer loaded with message text, got focused=%v input=%q", m.inputFocused, m.input)
+	}
+
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyBackspace})
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyBackspace})
+	m = typeText(m, "llo")
+	m, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m, _ = uitest.Drain(t, m, cmd)
+
+	if got := m.messages[1]; got.Text != "hello" || got.EditDate == 0 {
+		t.Errorf("expected edited message with EditDate, got %+v", got)
+	}
+	if !strings.Contains(m.View(), "(edited") {
+		t.Errorf("expected edited marker in view")
+	}
+
+	m, _ = m.Update(tg.EditRemote(testChat.ID, 10, "hi (fixed)"))
+	if got := m.messages[0]; got.Text != "hi (fixed)" || got.Sender != "Alice" {
+		t.Errorf("expected remote edit applied with sender kept, got %+v", got)
+	}
+}
+
+func TestEditKeepsUnchangedFields(t *testing.T) {
+	tg := fake.New(1)
+	tg.SetHistory(testChat.ID,
+		telegram.Message{ID: 10, Date: 1700000000, SenderID: 42, Sender: "Alice", Text: "hi"},
+		telegram.Message{ID: 11, Date: 1700000100, SenderID: 1, Out: true, Text: "helo", ReplyToID: 10},
+	)
+	m := openChat(t, tg)
+
+	// An edit the server did not echo back carries only what we sent.
+	m, _ = m.Update(common.MessageEditedMsg{Message: telegram.Message{
+		ID: 11, ChatID: testChat.ID, SenderID: 1, Out: true, Text: "hello", EditDate: 1700000200,
+	}})
+	got := m.messages[1]
+	if got.Text != "hello" || got.EditDate != 1700000200 {
+		t.Errorf("expected the edit applied, got %+v", got)
+	}
+	if got.Date != 1700000100 || got.ReplyToID != 10 {
+		t.Errorf("expected date and reply kept, got %+v", got)
+	}
+}
+
+func TestDeleteConfirmAndRemote(t *testing.T) {
+	tg := fake.New(1)
+	tg.SetHistory(testChat.ID,
+		telegram.Message{ID: 10, SenderID: 42, Text: "one"},
+		telegram.Message{ID: 11, SenderID: 1, Out: true, Text: "two"},
+		telegram.Message{ID: 12, SenderID: 42, Text: "three"},
+	)
+
+	m := openChat(t, tg)
+	m = m.SetInputFocus(false)
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'d'}})
+	if !m.IsConfirmingDelete() {
+		t.Fatal("expected delete confirmation prompt")
+	}
+	m, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'m'}})
+	m, _ = uitest.Drain(t, m, cmd)
+	if len(m.messages) != 2 || m.messages[1].ID != 11 {
+		t.Fatalf("expected message 12 deleted, got %+v", m.messages)
+	}
+
+	m, _ = m.Update(tg.DeleteRemote(testChat, 10))
+	if len(m.messages) != 1 || m.messages[0].ID != 11 {
+		t.Fatalf("expected remote deletion applied, got %+v", m.messages)
+	}
+	if m.cursor != 0 {
+		t.Errorf("expected cursor clamped to 0, got %d", m.cursor)
+	}
+}
+
+func TestUnrelatedDeletionDoesNotConfirmOurs(t *testing.T) {
+	tg := fake.New(1)
+	tg.SetHistory(testChat.ID,
+		telegram.Message{ID: 10, SenderID: 42, Text: "one"},
+		telegram.Message{ID: 11, SenderID: 1, Out: true, Text: "two"},
+		telegram.Message{ID: 12, SenderID: 42, Text: "three"},
+	)
+
+	m := openChat(t, tg).SetInputFocus(false)
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'d'}})
+	m, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'m'}})
+
+	// Another deletion in the chat lands before the server answers ours.
+	m, status := m.Update(common.MessagesDeletedMsg{ChatID: testChat.ID, IDs: []int{10}})
+	if status != nil || m.deleteIDs == nil {
+		t.Fatalf("an unrelated deletion was taken as confirmation (deleteIDs %v)", m.deleteIDs)
+	}
+
+	_, seen := uitest.Drain(t, m, cmd)
+	var texts []string
+	for _, msg := range seen {
+		if s, ok := msg.(common.StatusMsg); ok {
+			texts = append(texts, s.Text)
+		}
+	}
+	if !slices.Contains(texts, "Deleted 1 message(s)") {
+		t.Errorf("expected our deletion confirmed, got statuses %q", texts)
+	}
+}
+
+func TestReplyQuoteAndJumpToParent(t *testing.T) {
+	tg := fake.New(1)
+	var history []telegram.Message
+	for id := 1; id <= 120; id++ {
+		history = append(history, telegram.Message{ID: id, SenderID: 42, Sender: "Alice", Text: "msg"})
+	}
+	history[5].Text = "the original question"
+	history[119].ReplyToID = 6
+	tg.SetHistory(testChat.ID, history...)
+
+	m := openChat(t, tg)
+	if m.indexOf(6) >= 0 {
+		t.Fatal("parent should be outside the first page")
+	}
+	if !strings.Contains(m.View(), "┌ Alice: the original question") {
+		t.Errorf("expected quoted parent fetched by ID, got view:\n%s", m.View())
+	}
+
+	m = m.SetInputFocus(false)
+	m, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'p'}})
+	m, _ = uitest.Drain(t, m, cmd)
+	if m.cursor < 0 || m.messages[m.cursor].ID != 6 {
+		t.Fatalf("expected cursor on parent after jump, got %d", m.cursor)
+	}
+	if !m.hasNewer {
+		t.Error("expected window around parent to report newer history")
+	}
+
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'r'}})
+	m = typeText(m, "answer")
+	m, cmd = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	_, _ = uitest.Drain(t, m, cmd)
+	h := tg.History(testChat.ID)
+	if last := h[len(h)-1]; last.Text != "answer" || last.ReplyToID != 6 {
+		t.Errorf("expected reply to message 6, got %+v", last)
+	}
+}
+
+func TestHistoryAroundCentresMessage(t *testing.T) {
+	tg := fake.New(1)
+	var history []telegram.Message
+	for id := 1; id <= 120; id++ {
+		history = append(history, telegram.Message{ID: id, SenderID: 42, Sender: "Alice", Text: fmt.Sprintf("msg %d", id)})
+	}
+	tg.SetHistory(testChat.ID, history...)
+
+	m := New(tg).SetSize(80, 24).SetFocus(true)
+	chat := testChat
+	m = m.SetChat(&chat).SetInputFocus(false)
+	m, _ = uitest.Drain(t, m, func() tea.Msg { return tg.FetchHistoryAround(chat, 60)() })
+
+	lines := strings.Split(m.View(), "\n")
+	row := -1
+	for i, line := range lines {
+		if strings.Contains(line, "msg 60") {
+			row = i
+		}
+	}
+	// Below the title, the message area is 22 lines tall.
+	if middle := 1 + 22/2; row != middle {
+		t.Errorf("expected message 60 on line %d, got %d", middle, row)
+	}
+}
+
+// inboxRead returns the last read acknowledgement among msgs.
+func inboxRead(t *testing.T, msgs []tea.Msg) common.InboxReadMsg {
+	t.Helper()
+	var read *common.InboxReadMsg
+	for _, msg := range msgs {
+		if r, ok := msg.(common.InboxReadMsg); ok {
+			read = &r
+		}
+	}
+	if read == nil {
+		t.Fatalf("expected the chat to be marked read, got %v", msgs)
+	}
+	return *read
+}
+
+func TestMarkReadFullHistory(t *testing.T) {
+	tg := fake.New(1)
+	tg.SetHistory(testChat.ID,
+		telegram.Message{ID: 10, SenderID: 42, Sender: "Alice", Text: "one"},
+		telegram.Message{ID: 11, Out: true, Text: "two"},
+		telegram.Message{ID: 12, SenderID: 42, Sender: "Alice", Text: "three"},
+	)
+
+	m := New(tg).SetSize(80, 24).SetFocus(true)
+	chat := testChat
+	m = m.SetChat(&chat)
+	m, seen := uitest.Drain(t, m, func() tea.Msg { return tg.FetchHistory(chat)() })
+
+	if read := inboxRead(t, seen); read.MaxID != 12 || read.StillUnread != 0 {
+		t.Errorf("expected read up to 12 with nothing left, got %+v", read)
+	}
+	if m.readPending {
+		t.Errorf("expected the read to be settled")
+	}
+}
+
+func TestMarkReadPartialWindow(t *testing.T) {
+	tg := fake.New(1)
+	var history []telegram.Message
+	for id := 1; id <= 120; id++ {
+		history = append(history, telegram.Message{ID: id, SenderID: 42, Sender: "Alice", Text: fmt.Sprintf("msg %d", id)})
+	}
+	tg.SetHistory(testChat.ID, history...)
+
+	m := New(tg).SetSize(80, 24).SetFocus(true)
+	chat := testChat
+	m = m.SetChat(&chat).SetInputFocus(false)
+	m, seen := uitest.Drain(t, m, func() tea.Msg { return tg.FetchHistoryAround(chat, 60)() })
+
+	// The window stops short of the latest message, so the remaining
+	// count has to come from the backend rather than the loaded history.
+	read := inboxRead(t, seen)
+	if read.MaxID < 60 || read.MaxID >= 120 {
+		t.Fatalf("expected a read inside the window, got %+v", read)
+	}
+	if want := 120 - read.MaxID; read.StillUnread != want {
+		t.Errorf("expected %d still unread, got %d", want, read.StillUnread)
+	}
+	if m.readMaxID != read.MaxID {
+		t.Errorf("expected readMaxID %d, got %d", read.MaxID, m.readMaxID)
+	}
+}
+
+func TestRemoteReadAdvancesReadMark(t *testing.T) {
+	tg := fake.New(1)
+	var history []telegram.Message
+	for id := 1; id <= 120; id++ {
+		history = append(history, telegram.Message{ID: id, SenderID: 42, Sender: "Alice", Text: fmt.Sprintf("msg %d", id)})
+	}
+	tg.SetHistory(testChat.ID, history...)
+
+	m := New(tg).SetSize(80, 24).SetFocus(true)
+	chat := testChat
+	m = m.SetChat(&chat).SetInputFocus(false)
+	m, _ = uitest.Drain(t, m, func() tea.Msg { return tg.FetchHistoryAround(chat, 60)() })
+
+	m, cmd := m.Update(tg.ReadRemote(testChat.ID, 120))
+	if m.readMaxID != 120 {
+		t.Errorf("expected a read on another device to advance readMaxID, got %d", m.readMaxID)
+	}
+	if _, seen := uitest.Drain(t, m, cmd); len(seen) != 0 {
+		t.Errorf("expected no further read request, got %v", seen)
+	}
+}
+
+func TestMarkReadTopic(t *testing.T) {
+	tg := fake.New(1)
+	forum := telegram.Chat{ID: 77, AccessHash: 5, Title: "Gophers", Type: telegram.ChatTypeGroup, Forum: true, TopicID: 10}
+	tg.SetHistory(forum.ID,
+		telegram.Message{ID: 11, SenderID: 42, Sender: "Alice", Text: "v1.0 is out", TopicID: 10},
+		telegram.Message{ID: 12, SenderID: 42, Sender: "Alice", Text: "changelog", TopicID: 10},
+		telegram.Message{ID: 13, SenderID: 42, Sender: "Alice", Text: "off topic"},
+	)
+
+	m := New(tg).SetSize(80, 24).SetFocus(true)
+	m = m.SetChat(&forum)
+	_, seen := uitest.Drain(t, m, func() tea.Msg { return tg.FetchHistory(forum)() })
+
+	// The thread is read to the end, but the count left is the forum's.
+	read := inboxRead(t, seen)
+	if read.TopicID != 10 || read.MaxID != 12 || read.StillUnread != 1 {
+		t.Errorf("expected topic 10 read up to 12 with 1 left in the forum, got %+v", read)
+	}
+}
+
+func TestForumTopics(t *testing.T) {
+	tg := fake.New(1)
+	forum := telegram.Chat{ID: 77, AccessHash: 5, Title: "Gophers", Type: telegram.ChatTypeGroup, Forum: true}
+	tg.SetTopics(forum.ID,
+		telegram.Topic{ID: telegram.GeneralTopicID, Title: "General"},
+		telegram.Topic{ID: 10, Title: "Releases", UnreadCount: 1},
+	)
+	tg.SetHistory(forum.ID,
+		telegram.Message{ID: 5, SenderID: 42, Sender: "Alice", Text: "hello all"},
+		telegram.Message{ID: 11, SenderID: 42, Sender: "Alice", Text: "v1.0 is out", TopicID: 10},
+	)
+
+	m := New(tg).SetSize(80, 24).SetFocus(true)
+	m = m.SetChat(&forum)
+	m, _ = uitest.Drain(t, m, func() tea.Msg { return tg.FetchForumTopics(forum)() })
+	if !strings.Contains(m.View(), "Releases (1)") {
+		t.Fatalf("expected topic list with unread count, got view:\n%s", m.View())
+	}
+
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'j'}})
+	m, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m, _ = uitest.Drain(t, m, cmd)
+	if len(m.messages) != 1 || m.messages[0].ID != 11 {
+		t.Fatalf("expected only the topic's thread, got %+v", m.messages)
+	}
+
+	m, _ = m.Update(tg.Receive(telegram.Message{ChatID: forum.ID, SenderID: 42, Text: "off topic"}))
+	if len(m.messages) != 1 {
+		t.Errorf("expected message from General to stay out of the thread, got %+v", m.messages)
+	}
+
+	m = typeText(m, "congrats")
+	m, cmd = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m, _ = uitest.Drain(t, m, cmd)
+	h := tg.History(forum.ID)
+	if last := h[len(h)-1]; last.Text != "congrats" || last.TopicID != 10 {
+		t.Errorf("expected reply sent into topic 10, got %+v", last)
+	}
+
+	m = m.SetInputFocus(false)
+	m, cmd = m.Update(tea.KeyMsg{Type: tea.KeyEscape})
+	m, _ = uitest.Drain(t, m, cmd)
+	if !m.inTopicList() || !strings.Contains(m.View(), "General") {
+		t.Errorf("expected Esc to return to the topic list")
+	}
+}
+
+func TestForumTopicsPages(t *testing.T) {
+	tg := fake.New(1)
+	tg.SetTopicsPageSize(20)
+	forum := telegram.Chat{ID: 77, AccessHash: 5, Title: "Gophers", Type: telegram.ChatTypeGroup, Forum: true}
+	topics := []telegram.Topic{{ID: telegram.GeneralTopicID, Title: "General", Hidden: true}}
+	for id := 2; id <= 45; id++ {
+		topics = append(topics, telegram.Topic{ID: id, Title: fmt.Sprintf("topic %d", id)})
+	}
+	tg.SetTopics(forum.ID, topics...)
+
+	m := New(tg).SetSize(80, 24).SetFocus(true)
+	m = m.SetChat(&forum)
+	m, _ = uitest.Drain(t, m, func() tea.Msg { return tg.FetchForumTopics(forum)() })
+	if len(m.topics) != 20 {
+		t.Fatalf("expected the first page of 20 topics, got %d", len(m.topics))
+	}
+	if !strings.Contains(m.View(), "General (hidden)") {
+		t.Errorf("expected hidden General listed and marked, got view:\n%s", m.View())
+	}
+
+	for i := 0; i < 60; i++ {
+		var cmd tea.Cmd
+		m, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'j'}})
+		m, _ = uitest.Drain(t, m, cmd)
+	}
+	if len(m.topics) != 45 || m.topicsHasMore || m.loadingTopics {
+		t.Errorf("expected all 45 topics loaded, got %d (hasMore=%v loading=%v)", len(m.topics), m.topicsHasMore, m.loadingTopics)
+	}
+	if last := m.topics[len(m.topics)-1]; last.ID != 45 || m.topicCursor != 44 {
+		t.Errorf("expected the cursor on topic 45 at the end, got topic %d at %d", last.ID, m.topicCursor)
+	}
+}
+
+func TestAttachFileWithCaption(t *testing.T) {
+	tg := fake.New(1)
+	path := filepath.Join(t.TempDir(), "cat.png")
+	if err := os.WriteFile(path, []byte("\x89PNG\r\n\x1a\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	m := openChat(t, tg)
+	m = typeText(m, ":attach "+path)
+	m, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m, _ = uitest.Drain(t, m, cmd)
+	if m.attachPath != path || m.attachAs != telegram.SendAsPhoto {
+		t.Fatalf("expected png attached as photo, got %q as %v", m.attachPath, m.attachAs)
+	}
+
+	// Send it as a document instead.
+	for m.attachAs != telegram.SendAsDocument {
+		m, _ = m.Update(tea.KeyMsg{Type: tea.KeyCtrlT})
+	}
+	m = typeText(m, "look")
+	m, cmd = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m, _ = uitest.Drain(t, m, cmd)
+
+	if m.attachPath != "" {
+		t.Error("expected attachment cleared after sending")
+	}
+	last := m.messages[len(m.messages)-1]
+	if last.Media == nil || last.Media.Type != telegram.MediaDocument || last.Text != "look" {
+		t.Errorf("expected document with caption, got %+v", last)
+	}
+}
+
+func TestSearchFilters(t *testing.T) {
+	tg := fake.New(1)
+	day := func(d int) int { return int(time.Date(2026, 3, d, 12, 0, 0, 0, time.Local).Unix()) }
+	photo := &telegram.MediaInfo{Type: telegram.MediaPhoto, Label: "[Photo]"}
+	tg.SetHistory(testChat.ID,
+		telegram.Message{ID: 1, Date: day(1), SenderID: 42, Sender: "Alice", Text: "beach", Media: photo},
+		telegram.Message{ID: 2, Date: day(5), SenderID: 42, Sender: "Alice", Text: "beach again", Media: photo},
+		telegram.Message{ID: 3, Date: day(5), SenderID: 42, Sender: "Alice", Text: "beach trip?"},
+		telegram.Message{ID: 4, Date: day(6), Out: true, Text: "beach", Media: photo},
+		telegram.Message{ID: 5, Date: day(9), SenderID: 42, Sender: "Alice", Text: "beach", Media: photo},
+	)
+
+	m := openChat(t, tg).SetInputFocus(false)
+	m, _ = m.Update(common.ActionMsg{Action: keymap.Search})
+	m = typeText(m, "beach from:@alice type:photo after:2026-03-02 before:2026-03-09")
+	m, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m, _ = uitest.Drain(t, m, cmd)
+	if len(m.searchResults) != 1 || m.searchResults[0].ID != 2 {
+		t.Errorf("expected only message 2 to match, got %+v", m.searchResults)
+	}
+
+	// A malformed filter is reported and leaves the prompt open.
+	m, _ = m.Update(common.ActionMsg{Action: keymap.Search})
+	m = typeText(m, "type:sticker")
+	m, cmd = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	_, seen := uitest.Drain(t, m, cmd)
+	if !m.searching || len(seen) != 1 || !strings.Contains(seen[0].(common.StatusMsg).Text, `unknown type "sticker"`) {
+		t.Errorf("expected the prompt to stay open with an error, got %v", seen)
+	}
+}
+
+func TestSearchPagesAndGoesToMessage(t *testing.T) {
+	tg := fake.New(1)
+	var history []telegram.Message
+	for id := 1; id <= 300; id++ {
+		text := fmt.Sprintf("msg %d", id)
+		if id%2 == 0 {
+			text = fmt.Sprintf("msg %d about Cats", id)
+		}
+		history = append(history, telegram.Message{ID: id, SenderID: 42, Sender: "Alice", Text: text})
+	}
+	tg.SetHistory(testChat.ID, history...)
+
+	m := openChat(t, tg).SetInputFocus(false)
+	m, _ = m.Update(common.ActionMsg{Action: keymap.Search})
+	m = typeText(m, "cats")
+	m, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m, _ = uitest.Drain(t, m, cmd)
+	if len(m.searchResults) != 50 || !m.searchHasMore {
+		t.Fatalf("expected a first page of 50 with more to come, got %d (more %v)", len(m.searchResults), m.searchHasMore)
+	}
+
+	// Moving towards the oldest result loads the ones before it.
+	m, cmd = m.Update(common.ActionMsg{Action: keymap.Top})
+	m, _ = uitest.Drain(t, m, cmd)
+	if len(m.searchResults) != 100 || m.searchResults[0].ID != 102 {
+		t.Fatalf("expected 100 results from message 102, got %d from %d", len(m.searchResults), m.searchResults[0].ID)
+	}
+	if m.searchResults[m.cursor].ID != 202 {
+		t.Errorf("expected the cursor to stay on message 202, got %d", m.searchResults[m.cursor].ID)
+	}
+	for m.searchHasMore {
+		m, cmd = m.Update(common.ActionMsg{Action: keymap.Top})
+		m, _ = uitest.Drain(t, m, cmd)
+	}
+	if len(m.searchResults) != 150 {
+		t.Fatalf("expected all 150 results, got %d", len(m.searchResults))
+	}
+
+	// Going to a result shows it in its history, with the match marked.
+	m, _ = m.Update(common.ActionMsg{Action: keymap.Top})
+	m, cmd = m.Update(common.ActionMsg{Action: keymap.GoToMessage})
+	m, _ = uitest.Drain(t, m, cmd)
+	if m.searchActive {
+		t.Fatal("going to a message should leave the results")
+	}
+	if m.cursor < 0 || m.messages[m.cursor].ID != 2 || m.indexOf(1) < 0 || m.indexOf(3) < 0 {
+		t.Fatalf("expected history around message 2 with the cursor on it, got cursor %d", m.cursor)
+	}
+	if !strings.Contains(m.View(), "Cats\x1b[0m") {
+		t.Errorf("expected the match highlighted, got view:\n%s", m.View())
+	}
+	m, _ = m.Update(common.ActionMsg{Action: keymap.Back})
+	if m.highlight != nil || strings.Contains(m.View(), "Cats\x1b[0m") {
+		t.Error("back should clear the highlight")
+	}
+}
+
+func TestDownloadPathStableForUnnamedMedia(t *testing.T) {
+	m := New(fake.New(1))
+	m.downloadDir = t.TempDir()
+	voice := &telegram.MediaInfo{Type: telegram.MediaVoice, DocID: 555}
+
+	path := m.downloadPath(voice)
+	if filepath.Base(path) != "voice_555.ogg" {
+		t.Errorf("expected a name from the document ID, got %s", path)
+	}
+	// A cancelled download leaves only the partial file, and resumes into it.
+	if err := os.WriteFile(path+".part", []byte("half"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if again := m.downloadPath(voice); again != path {
+		t.Errorf("expected the same path to resume, got %s then %s", path, again)
+	}
+}
@@ -0,0 +1,205 @@
+package chatview
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/paramon-tech/tgtui/internal/telegram"
+	"github.com/paramon-tech/tgtui/internal/ui/common"
+	"github.com/paramon-tech/tgtui/internal/ui/keymap"
+)
+
+// inTopicList reports whether a forum is open without a topic chosen, in
+// which case the view lists the forum's topics instead of messages.
+func (m Model) inTopicList() bool {
+	return m.chat != nil && m.chat.Forum && m.chat.TopicID == 0
+}
+
+func (m Model) handleTopicKey(action keymap.Action) (Model, tea.Cmd) {
+	switch action {
+	case keymap.Up:
+		if m.topicCursor > 0 {
+			m.topicCursor--
+		}
+	case keymap.Down:
+		if m.topicCursor < len(m.topics)-1 {
+			m.topicCursor++
+		}
+	case keymap.Top:
+		m.topicCursor = 0
+	case keymap.Bottom:
+		if len(m.topics) > 0 {
+			m.topicCursor = len(m.topics) - 1
+		}
+	case keymap.Open:
+		if m.topicCursor < len(m.topics) {
+			return m.openTopic(m.topics[m.topicCursor])
+		}
+	}
+	return m, m.maybeLoadMoreTopics()
+}
+
+// topicsLoadThreshold is how close to the last loaded topic the cursor
+// gets before the next page is requested.
+const topicsLoadThreshold = 10
+
+// maybeLoadMoreTopics requests the next page of topics once the cursor
+// nears the end of the list, or while the open topic is not on it yet.
+func (m *Model) maybeLoadMoreTopics() tea.Cmd {
+	if m.chat == nil || m.loadingTopics || !m.topicsHasMore {
+		return nil
+	}
+	if m.inTopicList() {
+		if m.topicCursor < len(m.topics)-topicsLoadThreshold {
+			return nil
+		}
+	} else if m.topicTitle != "" {
+		return nil
+	}
+	m.loadingTopics = true
+	tg := m.tg
+	chat := *m.chat
+	cursor := m.topicsNext
+	return func() tea.Msg {
+		return tg.FetchMoreForumTopics(chat, cursor)()
+	}
+}
+
+// mergeTopics appends a further page of topics, skipping any that moved
+// up into an earlier page since it was loaded.
+func (m *Model) mergeTopics(topics []telegram.Topic) {
+	for _, t := range topics {
+		if !slices.ContainsFunc(m.topics, func(have telegram.Topic) bool { return have.ID == t.ID }) {
+			m.topics = append(m.topics, t)
+		}
+	}
+}
+
+// findTopicTitle fills in the title of a topic opened straight from a
+// search result, which has none until the topic shows up in the list.
+func (m *Model) findTopicTitle() {
+	if m.chat.TopicID == 0 || m.topicTitle != "" {
+		return
+	}
+	for i, t := range m.topics {
+		if t.ID == m.chat.TopicID {
+			m.topicTitle = t.Title
+			m.topicCursor = i
+		}
+	}
+}
+
+// openTopic switches the view to a topic's thread.
+func (m Model) openTopic(topic telegram.Topic) (Model, tea.Cmd) {
+	chat := *m.chat
+	chat.TopicID = topic.ID
+	topics, cursor := m.topics, m.topicCursor
+	m = m.SetChat(&chat)
+	m.topics, m.topicCursor = topics, cursor
+	m.topicTitle = topic.Title
+	m.readMaxID = topic.ReadInboxMaxID
+	tg := m.tg
+	return m, func() tea.Msg {
+		return tg.FetchHistory(chat)()
+	}
+}
+
+// closeTopic goes back from a thread to the forum's topic list.
+func (m Model) closeTopic() (Model, tea.Cmd) {
+	chat := *m.chat
+	chat.TopicID = 0
+	topics, cursor := m.topics, m.topicCursor
+	m = m.SetChat(&chat)
+	m.topics, m.topicCursor = topics, cursor
+	tg := m.tg
+	return m, func() tea.Msg {
+		return tg.FetchForumTopics(chat)()
+	}
+}
+
+// noteTopicMessage keeps the topic list current as messages arrive.
+func (m *Model) noteTopicMessage(msg telegram.Message) {
+	for i, t := range m.topics {
+		if !msg.InTopic(t.ID) {
+			continue
+		}
+		if !msg.Out && msg.ID > t.ReadInboxMaxID {
+			m.topics[i].UnreadCount++
+		}
+		last := msg
+		m.topics[i].LastMessage = &last
+		if t.Pinned {
+			return
+		}
+		// Move to the top of the unpinned topics, keeping the cursor on
+		// the topic it was on.
+		top := 0
+		for top < len(m.topics) && m.topics[top].Pinned {
+			top++
+		}
+		topic := m.topics[i]
+		copy(m.topics[top+1:i+1], m.topics[top:i])
+		m.topics[top] = topic
+		switch {
+		case m.topicCursor == i:
+			m.topicCursor = top
+		case m.topicCursor >= top && m.topicCursor < i:
+			m.topicCursor++
+		}
+		return
+	}
+}
+
+func (m Model) renderTopics(height int) string {
+	if height <= 0 {
+		return ""
+	}
+	if len(m.topics) == 0 {
+		return lipgloss.Place(m.width, height, lipgloss.Center, lipgloss.Center,
+			common.StyleMuted.Render("Loading topics..."))
+	}
+
+	offset := 0
+	if m.topicCursor >= height {
+		offset = m.topicCursor - height + 1
+	}
+	var lines []string
+	for i := offset; i < len(m.topics) && len(lines) < height; i++ {
+		t := m.topics[i]
+		prefix := "  "
+		if i == m.topicCursor {
+			prefix = lipgloss.NewStyle().Foreground(common.ColorPrimary).Render(">") + " "
+		}
+		title := t.Title
+		if t.Pinned {
+			title = "📌 " + title
+		}
+		if t.Closed {
+			title += common.StyleMuted.Render(" (closed)")
+		}
+		if t.Hidden {
+			title += common.StyleMuted.Render(" (hidden)")
+		}
+		var unread string
+		if t.UnreadCount > 0 {
+			unread = common.StyleUnread.Render(fmt.Sprintf(" (%d)", t.UnreadCount))
+		}
+		var last string
+		if t.LastMessage != nil {
+			text := strings.ReplaceAll(t.LastMessage.Text, "\n", " ")
+			if text == "" && t.LastMessage.Media != nil {
+				text = t.LastMessage.Media.Label
+			}
+			last = common.StyleMuted.Render("  " + text)
+		}
+		line := prefix + lipgloss.NewStyle().Bold(i == m.topicCursor).Render(title) + unread + last
+		lines = append(lines, lipgloss.NewStyle().MaxWidth(m.width).Render(line))
+	}
+	for len(lines) < height {
+		lines = append(lines, "")
+	}
+	return strings.Join(lines, "\n")
+}
@@ -1,6 +1,11 @@
 package common
 
-import "github.com/paramon-tech/tgtui/internal/telegram"
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/paramon-tech/tgtui/internal/config"
+	"github.com/paramon-tech/tgtui/internal/telegram"
+	"github.com/paramon-tech/tgtui/internal/ui/keymap"
+)
 
 // Re-export telegram messages as UI messages for convenience.
 type (
@@ -9,25 +14,64 @@ type (
 	CodeSentMsg           = telegram.CodeSentMsg
 	AuthErrorMsg          = telegram.AuthErrorMsg
 	Need2FAMsg            = telegram.Need2FAMsg
+	NeedPassphraseMsg     = telegram.NeedPassphraseMsg
+	SessionUnlockedMsg    = telegram.SessionUnlockedMsg
 	DialogsLoadedMsg      = telegram.DialogsLoadedMsg
 	DialogsErrorMsg       = telegram.DialogsErrorMsg
+	MoreDialogsLoadedMsg  = telegram.MoreDialogsLoadedMsg
+	MoreDialogsErrorMsg   = telegram.MoreDialogsErrorMsg
+	FoldersLoadedMsg      = telegram.FoldersLoadedMsg
+	FoldersErrorMsg       = telegram.FoldersErrorMsg
+	ChatFolderChangedMsg  = telegram.ChatFolderChangedMsg
+	FolderChangeErrorMsg  = telegram.FolderChangeErrorMsg
+	InboxReadMsg          = telegram.InboxReadMsg
+	ReadErrorMsg          = telegram.ReadErrorMsg
+	ForumTopicsLoadedMsg  = telegram.ForumTopicsLoadedMsg
+	ForumTopicsErrorMsg   = telegram.ForumTopicsErrorMsg
+	MoreTopicsLoadedMsg   = telegram.MoreTopicsLoadedMsg
+	MoreTopicsErrorMsg    = telegram.MoreTopicsErrorMsg
 	HistoryLoadedMsg      = telegram.HistoryLoadedMsg
 	HistoryErrorMsg       = telegram.HistoryErrorMsg
 	OlderHistoryLoadedMsg = telegram.OlderHistoryLoadedMsg
 	OlderHistoryErrorMsg  = telegram.OlderHistoryErrorMsg
+	NewerHistoryLoadedMsg = telegram.NewerHistoryLoadedMsg
+	NewerHistoryErrorMsg  = telegram.NewerHistoryErrorMsg
+	MessagesFetchedMsg    = telegram.MessagesFetchedMsg
+	MessagesFetchErrorMsg = telegram.MessagesFetchErrorMsg
 	NewMessageMsg         = telegram.NewMessageMsg
+	HistoryGapMsg         = telegram.HistoryGapMsg
+	UpdatesErrorMsg       = telegram.UpdatesErrorMsg
+	RateLimitedMsg        = telegram.RateLimitedMsg
+	ConnectionStateMsg    = telegram.ConnectionStateMsg
+	AccountMsg            = telegram.AccountMsg
 	MessageSentMsg        = telegram.MessageSentMsg
 	MessageSendErrorMsg   = telegram.MessageSendErrorMsg
+	UploadProgressMsg     = telegram.UploadProgressMsg
+	FileSentMsg           = telegram.FileSentMsg
+	FileSendErrorMsg      = telegram.FileSendErrorMsg
+	MessageEditedMsg      = telegram.MessageEditedMsg
+	MessageEditErrorMsg   = telegram.MessageEditErrorMsg
+	MessagesDeletedMsg    = telegram.MessagesDeletedMsg
+	DeleteErrorMsg        = telegram.DeleteErrorMsg
 	DownloadPhotoMsg      = telegram.DownloadPhotoMsg
 	DownloadPhotoErrorMsg = telegram.DownloadPhotoErrorMsg
 	SaveFileMsg           = telegram.SaveFileMsg
+	DownloadQueuedMsg     = telegram.DownloadQueuedMsg
+	DownloadProgressMsg   = telegram.DownloadProgressMsg
+	DownloadCancelledMsg  = telegram.DownloadCancelledMsg
 	SaveFileErrorMsg      = telegram.SaveFileErrorMsg
 	ForwardedMsg          = telegram.ForwardedMsg
 	ForwardErrorMsg       = telegram.ForwardErrorMsg
 	ReactionsUpdatedMsg   = telegram.ReactionsUpdatedMsg
+	TypingMsg             = telegram.TypingMsg
+	UserStatusMsg         = telegram.UserStatusMsg
 	QRTokenMsg            = telegram.QRTokenMsg
 	SearchResultMsg       = telegram.SearchResultMsg
+	SearchMoreMsg         = telegram.SearchMoreMsg
 	SearchErrorMsg        = telegram.SearchErrorMsg
+	GlobalSearchResultMsg = telegram.GlobalSearchResultMsg
+	GlobalSearchMoreMsg   = telegram.GlobalSearchMoreMsg
+	GlobalSearchErrorMsg  = telegram.GlobalSearchErrorMsg
 )
 
 // FatalErrorMsg is sent when the telegram client encounters a fatal error.
@@ -35,16 +79,62 @@ type FatalErrorMsg struct {
 	Err error
 }
 
+// ReloadConfigMsg asks for the config file to be read again, on SIGHUP or
+// the :reload command.
+type ReloadConfigMsg struct{}
+
+// ConfigLoadedMsg carries freshly loaded settings to every account.
+type ConfigLoadedMsg struct {
+	Config *config.Config
+}
+
+// ActionMsg is a key press, or key sequence ending in Key, that the
+// keymap resolved to Action in the current mode.
+type ActionMsg struct {
+	Action keymap.Action
+	Key    tea.KeyMsg
+}
+
+// SwitchAccountMsg asks for the account switcher.
+type SwitchAccountMsg struct{}
+
 // ChatSelectedMsg is sent when a user selects a chat from the list.
 type ChatSelectedMsg struct {
 	Chat telegram.Chat
 }
 
+// OpenMessageMsg is sent when the user picks a global search result: the
+// chat (with TopicID set for forum topics) opens on that message.
+type OpenMessageMsg struct {
+	Chat      telegram.Chat
+	MessageID int
+}
+
 // StatusMsg updates the status bar text.
 type StatusMsg struct {
 	Text string
 }
 
+// TypingExpiredMsg prompts models to drop typing indicators that were not
+// refreshed within TypingTimeout.
+type TypingExpiredMsg struct{}
+
+// PresenceTickMsg re-renders relative "last seen" times and lapses
+// expired online statuses.
+type PresenceTickMsg struct{}
+
+// LoadMoreDialogsMsg is sent when the chat list wants the next page of dialogs.
+type LoadMoreDialogsMsg struct {
+	Cursor telegram.DialogsCursor
+}
+
+// MoveChatMsg is sent when the user archives (FolderID telegram.ArchiveFolderID)
+// or unarchives (0) a chat from the chat list.
+type MoveChatMsg struct {
+	Chat     telegram.Chat
+	FolderID int
+}
+
 // ForwardRequestMsg is sent when the user has selected messages and pressed 'f'.
 type ForwardRequestMsg struct {
 	FromChat   telegram.Chat
@@ -1,43 +1,49 @@
 package common
 
-import "github.com/charmbracelet/lipgloss"
+import (
+	"github.com/charmbracelet/lipgloss"
+	"github.com/paramon-tech/tgtui/internal/theme"
+)
 
+// Colors and styles of the current theme, set by ApplyTheme.
 var (
-	ColorPrimary   = lipgloss.Color("#7AA2F7")
-	ColorSecondary = lipgloss.Color("#9ECE6A")
-	ColorMuted     = lipgloss.Color("#565F89")
-	ColorError     = lipgloss.Color("#F7768E")
-	ColorWarning   = lipgloss.Color("#E0AF68")
-
-	StyleTitle = lipgloss.NewStyle().
-			Bold(true).
-			Foreground(ColorPrimary)
-
-	StyleSelected = lipgloss.NewStyle().
-			Bold(true).
-			Foreground(ColorPrimary)
-
-	StyleMuted = lipgloss.NewStyle().
-			Foreground(ColorMuted)
-
-	StyleError = lipgloss.NewStyle().
-			Foreground(ColorError)
-
-	StyleSender = lipgloss.NewStyle().
-			Bold(true).
-			Foreground(ColorSecondary)
-
-	StyleSenderSelf = lipgloss.NewStyle().
-			Bold(true).
-			Foreground(ColorPrimary)
-
-	StyleTimestamp = lipgloss.NewStyle().
-			Foreground(ColorMuted)
-
-	StyleUnread = lipgloss.NewStyle().
-			Bold(true).
-			Foreground(ColorWarning)
-
-	StyleMediaLabel = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#7DCFFF"))
+	ColorPrimary   lipgloss.TerminalColor
+	ColorSecondary lipgloss.TerminalColor
+	ColorMuted     lipgloss.TerminalColor
+	ColorError     lipgloss.TerminalColor
+	ColorWarning   lipgloss.TerminalColor
+
+	StyleTitle      lipgloss.Style
+	StyleSelected   lipgloss.Style
+	StyleMuted      lipgloss.Style
+	StyleError      lipgloss.Style
+	StyleSender     lipgloss.Style
+	StyleSenderSelf lipgloss.Style
+	StyleTimestamp  lipgloss.Style
+	StyleUnread     lipgloss.Style
+	StyleMediaLabel lipgloss.Style
 )
+
+func init() {
+	ApplyTheme(theme.Default())
+}
+
+// ApplyTheme switches the colors and styles to t. Views pick them up on
+// their next render.
+func ApplyTheme(t *theme.Theme) {
+	ColorPrimary = t.Primary
+	ColorSecondary = t.Secondary
+	ColorMuted = t.Muted
+	ColorError = t.Error
+	ColorWarning = t.Warning
+
+	StyleTitle = t.Title.Lipgloss()
+	StyleSelected = t.Selected.Lipgloss()
+	StyleMuted = t.MutedText.Lipgloss()
+	StyleError = t.ErrorText.Lipgloss()
+	StyleSender = t.Sender.Lipgloss()
+	StyleSenderSelf = t.SenderSelf.Lipgloss()
+	StyleTimestamp = t.Timestamp.Lipgloss()
+	StyleUnread = t.Unread.Lipgloss()
+	StyleMediaLabel = t.MediaLabel.Lipgloss()
+}
@@ -0,0 +1,99 @@
+package common
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// TypingTimeout is how long a typing indicator lasts without a refresh.
+// Telegram clients resend their status about every five seconds.
+const TypingTimeout = 6 * time.Second
+
+type typingEntry struct {
+	name    string
+	action  string
+	expires time.Time
+}
+
+// TypingState tracks who is typing in which chat. The zero value is ready
+// to use; it is copied along with the model that embeds it, so the maps
+// are shared between copies like the rest of a model's maps.
+type TypingState struct {
+	chats map[int64]map[int64]typingEntry // chatID → userID → entry
+}
+
+// Apply records a TypingMsg received at now.
+func (s *TypingState) Apply(msg TypingMsg, now time.Time) {
+	if msg.Action == "" {
+		delete(s.chats[msg.ChatID], msg.UserID)
+		return
+	}
+	if s.chats == nil {
+		s.chats = make(map[int64]map[int64]typingEntry)
+	}
+	if s.chats[msg.ChatID] == nil {
+		s.chats[msg.ChatID] = make(map[int64]typingEntry)
+	}
+	s.chats[msg.ChatID][msg.UserID] = typingEntry{
+		name:    msg.Name,
+		action:  msg.Action,
+		expires: now.Add(TypingTimeout),
+	}
+}
+
+// Stop clears a user's indicator, e.g. once their message arrives.
+func (s *TypingState) Stop(chatID, userID int64) {
+	delete(s.chats[chatID], userID)
+}
+
+// Prune drops indicators that expired before now.
+func (s *TypingState) Prune(now time.Time) {
+	for chatID, users := range s.chats {
+		for userID, e := range users {
+			if now.After(e.expires) {
+				delete(users, userID)
+			}
+		}
+		if len(users) == 0 {
+			delete(s.chats, chatID)
+		}
+	}
+}
+
+// Active reports whether anyone is typing in the chat.
+func (s TypingState) Active(chatID int64, now time.Time) bool {
+	for _, e := range s.chats[chatID] {
+		if now.Before(e.expires) {
+			return true
+		}
+	}
+	return false
+}
+
+// Describe returns e.g. "Alice is typing…", "Alice and Bob are typing…"
+// or "" when nobody is.
+func (s TypingState) Describe(chatID int64, now time.Time) string {
+	var active []typingEntry
+	for _, e := range s.chats[chatID] {
+		if now.Before(e.expires) {
+			active = append(active, e)
+		}
+	}
+	sort.Slice(active, func(i, j int) bool { return active[i].name < active[j].name })
+	switch len(active) {
+	case 0:
+		return ""
+	case 1:
+		name := active[0].name
+		if name == "" {
+			name = "Someone"
+		}
+		return fmt.Sprintf("%s is %s…", name, active[0].action)
+	case 2:
+		if active[0].name != "" && active[1].name != "" {
+			return fmt.Sprintf("%s and %s are typing…", active[0].name, active[1].name)
+		}
+	}
+	return fmt.Sprintf("%d people are typing…", len(active))
+}
@@ -0,0 +1,63 @@
+package ui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/paramon-tech/tgtui/internal/ui/keymap"
+)
+
+// renderHelp lists the key bindings by mode, in as many columns as it
+// takes to fit the height.
+func renderHelp(keys *keymap.Keymap, width, height int) string {
+	title := lipgloss.NewStyle().Bold(true).Foreground(ColorPrimary)
+	keyStyle := lipgloss.NewStyle().Bold(true)
+
+	var blocks []string
+	for _, s := range keys.Help() {
+		keyWidth := 0
+		for _, b := range s.Bindings {
+			keyWidth = max(keyWidth, lipgloss.Width(strings.Join(b.Keys, " / ")))
+		}
+		lines := []string{title.Render(s.Mode.Title())}
+		for _, b := range s.Bindings {
+			k := keyStyle.Width(keyWidth + 2).Render(strings.Join(b.Keys, " / "))
+			lines = append(lines, k+b.Desc)
+		}
+		blocks = append(blocks, strings.Join(lines, "\n"))
+	}
+
+	// Border, padding and the footer take 6 lines.
+	room := max(height-6, 1)
+	var columns []string
+	var column []string
+	used := 0
+	for _, block := range blocks {
+		h := lipgloss.Height(block)
+		if used > 0 && used+1+h > room {
+			columns = append(columns, strings.Join(column, "\n\n"))
+			column, used = nil, 0
+		}
+		if used > 0 {
+			used++
+		}
+		column = append(column, block)
+		used += h
+	}
+	if len(column) > 0 {
+		columns = append(columns, strings.Join(column, "\n\n"))
+	}
+	for i := range columns[:max(len(columns)-1, 0)] {
+		columns[i] = lipgloss.NewStyle().PaddingRight(4).Render(columns[i])
+	}
+
+	// Too narrow a terminal cuts columns off rather than the border.
+	body := lipgloss.NewStyle().MaxWidth(max(width-6, 1)).
+		Render(lipgloss.JoinHorizontal(lipgloss.Top, columns...))
+	footer := StyleMuted.Render("Any key closes this help. Rebind keys in the [keys] tables of the config file.")
+	return lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(ColorMuted).
+		Padding(1, 2).
+		Render(body + "\n\n" + footer)
+}
@@ -0,0 +1,448 @@
+// Package keymap binds keys to named actions, per input mode. Bindings
+// come from a preset and can be changed from the config file; a binding
+// may be a sequence of keys, such as "g g".
+package keymap
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Mode is an input mode; each has its own bindings.
+type Mode string
+
+const (
+	ModeGlobal  Mode = "global" // every screen and mode, unless rebound there
+	ModeNormal  Mode = "normal"
+	ModeInsert  Mode = "insert"
+	ModeVisual  Mode = "visual"
+	ModeSearch  Mode = "search"
+	ModeForward Mode = "forward" // picking the chat to forward to
+)
+
+// Modes lists the modes in help order.
+var Modes = []Mode{ModeGlobal, ModeNormal, ModeInsert, ModeVisual, ModeSearch, ModeForward}
+
+// Title is the mode's name in the help, with its status bar label.
+func (m Mode) Title() string {
+	switch m {
+	case ModeNormal:
+		return "Normal (NOR)"
+	case ModeInsert:
+		return "Insert (INS)"
+	case ModeVisual:
+		return "Visual (VIS)"
+	case ModeSearch:
+		return "Search (SRH)"
+	case ModeForward:
+		return "Forward (FWD)"
+	default:
+		return "Everywhere"
+	}
+}
+
+// Action is something a key can be bound to. What it does depends on the
+// mode and on the focused pane.
+type Action string
+
+const (
+	Quit           Action = "quit"
+	ToggleFocus    Action = "toggle_focus"
+	SwitchAccount  Action = "switch_account"
+	Help           Action = "help"
+	Up             Action = "up"
+	Down           Action = "down"
+	PageUp         Action = "page_up"
+	PageDown       Action = "page_down"
+	Top            Action = "top"
+	Bottom         Action = "bottom"
+	Open           Action = "open"
+	Back           Action = "back"
+	PrevFolder     Action = "prev_folder"
+	NextFolder     Action = "next_folder"
+	Archive        Action = "archive"
+	Compose        Action = "insert"
+	Edit           Action = "edit"
+	Reply          Action = "reply"
+	JumpToParent   Action = "jump_to_parent"
+	Select         Action = "visual"
+	Search         Action = "search"
+	GlobalSearch   Action = "global_search"
+	GoToMessage    Action = "go_to_message"
+	Delete         Action = "delete"
+	Download       Action = "download"
+	CancelDownload Action = "cancel_download"
+	Send           Action = "send"
+	CycleAttach    Action = "cycle_attach"
+	ToggleSelect   Action = "toggle_select"
+	Forward        Action = "forward"
+	Submit         Action = "submit"
+)
+
+type actionInfo struct {
+	action Action
+	desc   string
+}
+
+// actions lists the actions of each mode, in help order.
+var actions = map[Mode][]actionInfo{
+	ModeGlobal: {
+		{Quit, "Quit"},
+		{ToggleFocus, "Switch between chat list and chat"},
+		{SwitchAccount, "Switch account"},
+	},
+	ModeNormal: {
+		{Up, "Previous chat or message"},
+		{Down, "Next chat or message"},
+		{PageUp, "Page up (loads older history)"},
+		{PageDown, "Page down"},
+		{Top, "First chat or message"},
+		{Bottom, "Last chat or message"},
+		{Open, "Open chat / expand message"},
+		{Back, "Collapse / leave results / back to topics"},
+		{PrevFolder, "Previous folder tab"},
+		{NextFolder, "Next folder tab"},
+		{Archive, "Archive or unarchive chat"},
+		{Compose, "Write a message (insert mode)"},
+		{Edit, "Edit your message"},
+		{Reply, "Reply to the message"},
+		{JumpToParent, "Jump to the message replied to"},
+		{Select, "Select messages (visual mode)"},
+		{Search, "Search messages in the chat"},
+		{GlobalSearch, "Search messages in all chats"},
+		{GoToMessage, "Show the search result in the chat"},
+		{Delete, "Delete the message"},
+		{Download, "Download media"},
+		{CancelDownload, "Cancel the download"},
+		{Help, "Show this help"},
+	},
+	ModeInsert: {
+		{Send, "Send the message"},
+		{Back, "Back to normal mode"},
+		{PageUp, "Back to normal mode"},
+		{CycleAttach, "Change how an attached file is sent"},
+	},
+	ModeVisual: {
+		{Up, "Previous message"},
+		{Down, "Next message"},
+		{PageUp, "Page up"},
+		{PageDown, "Page down"},
+		{ToggleSelect, "Select or unselect the message"},
+		{Forward, "Forward the selected messages"},
+		{Delete, "Delete the selected messages"},
+		{Back, "Leave visual mode"},
+	},
+	ModeSearch: {
+		{Submit, "Search"},
+		{Back, "Cancel"},
+	},
+	ModeForward: {
+		{Up, "Previous chat"},
+		{Down, "Next chat"},
+		{PrevFolder, "Previous folder tab"},
+		{NextFolder, "Next folder tab"},
+		{Open, "Forward to the chat"},
+		{Back, "Cancel forwarding"},
+	},
+}
+
+func hasAction(mode Mode, action Action) bool {
+	for _, a := range actions[mode] {
+		if a.action == action {
+			return true
+		}
+	}
+	return false
+}
+
+// Keymap holds the resolved bindings. It is not changed once built, so
+// models share one.
+type Keymap struct {
+	tables map[Mode]*table
+	keys   map[Mode]map[Action][]string // bound sequences, for help and hints
+}
+
+// table maps key sequences, joined by keySep, to actions.
+type table struct {
+	bound  map[string]Action
+	prefix map[string]bool // proper prefixes of bound sequences
+}
+
+const keySep = "\x00"
+
+// Default returns the keymap of the default preset.
+func Default() *Keymap {
+	k, err := New("", nil)
+	if err != nil {
+		panic(err)
+	}
+	return k
+}
+
+// New builds the keymap of a preset ("" for helix) with user bindings,
+// keyed mode → action → keys, as in the config file's [keys] tables.
+// Binding an action replaces its preset keys in that mode; a preset
+// binding taking a key the user bound elsewhere is dropped.
+func New(preset string, user map[string]map[string][]string) (*Keymap, error) {
+	if preset == "" {
+		preset = "helix"
+	}
+	base, ok := presets[preset]
+	if !ok {
+		return nil, fmt.Errorf("keymap: unknown preset %q", preset)
+	}
+
+	custom := make(map[Mode]map[Action][]string)
+	for _, modeName := range sortedKeys(user) {
+		mode := Mode(modeName)
+		if _, ok := actions[mode]; !ok {
+			return nil, fmt.Errorf("keys.%s: unknown mode, want one of %s", modeName, modeList())
+		}
+		custom[mode] = make(map[Action][]string)
+		for _, actionName := range sortedKeys(user[modeName]) {
+			action := Action(actionName)
+			if !hasAction(mode, action) {
+				return nil, fmt.Errorf("keys.%s.%s: unknown action in %s mode", modeName, actionName, modeName)
+			}
+			seqs := make([]string, 0, len(user[modeName][actionName]))
+			for _, s := range user[modeName][actionName] {
+				seq, err := parseSequence(s)
+				if err != nil {
+					return nil, fmt.Errorf("keys.%s.%s: %w", modeName, actionName, err)
+				}
+				seqs = append(seqs, seq)
+			}
+			custom[mode][action] = seqs
+		}
+	}
+
+	k := &Keymap{tables: make(map[Mode]*table), keys: make(map[Mode]map[Action][]string)}
+	for _, mode := range Modes {
+		t := &table{bound: make(map[string]Action), prefix: make(map[string]bool)}
+		k.tables[mode] = t
+		k.keys[mode] = make(map[Action][]string)
+
+		// User bindings go first and must not clash; preset ones fill in
+		// around them.
+		layers := []Mode{mode}
+		if mode != ModeGlobal {
+			layers = append(layers, ModeGlobal)
+		}
+		for _, layer := range layers {
+			for _, a := range actions[layer] {
+				for _, seq := range custom[layer][a.action] {
+					other, ok := t.add(seq, a.action)
+					if other == a.action {
+						continue
+					}
+					if !ok {
+						return nil, fmt.Errorf("keys.%s.%s: %q conflicts with the binding of %s", layer, a.action, display(seq), other)
+					}
+					if layer == mode {
+						k.keys[mode][a.action] = append(k.keys[mode][a.action], seq)
+					}
+				}
+			}
+		}
+		for _, layer := range layers {
+			for _, a := range actions[layer] {
+				if _, ok := custom[layer][a.action]; ok {
+					continue
+				}
+				for _, key := range base[layer][a.action] {
+					seq := mustParse(key)
+					if _, ok := t.add(seq, a.action); ok && layer == mode {
+						k.keys[mode][a.action] = append(k.keys[mode][a.action], seq)
+					}
+				}
+			}
+		}
+	}
+	return k, nil
+}
+
+// add binds seq to action unless seq, one of its prefixes, or a sequence
+// it is a prefix of is bound already; the action bound then is returned.
+// Binding the same sequence twice to an action returns that action.
+func (t *table) add(seq string, action Action) (Action, bool) {
+	keys := strings.Split(seq, keySep)
+	for i := 1; i <= len(keys); i++ {
+		if other, ok := t.bound[strings.Join(keys[:i], keySep)]; ok {
+			return other, false
+		}
+	}
+	if t.prefix[seq] {
+		for bound, other := range t.bound {
+			if strings.HasPrefix(bound, seq+keySep) {
+				return other, false
+			}
+		}
+	}
+	t.bound[seq] = action
+	for i := 1; i < len(keys); i++ {
+		t.prefix[strings.Join(keys[:i], keySep)] = true
+	}
+	return "", true
+}
+
+// Lookup returns the action bound to a single key in mode.
+func (k *Keymap) Lookup(mode Mode, key string) (Action, bool) {
+	a, ok := k.tables[mode].bound[key]
+	return a, ok
+}
+
+// Sequence holds the keys typed so far towards a multi-key binding.
+type Sequence []tea.KeyMsg
+
+// Feed resolves key, typed after the keys in seq, in mode. A completed
+// binding returns its action and a key continuing one returns the longer
+// sequence to feed the next key to. When seq cannot complete a binding
+// any more its keys are returned in flushed, to be handled as if unbound,
+// and key is resolved on its own; with no action and nothing pending key
+// itself is unbound.
+func (k *Keymap) Feed(mode Mode, seq Sequence, key tea.KeyMsg) (next Sequence, action Action, flushed []tea.KeyMsg) {
+	t := k.tables[mode]
+	typed := make([]string, 0, len(seq)+1)
+	for _, msg := range seq {
+		typed = append(typed, msg.String())
+	}
+	typed = append(typed, key.String())
+	joined := strings.Join(typed, keySep)
+
+	if a, ok := t.bound[joined]; ok {
+		return nil, a, nil
+	}
+	if t.prefix[joined] {
+		return append(slices.Clip(seq), key), "", nil
+	}
+	if len(seq) == 0 {
+		return nil, "", nil
+	}
+	next, action, _ = k.Feed(mode, nil, key)
+	return next, action, seq
+}
+
+// Keys returns the key sequences bound to action in mode, as written in
+// the config file.
+func (k *Keymap) Keys(mode Mode, action Action) []string {
+	var out []string
+	for _, seq := range k.keys[mode][action] {
+		out = append(out, display(seq))
+	}
+	return out
+}
+
+// Hint names the first key bound to action in mode, for prompts such as
+// "x to cancel".
+func (k *Keymap) Hint(mode Mode, action Action) string {
+	if keys := k.Keys(mode, action); len(keys) > 0 {
+		return keys[0]
+	}
+	return string(action)
+}
+
+// Section is one mode's part of the help.
+type Section struct {
+	Mode     Mode
+	Bindings []Binding
+}
+
+// Binding is a line of the help.
+type Binding struct {
+	Action Action
+	Keys   []string
+	Desc   string
+}
+
+// Help lists the bound actions of every mode.
+func (k *Keymap) Help() []Section {
+	var sections []Section
+	for _, mode := range Modes {
+		s := Section{Mode: mode}
+		for _, a := range actions[mode] {
+			if keys := k.Keys(mode, a.action); len(keys) > 0 {
+				s.Bindings = append(s.Bindings, Binding{Action: a.action, Keys: keys, Desc: a.desc})
+			}
+		}
+		if len(s.Bindings) > 0 {
+			sections = append(sections, s)
+		}
+	}
+	return sections
+}
+
+// namedKeys are the keys, other than single characters, that may follow
+// the ctrl+, alt+ and shift+ modifiers.
+var namedKeys = []string{
+	"enter", "esc", "tab", "backspace", "delete", "insert", "space",
+	"up", "down", "left", "right", "home", "end", "pgup", "pgdown",
+	"f1", "f2", "f3", "f4", "f5", "f6", "f7", "f8", "f9", "f10", "f11", "f12",
+}
+
+// parseSequence turns keys as written in the config file, separated by
+// spaces ("g g", "ctrl+x ctrl+c"), into a table key. A space is written
+// "space".
+func parseSequence(s string) (string, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return "", fmt.Errorf("empty key")
+	}
+	for i, f := range fields {
+		mods, name := "", f
+		for {
+			mod, rest, ok := strings.Cut(name, "+")
+			if !ok || rest == "" || !slices.Contains([]string{"ctrl", "alt", "shift"}, mod) {
+				break
+			}
+			mods, name = mods+mod+"+", rest
+		}
+		if utf8.RuneCountInString(name) != 1 && !slices.Contains(namedKeys, name) {
+			return "", fmt.Errorf("unknown key %q", f)
+		}
+		if name == "space" {
+			fields[i] = mods + " "
+		}
+	}
+	return strings.Join(fields, keySep), nil
+}
+
+func mustParse(s string) string {
+	seq, err := parseSequence(s)
+	if err != nil {
+		panic(err)
+	}
+	return seq
+}
+
+// display writes a table key back as in the config file.
+func display(seq string) string {
+	keys := strings.Split(seq, keySep)
+	for i, key := range keys {
+		if strings.HasSuffix(key, " ") {
+			keys[i] = strings.TrimSuffix(key, " ") + "space"
+		}
+	}
+	return strings.Join(keys, " ")
+}
+
+func modeList() string {
+	names := make([]string, len(Modes))
+	for i, m := range Modes {
+		names[i] = string(m)
+	}
+	return strings.Join(names, ", ")
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,122 @@
+package keymap
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/paramon-tech/tgtui/internal/config"
+)
+
+func key(s string) tea.KeyMsg {
+	switch s {
+	case "esc":
+		return tea.KeyMsg{Type: tea.KeyEscape}
+	case " ":
+		return tea.KeyMsg{Type: tea.KeySpace, Runes: []rune{' '}}
+	case "ctrl+x":
+		return tea.KeyMsg{Type: tea.KeyCtrlX}
+	case "ctrl+c":
+		return tea.KeyMsg{Type: tea.KeyCtrlC}
+	}
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)}
+}
+
+func TestPresets(t *testing.T) {
+	for _, name := range config.KeymapPresets {
+		k, err := New(name, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		// Presets must not shadow their own bindings.
+		for mode, acts := range presets[name] {
+			for action, keys := range acts {
+				if got := k.Keys(mode, action); !slices.Equal(got, keys) {
+					t.Errorf("%s: %s.%s bound to %v, want %v", name, mode, action, got, keys)
+				}
+			}
+		}
+	}
+}
+
+func TestFeedSequence(t *testing.T) {
+	k := Default()
+
+	seq, action, flushed := k.Feed(ModeNormal, nil, key("g"))
+	if len(seq) != 1 || action != "" || flushed != nil {
+		t.Fatalf("g should start a sequence, got %v %q %v", seq, action, flushed)
+	}
+	seq, action, _ = k.Feed(ModeNormal, seq, key("g"))
+	if seq != nil || action != Top {
+		t.Fatalf("g g should go to the top, got %v %q", seq, action)
+	}
+
+	// A key that cannot continue the sequence flushes it and counts on its own.
+	seq, _, _ = k.Feed(ModeNormal, nil, key("g"))
+	seq, action, flushed = k.Feed(ModeNormal, seq, key("x"))
+	if seq != nil || action != CancelDownload || len(flushed) != 1 || flushed[0].String() != "g" {
+		t.Errorf("g x: got %v %q %v", seq, action, flushed)
+	}
+
+	// Global bindings apply in every mode.
+	if _, action, _ := k.Feed(ModeInsert, nil, key("ctrl+c")); action != Quit {
+		t.Errorf("ctrl+c in insert mode: got %q", action)
+	}
+	if _, action, _ := k.Feed(ModeInsert, nil, key("j")); action != "" {
+		t.Errorf("j should be unbound in insert mode, got %q", action)
+	}
+	if action, _ := k.Lookup(ModeVisual, " "); action != ToggleSelect {
+		t.Errorf("space in visual mode: got %q", action)
+	}
+}
+
+func TestUserBindings(t *testing.T) {
+	k, err := New("vim", map[string]map[string][]string{
+		"normal": {"reply": {"x"}, "top": {"space space"}},
+		"insert": {"back": {"j k"}},
+		"global": {"quit": {"ctrl+x ctrl+c"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if action, _ := k.Lookup(ModeNormal, "x"); action != Reply {
+		t.Errorf("x: got %q, want reply", action)
+	}
+	if keys := k.Keys(ModeNormal, CancelDownload); len(keys) != 0 {
+		t.Errorf("cancel_download should have lost x, got %v", keys)
+	}
+	if keys := k.Keys(ModeNormal, Top); !slices.Equal(keys, []string{"space space"}) {
+		t.Errorf("top: got %v", keys)
+	}
+	if keys := k.Keys(ModeNormal, Bottom); !slices.Equal(keys, []string{"G"}) {
+		t.Errorf("vim bottom: got %v", keys)
+	}
+
+	seq, _, _ := k.Feed(ModeInsert, nil, key("j"))
+	if _, action, _ := k.Feed(ModeInsert, seq, key("k")); action != Back {
+		t.Errorf("j k in insert mode: got %q", action)
+	}
+	seq, _, _ = k.Feed(ModeNormal, nil, key("ctrl+x"))
+	if _, action, _ := k.Feed(ModeNormal, seq, key("ctrl+c")); action != Quit {
+		t.Errorf("ctrl+x ctrl+c: got %q", action)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	for _, tc := range []struct {
+		keys map[string]map[string][]string
+		want string
+	}{
+		{map[string]map[string][]string{"command": {"quit": {"q"}}}, "keys.command: unknown mode"},
+		{map[string]map[string][]string{"insert": {"reply": {"r"}}}, "keys.insert.reply: unknown action"},
+		{map[string]map[string][]string{"normal": {"reply": {"Enter"}}}, `keys.normal.reply: unknown key "Enter"`},
+		{map[string]map[string][]string{"normal": {"reply": {"r"}, "edit": {"r"}}}, `keys.normal.reply: "r" conflicts with the binding of edit`},
+		{map[string]map[string][]string{"normal": {"top": {"g"}, "bottom": {"g e"}}}, `keys.normal.bottom: "g e" conflicts with the binding of top`},
+	} {
+		_, err := New("", tc.keys)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%v: got error %v, want %q", tc.keys, err, tc.want)
+		}
+	}
+}
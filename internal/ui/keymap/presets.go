@@ -0,0 +1,129 @@
+package keymap
+
+// bindings maps mode → action → keys, in config file notation.
+type bindings map[Mode]map[Action][]string
+
+// helix is the default preset, modal in the way of the helix editor.
+var helix = bindings{
+	ModeGlobal: {
+		Quit:          {"ctrl+c"},
+		ToggleFocus:   {"tab"},
+		SwitchAccount: {"ctrl+a"},
+	},
+	ModeNormal: {
+		Up:             {"k", "up"},
+		Down:           {"j", "down"},
+		PageUp:         {"pgup"},
+		PageDown:       {"pgdown"},
+		Top:            {"g g"},
+		Bottom:         {"g e"},
+		Open:           {"enter"},
+		Back:           {"esc"},
+		PrevFolder:     {"["},
+		NextFolder:     {"]"},
+		Archive:        {"a"},
+		Compose:        {"i"},
+		Edit:           {"e"},
+		Reply:          {"r"},
+		JumpToParent:   {"p"},
+		Select:         {"v"},
+		Search:         {"/"},
+		GlobalSearch:   {"space /"},
+		GoToMessage:    {"g m"},
+		Delete:         {"d"},
+		Download:       {"D"},
+		CancelDownload: {"x"},
+		Help:           {"?"},
+	},
+	ModeInsert: {
+		Send:        {"enter"},
+		Back:        {"esc"},
+		PageUp:      {"pgup"},
+		CycleAttach: {"ctrl+t"},
+	},
+	ModeVisual: {
+		Up:           {"k", "up"},
+		Down:         {"j", "down"},
+		PageUp:       {"pgup"},
+		PageDown:     {"pgdown"},
+		ToggleSelect: {"space"},
+		Forward:      {"f"},
+		Delete:       {"d"},
+		Back:         {"esc"},
+	},
+	ModeSearch: {
+		Submit: {"enter"},
+		Back:   {"esc"},
+	},
+	ModeForward: {
+		Up:         {"k", "up"},
+		Down:       {"j", "down"},
+		PrevFolder: {"["},
+		NextFolder: {"]"},
+		Open:       {"enter"},
+		Back:       {"esc"},
+	},
+}
+
+var presets = map[string]bindings{
+	"helix": helix,
+	"vim": helix.with(bindings{
+		ModeNormal: {
+			PageUp:   {"pgup", "ctrl+b", "ctrl+u"},
+			PageDown: {"pgdown", "ctrl+f", "ctrl+d"},
+			Top:      {"g g"},
+			Bottom:   {"G"},
+		},
+		ModeVisual: {
+			PageUp:   {"pgup", "ctrl+b", "ctrl+u"},
+			PageDown: {"pgdown", "ctrl+f", "ctrl+d"},
+			Delete:   {"d", "x"},
+		},
+	}),
+	"emacs": helix.with(bindings{
+		ModeGlobal: {
+			Quit: {"ctrl+c", "ctrl+x ctrl+c"},
+		},
+		ModeNormal: {
+			Up:       {"k", "up", "ctrl+p"},
+			Down:     {"j", "down", "ctrl+n"},
+			PageUp:   {"pgup", "alt+v"},
+			PageDown: {"pgdown", "ctrl+v"},
+			Top:      {"alt+<"},
+			Bottom:   {"alt+>"},
+			Back:     {"esc", "ctrl+g"},
+			Search:   {"/", "ctrl+s"},
+		},
+		ModeInsert: {
+			Back: {"esc", "ctrl+g"},
+		},
+		ModeVisual: {
+			Up:   {"up", "ctrl+p"},
+			Down: {"down", "ctrl+n"},
+			Back: {"esc", "ctrl+g"},
+		},
+		ModeSearch: {
+			Back: {"esc", "ctrl+g"},
+		},
+		ModeForward: {
+			Up:   {"up", "ctrl+p"},
+			Down: {"down", "ctrl+n"},
+			Back: {"esc", "ctrl+g"},
+		},
+	}),
+}
+
+// with returns b with the keys of the actions in changes replaced.
+func (b bindings) with(changes bindings) bindings {
+	out := make(bindings, len(b))
+	for mode, acts := range b {
+		out[mode] = make(map[Action][]string, len(acts))
+		for a, keys := range acts {
+			out[mode][a] = keys
+		}
+		for a, keys := range changes[mode] {
+			out[mode][a] = keys
+		}
+	}
+	return out
+}
@@ -6,10 +6,13 @@ import "github.com/paramon-tech/tgtui/internal/ui/common"
 type (
 	AuthorizedMsg         = common.AuthorizedMsg
 	NeedAuthMsg           = common.NeedAuthMsg
+	NeedPassphraseMsg     = common.NeedPassphraseMsg
+	SessionUnlockedMsg    = common.SessionUnlockedMsg
 	DialogsLoadedMsg      = common.DialogsLoadedMsg
 	HistoryLoadedMsg      = common.HistoryLoadedMsg
 	NewMessageMsg         = common.NewMessageMsg
 	ChatSelectedMsg       = common.ChatSelectedMsg
+	OpenMessageMsg        = common.OpenMessageMsg
 	FatalErrorMsg         = common.FatalErrorMsg
 	StatusMsg             = common.StatusMsg
 	MessageSendErrorMsg   = common.MessageSendErrorMsg
@@ -21,4 +24,11 @@ type (
 	ForwardErrorMsg       = common.ForwardErrorMsg
 	ForwardRequestMsg     = common.ForwardRequestMsg
 	ForwardDestSelectedMsg = common.ForwardDestSelectedMsg
+	TypingMsg             = common.TypingMsg
+	ConnectionStateMsg    = common.ConnectionStateMsg
+	AccountMsg            = common.AccountMsg
+	ReloadConfigMsg       = common.ReloadConfigMsg
+	ConfigLoadedMsg       = common.ConfigLoadedMsg
+	ActionMsg             = common.ActionMsg
+	SwitchAccountMsg      = common.SwitchAccountMsg
 )
@@ -0,0 +1,285 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/paramon-tech/tgtui/internal/config"
+	"github.com/paramon-tech/tgtui/internal/format"
+	"github.com/paramon-tech/tgtui/internal/telegram"
+	"github.com/paramon-tech/tgtui/internal/theme"
+	"github.com/paramon-tech/tgtui/internal/ui/common"
+	"github.com/paramon-tech/tgtui/internal/ui/keymap"
+)
+
+// Account is one Telegram account shown by Root.
+type Account struct {
+	Name    string
+	Backend telegram.Backend
+}
+
+// Root runs an App per account, all connected at once, and shows the
+// active one. Messages pushed by a client arrive wrapped in AccountMsg
+// and commands are wrapped on their way out, so every result reaches the
+// App that asked for it even after switching accounts.
+type Root struct {
+	names  []string
+	apps   []App
+	active int
+	// Settings, and how to read them again on ReloadConfigMsg
+	cfg    *config.Config
+	reload func() (*config.Config, error)
+	keys   *keymap.Keymap
+	// Account switcher
+	switching bool
+	cursor    int
+
+	width, height int
+}
+
+func NewRoot(accounts []Account, active string, cfg *config.Config, reload func() (*config.Config, error)) Root {
+	r := Root{cfg: cfg, reload: reload, keys: keymap.Default()}
+	r.applyGlobalConfig()
+	for i, acc := range accounts {
+		r.names = append(r.names, acc.Name)
+		r.apps = append(r.apps, NewApp(acc.Backend).ApplyConfig(cfg))
+		if acc.Name == active {
+			r.active = i
+		}
+	}
+	return r
+}
+
+func (r Root) Init() tea.Cmd {
+	var cmds []tea.Cmd
+	for i, app := range r.apps {
+		cmds = append(cmds, wrapCmd(r.names[i], app.Init()))
+	}
+	return tea.Batch(cmds...)
+}
+
+func (r Root) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case ReloadConfigMsg:
+		return r, r.reloadConfig()
+
+	case ConfigLoadedMsg:
+		r.cfg = msg.Config
+		r.applyGlobalConfig()
+		for i := range r.apps {
+			r.apps[i] = r.apps[i].ApplyConfig(r.cfg)
+		}
+		if len(r.apps) == 0 {
+			return r, nil
+		}
+		return r, r.updateApp(r.active, StatusMsg{Text: "Configuration reloaded"})
+
+	case AccountMsg:
+		switch msg.Msg.(type) {
+		case ReloadConfigMsg:
+			return r, r.reloadConfig()
+		case SwitchAccountMsg:
+			if len(r.apps) > 1 {
+				r.switching = true
+				r.cursor = r.active
+			}
+			return r, nil
+		}
+		i := r.indexOf(msg.Account)
+		if i < 0 {
+			return r, nil
+		}
+		cmd := r.updateApp(i, msg.Msg)
+		if _, fatal := msg.Msg.(FatalErrorMsg); fatal && i != r.active {
+			// A background account failing must not quit the others;
+			// its error shows once switched to.
+			return r, nil
+		}
+		return r, cmd
+
+	case tea.WindowSizeMsg:
+		r.width, r.height = msg.Width, msg.Height
+		size := tea.WindowSizeMsg{Width: msg.Width, Height: msg.Height - r.barHeight()}
+		var cmds []tea.Cmd
+		for i := range r.apps {
+			cmds = append(cmds, r.updateApp(i, size))
+		}
+		return r, tea.Batch(cmds...)
+
+	case tea.KeyMsg:
+		if r.switching {
+			return r.handleSwitcherKey(msg)
+		}
+	}
+
+	if len(r.apps) == 0 {
+		return r, nil
+	}
+	return r, r.updateApp(r.active, msg)
+}
+
+// reloadConfig reads the settings again. Credentials and the proxy only
+// take effect after a restart; everything else applies right away.
+func (r Root) reloadConfig() tea.Cmd {
+	if r.reload == nil {
+		return nil
+	}
+	reload := r.reload
+	return func() tea.Msg {
+		cfg, err := reload()
+		if err != nil {
+			return StatusMsg{Text: "Reload failed: " + err.Error()}
+		}
+		return ConfigLoadedMsg{Config: cfg}
+	}
+}
+
+// applyGlobalConfig applies the settings shared by all accounts.
+func (r *Root) applyGlobalConfig() {
+	if r.cfg.ImageProtocol != "none" {
+		format.UseImageProtocol(r.cfg.ImageProtocol)
+	}
+	if keys, err := keymap.New(r.cfg.Keymap, r.cfg.Keys); err == nil {
+		r.keys = keys
+	}
+	if t, err := theme.Load(r.cfg.Theme, r.cfg.ThemeDir()); err == nil {
+		applyTheme(t)
+	}
+}
+
+func (r *Root) updateApp(i int, msg tea.Msg) tea.Cmd {
+	model, cmd := r.apps[i].Update(msg)
+	r.apps[i] = model.(App)
+	return wrapCmd(r.names[i], cmd)
+}
+
+// handleSwitcherKey moves through the switcher with the normal mode
+// bindings.
+func (r Root) handleSwitcherKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	action, _ := r.keys.Lookup(keymap.ModeNormal, msg.String())
+	switch action {
+	case keymap.Quit:
+		return r, tea.Quit
+	case keymap.Back, keymap.SwitchAccount:
+		r.switching = false
+	case keymap.Down:
+		if r.cursor < len(r.apps)-1 {
+			r.cursor++
+		}
+	case keymap.Up:
+		if r.cursor > 0 {
+			r.cursor--
+		}
+	case keymap.Open:
+		r.active = r.cursor
+		r.switching = false
+	}
+	return r, nil
+}
+
+func (r Root) indexOf(name string) int {
+	for i, n := range r.names {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// barHeight is the height of the account bar, shown only with several
+// accounts.
+func (r Root) barHeight() int {
+	if len(r.apps) > 1 {
+		return 1
+	}
+	return 0
+}
+
+func (r Root) View() string {
+	if len(r.apps) == 0 {
+		return ""
+	}
+	if r.switching {
+		return lipgloss.Place(r.width, r.height, lipgloss.Center, lipgloss.Center, r.renderSwitcher())
+	}
+	view := r.apps[r.active].View()
+	if r.barHeight() == 0 {
+		return view
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, r.renderBar(), view)
+}
+
+// renderBar lists the accounts with their unread badges.
+func (r Root) renderBar() string {
+	parts := make([]string, len(r.apps))
+	for i, app := range r.apps {
+		style := StyleMuted
+		if i == r.active {
+			style = lipgloss.NewStyle().Bold(true).Foreground(ColorPrimary)
+		}
+		parts[i] = style.Render(r.names[i])
+		if n := app.UnreadCount(); n > 0 {
+			parts[i] += common.StyleUnread.Render(fmt.Sprintf(" %d", n))
+		}
+	}
+	bar := strings.Join(parts, StyleMuted.Render(" │ "))
+	hint := StyleMuted.Render("  " + r.keys.Hint(keymap.ModeGlobal, keymap.SwitchAccount) + ": switch account")
+	return lipgloss.NewStyle().Width(r.width).Padding(0, 1).Render(bar + hint)
+}
+
+func (r Root) renderSwitcher() string {
+	var b strings.Builder
+	b.WriteString(lipgloss.NewStyle().Bold(true).Render("Switch account"))
+	b.WriteString("\n\n")
+	for i, app := range r.apps {
+		prefix := "  "
+		name := r.names[i]
+		if i == r.cursor {
+			prefix = "› "
+			name = lipgloss.NewStyle().Bold(true).Foreground(ColorPrimary).Render(name)
+		}
+		line := prefix + name
+		if n := app.UnreadCount(); n > 0 {
+			line += common.StyleUnread.Render(fmt.Sprintf(" (%d)", n))
+		}
+		if i == r.active {
+			line += StyleMuted.Render("  current")
+		}
+		b.WriteString(line + "\n")
+	}
+	hint := fmt.Sprintf("%s/%s: move  %s: switch  %s: cancel",
+		r.keys.Hint(keymap.ModeNormal, keymap.Down), r.keys.Hint(keymap.ModeNormal, keymap.Up),
+		r.keys.Hint(keymap.ModeNormal, keymap.Open), r.keys.Hint(keymap.ModeNormal, keymap.Back))
+	b.WriteString("\n" + StyleMuted.Render(hint))
+	return lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(ColorMuted).
+		Padding(1, 2).
+		Render(b.String())
+}
+
+// wrapCmd tags the result of cmd with the account it was issued for.
+// Batches are wrapped command by command, and quitting passes through.
+func wrapCmd(account string, cmd tea.Cmd) tea.Cmd {
+	if cmd == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		switch msg := cmd().(type) {
+		case nil:
+			return nil
+		case tea.QuitMsg:
+			return msg
+		case tea.BatchMsg:
+			wrapped := make(tea.BatchMsg, len(msg))
+			for i, c := range msg {
+				wrapped[i] = wrapCmd(account, c)
+			}
+			return wrapped
+		default:
+			return AccountMsg{Account: account, Msg: msg}
+		}
+	}
+}
@@ -0,0 +1,362 @@
+// Package search is the global search pane: a query prompt and the
+// messages found across all chats, shown in place of the chat view.
+package search
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/paramon-tech/tgtui/internal/config"
+	"github.com/paramon-tech/tgtui/internal/format"
+	"github.com/paramon-tech/tgtui/internal/telegram"
+	"github.com/paramon-tech/tgtui/internal/ui/common"
+	"github.com/paramon-tech/tgtui/internal/ui/keymap"
+)
+
+// loadMoreThreshold is how close to the end of the results the cursor
+// gets before the next page is requested.
+const loadMoreThreshold = 10
+
+// resultMsg is a backend result tagged with the search it was requested
+// for. Submitting the same query again starts a new search, so the query
+// alone cannot tell a late page of the old search from one of the new.
+type resultMsg struct {
+	gen int
+	msg tea.Msg
+}
+
+type Model struct {
+	tg            telegram.Backend
+	keys          *keymap.Keymap
+	active        bool
+	editing       bool   // typing the query
+	input         string // query being typed
+	query         string // query the results are for
+	gen           int    // bumped on every submit
+	hits          []telegram.SearchHit
+	cursor        int
+	offset        int
+	searching     bool // first page in flight
+	next          telegram.GlobalSearchCursor
+	hasMore       bool
+	loadingMore   bool
+	width, height int
+	// Settings
+	timestampFormat string
+}
+
+func New(tg telegram.Backend) Model {
+	return Model{
+		tg:              tg,
+		keys:            keymap.Default(),
+		timestampFormat: "Mon 02/01/2006 15:04",
+	}
+}
+
+// ApplyConfig takes the settings that affect the results.
+func (m Model) ApplyConfig(cfg *config.Config) Model {
+	m.timestampFormat = cfg.TimestampFormat
+	return m
+}
+
+// SetKeymap sets the key bindings.
+func (m Model) SetKeymap(keys *keymap.Keymap) Model {
+	m.keys = keys
+	return m
+}
+
+// Open shows the pane with the prompt, holding the last query. Results
+// of the last search stay until a new one is submitted.
+func (m Model) Open() Model {
+	m.active = true
+	m.editing = true
+	m.input = m.query
+	return m
+}
+
+// Close hides the pane.
+func (m Model) Close() Model {
+	m.active = false
+	m.editing = false
+	return m
+}
+
+// Active reports whether the pane is shown.
+func (m Model) Active() bool {
+	return m.active
+}
+
+// Editing reports whether the query is being typed.
+func (m Model) Editing() bool {
+	return m.active && m.editing
+}
+
+// KeyMode is the keymap mode keys are resolved in.
+func (m Model) KeyMode() keymap.Mode {
+	if m.editing {
+		return keymap.ModeSearch
+	}
+	return keymap.ModeNormal
+}
+
+func (m Model) SetSize(w, h int) Model {
+	m.width = w
+	m.height = h
+	return m
+}
+
+func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case resultMsg:
+		if msg.gen != m.gen {
+			return m, nil
+		}
+		return m.Update(msg.msg)
+
+	case common.GlobalSearchResultMsg:
+		if msg.Query != m.query {
+			return m, nil
+		}
+		m.searching = false
+		m.hits = msg.Hits
+		m.next, m.hasMore = msg.Next, msg.HasMore
+		m.cursor, m.offset = 0, 0
+		status := fmt.Sprintf("Found %d result(s) for \"%s\"", len(msg.Hits), msg.Query)
+		if msg.HasMore {
+			status = fmt.Sprintf("Found more than %d results for \"%s\"", len(msg.Hits), msg.Query)
+		}
+		return m, tea.Batch(
+			func() tea.Msg { return common.StatusMsg{Text: status} },
+			m.maybeLoadMore(),
+		)
+
+	case common.GlobalSearchMoreMsg:
+		if msg.Query != m.query {
+			return m, nil
+		}
+		m.loadingMore = false
+		m.hits = append(m.hits, msg.Hits...)
+		m.next, m.hasMore = msg.Next, msg.HasMore
+		return m, m.maybeLoadMore()
+
+	case common.GlobalSearchErrorMsg:
+		if msg.Query != m.query {
+			return m, nil
+		}
+		m.searching = false
+		m.loadingMore = false
+		m.hasMore = false
+		return m, func() tea.Msg {
+			return common.StatusMsg{Text: "Search failed: " + msg.Err.Error()}
+		}
+
+	case tea.KeyMsg:
+		if !m.active {
+			return m, nil
+		}
+		action, _ := m.keys.Lookup(m.KeyMode(), msg.String())
+		return m.handleKey(action, msg)
+
+	case common.ActionMsg:
+		if !m.active {
+			return m, nil
+		}
+		return m.handleKey(msg.Action, msg.Key)
+	}
+	return m, nil
+}
+
+func (m Model) handleKey(action keymap.Action, msg tea.KeyMsg) (Model, tea.Cmd) {
+	if m.editing {
+		return m.handlePromptKey(action, msg)
+	}
+
+	switch action {
+	case keymap.Up:
+		m.moveCursor(-1)
+	case keymap.Down:
+		m.moveCursor(1)
+	case keymap.PageUp:
+		m.moveCursor(-m.visibleCount())
+	case keymap.PageDown:
+		m.moveCursor(m.visibleCount())
+	case keymap.Top:
+		m.moveCursor(-len(m.hits))
+	case keymap.Bottom:
+		m.moveCursor(len(m.hits))
+	case keymap.Search, keymap.GlobalSearch:
+		return m.Open(), nil
+	case keymap.Back:
+		return m.Close(), nil
+	case keymap.Open:
+		if m.cursor >= len(m.hits) {
+			return m, nil
+		}
+		hit := m.hits[m.cursor]
+		chat := hit.Chat
+		if chat.Forum {
+			chat.TopicID = hit.Message.TopicID
+			if chat.TopicID == 0 {
+				chat.TopicID = telegram.GeneralTopicID
+			}
+		}
+		m = m.Close()
+		return m, func() tea.Msg {
+			return common.OpenMessageMsg{Chat: chat, MessageID: hit.Message.ID}
+		}
+	}
+	return m, m.maybeLoadMore()
+}
+
+func (m Model) handlePromptKey(action keymap.Action, msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch action {
+	case keymap.Submit:
+		query := strings.TrimSpace(m.input)
+		if query == "" {
+			return m.Close(), nil
+		}
+		m.editing = false
+		m.query = query
+		m.hits = nil
+		m.cursor, m.offset = 0, 0
+		m.searching = true
+		m.hasMore = false
+		m.loadingMore = false
+		m.gen++
+		tg, gen := m.tg, m.gen
+		return m, func() tea.Msg {
+			return resultMsg{gen: gen, msg: tg.SearchGlobal(query)()}
+		}
+
+	case keymap.Back:
+		// Back to the results, if there are any to go back to.
+		if m.query == "" {
+			return m.Close(), nil
+		}
+		m.editing = false
+		return m, nil
+	}
+	if action != "" {
+		return m, nil
+	}
+
+	switch msg.Type {
+	case tea.KeyBackspace:
+		if len(m.input) > 0 {
+			runes := []rune(m.input)
+			m.input = string(runes[:len(runes)-1])
+		}
+
+	case tea.KeyRunes:
+		m.input += string(msg.Runes)
+
+	case tea.KeySpace:
+		m.input += " "
+	}
+	return m, nil
+}
+
+func (m *Model) moveCursor(delta int) {
+	m.cursor = max(min(m.cursor+delta, len(m.hits)-1), 0)
+	visible := m.visibleCount()
+	if m.cursor < m.offset {
+		m.offset = m.cursor
+	}
+	if m.cursor >= m.offset+visible {
+		m.offset = m.cursor - visible + 1
+	}
+}
+
+// maybeLoadMore requests the next page of results once the cursor nears
+// the end of those loaded.
+func (m *Model) maybeLoadMore() tea.Cmd {
+	if !m.hasMore || m.loadingMore || m.cursor < len(m.hits)-loadMoreThreshold {
+		return nil
+	}
+	m.loadingMore = true
+	tg, gen := m.tg, m.gen
+	cursor := m.next
+	return func() tea.Msg {
+		return resultMsg{gen: gen, msg: tg.SearchGlobalMore(cursor)()}
+	}
+}
+
+// visibleCount is how many results fit below the title and prompt.
+func (m Model) visibleCount() int {
+	return max(m.height-2, 1)
+}
+
+func (m Model) View() string {
+	title := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(common.ColorPrimary).
+		MaxWidth(m.width).
+		Padding(0, 1).
+		Render("Search all chats")
+
+	prompt := lipgloss.NewStyle().Foreground(common.ColorWarning).Render("/")
+	var line string
+	if m.editing {
+		line = prompt + m.input + "█"
+	} else {
+		line = prompt + m.query + common.StyleMuted.Render("  "+m.keys.Hint(keymap.ModeNormal, keymap.Open)+" to open, "+m.keys.Hint(keymap.ModeNormal, keymap.Back)+" to close")
+	}
+	lines := []string{title, lipgloss.NewStyle().MaxWidth(m.width).Padding(0, 1).Render(line)}
+
+	switch {
+	case m.searching:
+		lines = append(lines, common.StyleMuted.Render("  Searching..."))
+	case m.query != "" && len(m.hits) == 0:
+		lines = append(lines, common.StyleMuted.Render("  No results"))
+	}
+
+	visible := m.visibleCount()
+	for i := m.offset; i < len(m.hits) && i < m.offset+visible; i++ {
+		lines = append(lines, m.renderHit(m.hits[i], i == m.cursor))
+	}
+	if m.loadingMore && len(lines) < m.height {
+		lines = append(lines, common.StyleMuted.Render("  Loading more results..."))
+	}
+
+	for len(lines) < m.height {
+		lines = append(lines, "")
+	}
+	return strings.Join(lines[:max(m.height, 0)], "\n")
+}
+
+func (m Model) renderHit(hit telegram.SearchHit, selected bool) string {
+	msg := hit.Message
+	ts := common.StyleTimestamp.Render("[" + time.Unix(int64(msg.Date), 0).Format(m.timestampFormat) + "]")
+	chat := lipgloss.NewStyle().Bold(true).Foreground(common.ColorPrimary).Render(hit.Chat.Title)
+
+	var sender string
+	switch {
+	case msg.Out:
+		sender = common.StyleSenderSelf.Render("You")
+	case msg.Sender != "":
+		sender = common.StyleSender.Render(msg.Sender)
+	}
+
+	var text string
+	switch {
+	case msg.Media != nil && msg.Text != "":
+		text = common.StyleMediaLabel.Render(msg.Media.Label) + " " + format.RenderStyledText(msg.Text, msg.Entities)
+	case msg.Media != nil:
+		text = common.StyleMediaLabel.Render(msg.Media.Label)
+	default:
+		text = format.RenderStyledText(msg.Text, msg.Entities)
+	}
+	if sender != "" {
+		text = sender + ": " + text
+	}
+
+	prefix := "  "
+	if selected && !m.editing {
+		prefix = lipgloss.NewStyle().Foreground(common.ColorPrimary).Render(">") + " "
+	}
+	line := fmt.Sprintf("%s%s %s  %s", prefix, ts, chat, text)
+	return lipgloss.NewStyle().MaxWidth(m.width).Render(line)
+}
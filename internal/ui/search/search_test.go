@@ -0,0 +1,128 @@
+package search
+
+import (
+	"fmt"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/paramon-tech/tgtui/internal/telegram"
+	"github.com/paramon-tech/tgtui/internal/telegram/fake"
+	"github.com/paramon-tech/tgtui/internal/ui/common"
+	"github.com/paramon-tech/tgtui/internal/ui/keymap"
+	"github.com/paramon-tech/tgtui/internal/ui/uitest"
+)
+
+var (
+	alice = telegram.Chat{ID: 42, Title: "Alice", Type: telegram.ChatTypePrivate}
+	forum = telegram.Chat{ID: 77, Title: "Forum", Type: telegram.ChatTypeGroup, AccessHash: 1, Forum: true}
+)
+
+func search(t *testing.T, m Model, query string) Model {
+	t.Helper()
+	m = m.Open()
+	for _, r := range query {
+		m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+	}
+	m, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m, _ = uitest.Drain(t, m, cmd)
+	return m
+}
+
+func TestSearchPaginatesAndOpens(t *testing.T) {
+	tg := fake.New(1)
+	tg.SetDialogs(alice, forum)
+	var msgs []telegram.Message
+	for i := 1; i <= 60; i++ {
+		msgs = append(msgs, telegram.Message{ID: i, Date: i, Sender: "Alice", Text: fmt.Sprintf("hello %d", i)})
+	}
+	tg.SetHistory(alice.ID, msgs...)
+	tg.SetHistory(forum.ID, telegram.Message{ID: 500, Date: 1000, Text: "hello forum"})
+
+	m := search(t, New(tg).SetSize(80, 20), "hello")
+	if m.Editing() || len(m.hits) != 50 || !m.hasMore {
+		t.Fatalf("expected a first page of 50 with more to come, got %d (more %v)", len(m.hits), m.hasMore)
+	}
+	if m.hits[0].Message.ID != 500 {
+		t.Errorf("results should be newest first, got message %d", m.hits[0].Message.ID)
+	}
+
+	// Nearing the end of the page loads the next one.
+	m, cmd := m.Update(common.ActionMsg{Action: keymap.Bottom})
+	m, _ = uitest.Drain(t, m, cmd)
+	if len(m.hits) != 61 || m.hasMore {
+		t.Fatalf("expected all 61 results after paging, got %d (more %v)", len(m.hits), m.hasMore)
+	}
+
+	m, _ = m.Update(common.ActionMsg{Action: keymap.Bottom})
+	m, cmd = m.Update(common.ActionMsg{Action: keymap.Open})
+	if m.Active() {
+		t.Error("opening a result should close the pane")
+	}
+	open, ok := cmd().(common.OpenMessageMsg)
+	if !ok || open.Chat.ID != alice.ID || open.MessageID != 1 {
+		t.Errorf("expected to open message 1 of Alice, got %+v", open)
+	}
+
+	// A forum's result opens in its topic.
+	m = m.Open()
+	m, _ = m.Update(common.ActionMsg{Action: keymap.Back})
+	if !m.Active() || m.Editing() {
+		t.Fatal("back from the prompt should return to the results")
+	}
+	m, _ = m.Update(common.ActionMsg{Action: keymap.Top})
+	_, cmd = m.Update(common.ActionMsg{Action: keymap.Open})
+	open = cmd().(common.OpenMessageMsg)
+	if open.Chat.ID != forum.ID || open.Chat.TopicID != telegram.GeneralTopicID {
+		t.Errorf("expected the forum's General topic, got %+v", open.Chat)
+	}
+}
+
+func TestStaleResultsIgnored(t *testing.T) {
+	tg := fake.New(1)
+	tg.SetDialogs(alice)
+	tg.SetHistory(alice.ID, telegram.Message{ID: 1, Text: "cats"}, telegram.Message{ID: 2, Text: "dogs"})
+
+	m := search(t, New(tg).SetSize(80, 20), "dogs")
+	m, _ = m.Update(tg.SearchGlobal("cats")())
+	if len(m.hits) != 1 || m.hits[0].Message.ID != 2 {
+		t.Errorf("results of an earlier query replaced the current ones: %+v", m.hits)
+	}
+}
+
+func TestResubmitDropsPagesOfEarlierSearch(t *testing.T) {
+	tg := fake.New(1)
+	tg.SetDialogs(alice)
+	var msgs []telegram.Message
+	for i := 1; i <= 60; i++ {
+		msgs = append(msgs, telegram.Message{ID: i, Date: i, Text: fmt.Sprintf("hello %d", i)})
+	}
+	tg.SetHistory(alice.ID, msgs...)
+
+	m := search(t, New(tg).SetSize(80, 20), "hello")
+	m, more := m.Update(common.ActionMsg{Action: keymap.Bottom})
+	if more == nil {
+		t.Fatal("expected the next page to be requested")
+	}
+
+	// The same query again, while the old search's next page is in flight.
+	m = m.Open()
+	m, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m, _ = uitest.Drain(t, m, cmd)
+	m, _ = uitest.Drain(t, m, more)
+	if len(m.hits) != 50 {
+		t.Fatalf("expected only the new search's first page, got %d results", len(m.hits))
+	}
+
+	m, cmd = m.Update(common.ActionMsg{Action: keymap.Bottom})
+	m, _ = uitest.Drain(t, m, cmd)
+	seen := make(map[int]bool)
+	for _, hit := range m.hits {
+		if seen[hit.Message.ID] {
+			t.Fatalf("message %d listed twice", hit.Message.ID)
+		}
+		seen[hit.Message.ID] = true
+	}
+	if len(m.hits) != 60 {
+		t.Errorf("expected all 60 results after paging, got %d", len(m.hits))
+	}
+}
@@ -1,19 +1,25 @@
 package statusbar
 
 import (
+	"fmt"
+
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/paramon-tech/tgtui/internal/telegram"
 	"github.com/paramon-tech/tgtui/internal/ui/common"
 )
 
 type Model struct {
-	text  string
-	mode  string
-	width int
+	text    string
+	notice  string // shown instead of text while set, e.g. a flood wait
+	mode    string
+	conn    telegram.ConnectionState
+	pending int // requests waiting for the connection
+	width   int
 }
 
 func New() Model {
-	return Model{text: "Connecting...", mode: "NOR"}
+	return Model{mode: "NOR"}
 }
 
 func (m Model) Init() tea.Cmd {
@@ -24,10 +30,18 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case common.StatusMsg:
 		m.text = msg.Text
-	case common.AuthorizedMsg:
-		m.text = "Connected"
+	case common.ConnectionStateMsg:
+		m.conn = msg.State
+		m.pending = msg.Pending
 	case common.NeedAuthMsg:
 		m.text = "Authentication required"
+	case common.RateLimitedMsg:
+		m.notice = ""
+		if msg.Wait > 0 {
+			m.notice = fmt.Sprintf("Rate limited, retrying in %s", msg.Wait)
+		}
+	case common.UpdatesErrorMsg:
+		m.text = "Live updates stopped: " + msg.Err.Error()
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 	}
@@ -46,7 +60,31 @@ func (m Model) View() string {
 	}
 	mode := modeStyle.Render(m.mode)
 	text := lipgloss.NewStyle().Foreground(common.ColorMuted).Render(m.text)
-	return lipgloss.NewStyle().Width(m.width).Padding(0, 1).Render(mode + "  " + text)
+	if m.notice != "" {
+		text = lipgloss.NewStyle().Foreground(common.ColorWarning).Render(m.notice)
+	}
+	return lipgloss.NewStyle().Width(m.width).Padding(0, 1).Render(mode + "  " + m.renderConn() + "  " + text)
+}
+
+// renderConn shows the connection state, and while disconnected how many
+// requests are waiting for it.
+func (m Model) renderConn() string {
+	var style lipgloss.Style
+	dot := "○"
+	switch m.conn {
+	case telegram.StateConnected:
+		style = lipgloss.NewStyle().Foreground(common.ColorSecondary)
+		dot = "●"
+	case telegram.StateOffline, telegram.StateWaitingForNetwork:
+		style = lipgloss.NewStyle().Foreground(common.ColorError)
+	default:
+		style = lipgloss.NewStyle().Foreground(common.ColorWarning)
+	}
+	s := dot + " " + m.conn.String()
+	if m.conn != telegram.StateConnected && m.pending > 0 {
+		s += fmt.Sprintf(" · %d waiting", m.pending)
+	}
+	return style.Render(s)
 }
 
 func (m Model) SetSize(w int) Model {
@@ -1,11 +1,25 @@
 package ui
 
-import "github.com/paramon-tech/tgtui/internal/ui/common"
+import (
+	"github.com/paramon-tech/tgtui/internal/format"
+	"github.com/paramon-tech/tgtui/internal/theme"
+	"github.com/paramon-tech/tgtui/internal/ui/common"
+)
 
 // Re-export common styles and colors for use in the app package.
 var (
-	ColorPrimary   = common.ColorPrimary
-	ColorMuted     = common.ColorMuted
-	StyleMuted     = common.StyleMuted
-	StyleError     = common.StyleError
+	ColorPrimary = common.ColorPrimary
+	ColorMuted   = common.ColorMuted
+	StyleMuted   = common.StyleMuted
+	StyleError   = common.StyleError
 )
+
+// applyTheme switches the UI and message formatting to t.
+func applyTheme(t *theme.Theme) {
+	common.ApplyTheme(t)
+	format.UseTheme(t)
+	ColorPrimary = common.ColorPrimary
+	ColorMuted = common.ColorMuted
+	StyleMuted = common.StyleMuted
+	StyleError = common.StyleError
+}
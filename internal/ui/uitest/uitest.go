@@ -0,0 +1,42 @@
+// Package uitest holds helpers for testing the UI's Bubble Tea models.
+package uitest
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Model is a Bubble Tea model whose Update returns its own type, as the
+// UI's components do.
+type Model[M any] interface {
+	Update(msg tea.Msg) (M, tea.Cmd)
+}
+
+// Drain runs cmd and feeds every resulting message back into the model,
+// the way the Bubble Tea runtime would. It returns the non-nil messages seen.
+func Drain[M Model[M]](t testing.TB, m M, cmd tea.Cmd) (M, []tea.Msg) {
+	t.Helper()
+	var seen []tea.Msg
+	queue := []tea.Cmd{cmd}
+	for len(queue) > 0 {
+		c := queue[0]
+		queue = queue[1:]
+		if c == nil {
+			continue
+		}
+		msg := c()
+		if batch, ok := msg.(tea.BatchMsg); ok {
+			queue = append(queue, batch...)
+			continue
+		}
+		if msg == nil {
+			continue
+		}
+		seen = append(seen, msg)
+		var next tea.Cmd
+		m, next = m.Update(msg)
+		queue = append(queue, next)
+	}
+	return m, seen
+}
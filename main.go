@@ -1,38 +1,177 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
 
 	"github.com/paramon-tech/tgtui/internal/config"
 	"github.com/paramon-tech/tgtui/internal/telegram"
+	"github.com/paramon-tech/tgtui/internal/theme"
 	"github.com/paramon-tech/tgtui/internal/ui"
+	"github.com/paramon-tech/tgtui/internal/ui/keymap"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"golang.org/x/term"
 )
 
 func main() {
-	cfg, err := config.Load()
+	account := flag.String("account", config.DefaultAccount, "account profile to open; a new name adds an account")
+	configPath := flag.String("config", "", "config file (default $XDG_CONFIG_HOME/tgtui/config.toml)")
+	// Flags named after config keys override the config file.
+	flag.String("proxy", "", "proxy URL: socks5://, http://, mtproxy://secret@host:port or a tg://proxy link")
+	flag.String("download-dir", "", "where downloaded media is saved (default ~/Downloads)")
+	flag.String("timestamp-format", "", "Go time layout for message timestamps")
+	flag.String("chat-list-width", "", "chat list width, in percent of the terminal")
+	flag.String("image-protocol", "", "auto, kitty, iterm, sixel, halfblock or none")
+	flag.String("theme", "", "color theme: dark, light, high-contrast or a theme file")
+	flag.String("keymap", "", "key bindings to start from: helix, vim or emacs")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [encrypt-session [--keyring]]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	flags := make(map[string]string)
+	flag.Visit(func(f *flag.Flag) {
+		if key := strings.ReplaceAll(f.Name, "-", "_"); config.IsKey(key) {
+			flags[key] = f.Value.String()
+		}
+	})
+	load := func() (*config.Config, error) {
+		cfg, err := config.Load(*configPath, flags)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := telegram.ParseProxy(cfg.Proxy); err != nil {
+			return nil, fmt.Errorf("proxy: %w", err)
+		}
+		if _, err := keymap.New(cfg.Keymap, cfg.Keys); err != nil {
+			return nil, fmt.Errorf("%s: %w", cfg.File, err)
+		}
+		if _, err := theme.Load(cfg.Theme, cfg.ThemeDir()); err != nil {
+			return nil, fmt.Errorf("theme: %w", err)
+		}
+		return cfg, nil
+	}
+
+	cfg, err := load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if flag.Arg(0) == "encrypt-session" {
+		if err := encryptSession(cfg, *account, flag.Args()[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Every known account stays connected; the chosen one is shown first.
+	names, err := cfg.Accounts()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
+	if !slices.Contains(names, *account) {
+		names = append(names, *account)
+	}
 
-	tg := telegram.NewClient(cfg)
+	var clients []*telegram.Client
+	var accounts []ui.Account
+	for _, name := range names {
+		accountCfg, err := cfg.WithAccount(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		tg := telegram.NewClient(accountCfg)
+		clients = append(clients, tg)
+		accounts = append(accounts, ui.Account{Name: name, Backend: tg})
+	}
 
-	p := tea.NewProgram(ui.NewApp(tg), tea.WithAltScreen())
-	tg.SetProgram(p)
+	p := tea.NewProgram(ui.NewRoot(accounts, *account, cfg, load), tea.WithAltScreen())
 
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
 	go func() {
-		if err := tg.Run(); err != nil {
-			p.Send(ui.FatalErrorMsg{Err: err})
+		for range hup {
+			p.Send(ui.ReloadConfigMsg{})
 		}
 	}()
 
+	for _, tg := range clients {
+		tg.SetProgram(p)
+		go func(tg *telegram.Client) {
+			if err := tg.Run(); err != nil {
+				p.Send(ui.AccountMsg{Account: tg.Account(), Msg: ui.FatalErrorMsg{Err: err}})
+			}
+		}(tg)
+	}
+
 	if _, err := p.Run(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 
-	tg.Stop()
+	for _, tg := range clients {
+		tg.Stop()
+	}
+}
+
+// encryptSession migrates an account's plaintext session to an encrypted
+// one, asking for the new passphrase on the terminal.
+func encryptSession(cfg *config.Config, account string, args []string) error {
+	fs := flag.NewFlagSet("encrypt-session", flag.ExitOnError)
+	useKeyring := fs.Bool("keyring", false, "also store the passphrase in the OS keyring so startup does not ask for it")
+	fs.Parse(args)
+
+	accountCfg, err := cfg.WithAccount(account)
+	if err != nil {
+		return err
+	}
+
+	passphrase, err := readPassphrase("New passphrase: ")
+	if err != nil {
+		return err
+	}
+	if passphrase == "" {
+		return fmt.Errorf("passphrase must not be empty")
+	}
+	confirm, err := readPassphrase("Repeat passphrase: ")
+	if err != nil {
+		return err
+	}
+	if confirm != passphrase {
+		return fmt.Errorf("passphrases do not match")
+	}
+
+	if err := telegram.EncryptSession(accountCfg.SessionPath(), passphrase); err != nil {
+		return err
+	}
+	fmt.Printf("Session for %q is now encrypted.\n", account)
+
+	if *useKeyring {
+		if err := telegram.SaveSessionPassphrase(account, passphrase); err != nil {
+			return fmt.Errorf("session encrypted, but saving the passphrase failed: %w", err)
+		}
+		fmt.Println("Passphrase saved in the OS keyring.")
+	}
+	return nil
+}
+
+func readPassphrase(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	b, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	return string(b), nil
 }